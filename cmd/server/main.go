@@ -1,62 +1,279 @@
 package main
 
 import (
+	"context"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"srs-backend/internal/config"
 	"srs-backend/internal/handlers"
+	"srs-backend/internal/logging"
 	"srs-backend/internal/services"
+	"srs-backend/internal/storage"
+	"srs-backend/internal/telemetry"
 )
 
 func main() {
 	// Inicializar configuración
 	cfg := config.New()
 
-	log.Printf("🆔 Server ID: %s", cfg.ServerID)
-	log.Printf("🌐 Server IP: %s", cfg.ServerIP)
+	// Subcomando de migraciones: main migrate up|down [N]|status
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(cfg, os.Args[2:])
+		return
+	}
+	// Exportación de consumo: main usage export [flags]
+	if len(os.Args) > 1 && os.Args[1] == "usage" {
+		runUsage(cfg, os.Args[2:])
+		return
+	}
+	// Benchmark de escrituras en lote: main bench-writes [flags]
+	if len(os.Args) > 1 && os.Args[1] == "bench-writes" {
+		runBenchWrites(os.Args[2:])
+		return
+	}
+
+	// Logs estructurados; log.Printf de dependencias también pasa por aquí
+	if err := logging.Setup(os.Stdout, cfg.LogFormat, cfg.LogLevel, cfg.ServerID); err != nil {
+		log.Fatalf("❌ Error inicializando logs: %v", err)
+	}
+	slog.Info("🆔 Servidor iniciando", "server_ip", cfg.ServerIP, "log_format", logging.Format(), "log_level", logging.Level())
+
+	// Trazas OTLP de hooks, base de datos y ffmpeg
+	shutdownTracing, err := telemetry.SetupTracing(cfg.TracesExporter, cfg.ServerID, cfg.TracesSampleRatio)
+	if err != nil {
+		fatal("❌ Error inicializando trazas", err)
+	}
+	slog.Info("🔭 Trazas", "exporter", cfg.TracesExporter)
 
 	// Inicializar servicios
-	supabaseService := services.NewSupabaseService(cfg.SupabaseURL, cfg.SupabaseKey)
-	thumbnailService := services.NewThumbnailService()
+	backend, err := storage.Open(cfg.StorageBackend, storage.Options{
+		SupabaseURL: cfg.SupabaseURL,
+		SupabaseKey: cfg.SupabaseKey,
+		DatabaseURL: cfg.DatabaseURL,
+	})
+	if err != nil {
+		fatal("❌ Error inicializando almacenamiento", err)
+	}
+	slog.Info("🗄️ Almacenamiento", "backend", cfg.StorageBackend)
+	store := storage.NewStore(backend)
+	store.SetBatching(cfg.MetricsBatchSize, cfg.SessionFlushInterval)
+	// Eventos en vivo para /events/stream; las alertas llegan al registrarse
+	eventBus := services.NewEventBus(cfg.ServerID, cfg.EventsBufferSize)
+	store.SetEventHook(eventBus.Alert)
+	thumbnailService := services.NewThumbnailService(eventBus)
+
+	// Outbox: las escrituras sobreviven a caídas de la base de datos y reinicios
+	outbox, err := storage.NewOutbox(cfg.OutboxDir, store.Deliver, cfg.OutboxMaxAttempts, cfg.OutboxMaxBackoff)
+	if err != nil {
+		fatal("❌ Error abriendo outbox", err, "dir", cfg.OutboxDir)
+	}
+	store.SetOutbox(outbox)
+	go outbox.Start()
+
+	// Privacidad: las IPs de clientes se anonimizan al guardarlas
+	privacyService, err := services.NewPrivacyService(store, cfg.PrivacyIPMode, cfg.PrivacyIPSalt, cfg.ServerID, cfg.ServerIP)
+	if err != nil {
+		fatal("❌ Error en la configuración de privacidad", err)
+	}
+	if privacyService.Mode() != services.PrivacyIPModeOff {
+		store.SetIPAnonymizer(privacyService.AnonymizeIP)
+	}
+	slog.Info("🔏 Privacidad", "ip_mode", privacyService.Mode())
+	var retentionService *services.RetentionService
+	if cfg.RetentionInterval > 0 {
+		retentionService = services.NewRetentionService(store, services.RetentionPolicy{
+			Sessions:      cfg.RetentionSessions,
+			Events:        cfg.RetentionEvents,
+			StreamMetrics: cfg.RetentionStreamMetrics,
+			ServerMetrics: cfg.RetentionServerMetrics,
+			SystemMetrics: cfg.RetentionSystemMetrics,
+		}, cfg.RetentionInterval)
+		go retentionService.Start()
+	}
+
+	streamKeyService := services.NewStreamKeyService(store, cfg.ServerID, cfg.ServerIP, cfg.BackupKeySuffix)
+
+	// Geolocalización de clientes con bases locales, sin consultas de red
+	geoIPService := services.NewGeoIPService(cfg.GeoIPCityDB, cfg.GeoIPASNDB, cfg.GeoIPReloadInterval)
+	if !geoIPService.Enabled() {
+		slog.Info("🌍 GeoIP sin bases cargadas, las sesiones se guardan sin ubicación", "city_db", cfg.GeoIPCityDB, "asn_db", cfg.GeoIPASNDB)
+	}
+	go geoIPService.Start()
+	publisherTracker := services.NewPublisherTracker(store, geoIPService, cfg.ServerID, cfg.ServerIP, cfg.DuplicatePublishPolicy)
+	failoverService := services.NewFailoverService(store, streamKeyService, cfg.ServerID, cfg.ServerIP, cfg.FailoverReconnectTimeout)
+	tenantService := services.NewTenantService(store, streamKeyService, cfg.ServerID, cfg.ServerIP, cfg.TenantCacheTTL)
+	// Consumo diario por canal y organización para facturación
+	usageService := services.NewUsageService(store, tenantService, cfg.UsageInterval, cfg.UsageLookbackDays)
+	if cfg.UsageInterval > 0 {
+		go usageService.Start()
+	}
+	// Webhooks salientes firmados; retoma las entregas pendientes de este servidor
+	webhookService := services.NewWebhookService(store, tenantService, cfg.ServerID, cfg.ServerIP, cfg.WebhookTimeout, cfg.WebhookMaxAttempts, cfg.WebhookMaxBackoff, cfg.TenantCacheTTL)
+	go webhookService.Start()
+	publishGuard := services.NewPublishGuard(store, cfg.ServerID, cfg.ServerIP, services.PublishGuardLimits{
+		MaxFailuresPerIP:     cfg.PublishMaxFailuresPerIP,
+		MaxFailuresPerSubnet: cfg.PublishMaxFailuresPerSubnet,
+		FailureWindow:        cfg.PublishFailureWindow,
+		BaseLockout:          cfg.PublishLockoutBase,
+		MaxLockout:           cfg.PublishLockoutMax,
+	})
 
 	// ✅ Registrar servidor en BD
-	if err := supabaseService.RegisterServer(cfg.ServerID, cfg.ServerIP); err != nil {
-		log.Printf("⚠️ Error registrando servidor: %v", err)
+	if err := store.Servers.Register(context.Background(), cfg.ServerID, cfg.ServerIP); err != nil {
+		slog.Warn("⚠️ Error registrando servidor", "error", err)
 	} else {
-		log.Printf("✅ Servidor %s registrado en base de datos", cfg.ServerID)
+		slog.Info("✅ Servidor registrado en base de datos")
 	}
 
+	sessionTracker := services.NewSessionTracker(store, geoIPService, cfg.ServerID, cfg.ServerIP)
+	liveViewers := services.NewLiveViewers(store, sessionTracker, eventBus, cfg.ServerID, cfg.LiveViewersMinChangePercent, cfg.LiveViewersMaxDelay)
+
+	// Snapshot de cada ciclo del recolector para el feed WebSocket
+	statsFeed := services.NewStatsFeed()
+
 	// ✅ CORREGIDO: Pasar serverID y serverIP
-	metricsCollector := services.NewMetricsCollector(supabaseService, cfg.ServerID, cfg.ServerIP)
+	metricsCollector := services.NewMetricsCollector(store, tenantService, liveViewers, statsFeed, cfg.ServerID, cfg.ServerIP)
 
 	// Iniciar recolector de métricas en background
 	go metricsCollector.Start()
 
 	// Inicializar handlers
 	// Cambio: pasar ServerIP a PublishHandler (Firma: Cursor)
-	publishHandler := handlers.NewPublishHandler(supabaseService, thumbnailService, cfg.ServerIP)
-	unpublishHandler := handlers.NewUnpublishHandler(supabaseService, thumbnailService)
+	// Restricción geográfica por canal en on_play y on_publish
+	policyService := services.NewChannelPolicyService(store, geoIPService, cfg.ServerID, cfg.ServerIP, cfg.TenantCacheTTL)
+	publishHandler := handlers.NewPublishHandler(store, thumbnailService, publishGuard, streamKeyService, publisherTracker, failoverService, tenantService, policyService, sessionTracker, eventBus, webhookService, cfg.ServerIP)
+	unpublishHandler := handlers.NewUnpublishHandler(store, thumbnailService, streamKeyService, publisherTracker, failoverService, sessionTracker, eventBus, webhookService)
 	// Cambio: handler para sesiones on_play/on_stop (Firma: Cursor)
-	sessionsHandler := handlers.NewSessionsHandler(supabaseService, cfg.ServerID, cfg.ServerIP)
-	forwardHandler := handlers.NewForwardHandler(cfg.TargetForwardURL)
+	sessionsHandler := handlers.NewSessionsHandler(tenantService, policyService, sessionTracker, webhookService)
+
+	// Cierra sesiones sin on_stop y adopta clientes sin sesión
+	var sessionReconciler *services.SessionReconciler
+	if cfg.SessionReconcileInterval > 0 {
+		sessionReconciler = services.NewSessionReconciler(store, sessionTracker, tenantService, cfg.ServerID, cfg.SessionReconcileInterval)
+		go sessionReconciler.Start()
+	}
+	forwardHandler := handlers.NewForwardHandler(cfg.TargetForwardURL, failoverService)
 	statsHandler := handlers.NewStatsHandler()
 	clientsHandler := handlers.NewClientsHandler()
 	performanceHandler := handlers.NewPerformanceHandler()
 	summaryHandler := handlers.NewSummaryHandler()
+	securityHandler := handlers.NewSecurityHandler(publishGuard, cfg.AdminToken)
+	keysHandler := handlers.NewKeysHandler(streamKeyService, cfg.AdminToken)
+	failoverHandler := handlers.NewFailoverHandler(failoverService, cfg.AdminToken)
+	outboxHandler := handlers.NewOutboxHandler(outbox, cfg.AdminToken)
+	loggingHandler := handlers.NewLoggingHandler(cfg.AdminToken)
+	analyticsHandler := handlers.NewAnalyticsHandler(services.NewAnalyticsService(store), cfg.AdminToken)
+	policiesHandler := handlers.NewPoliciesHandler(policyService, cfg.AdminToken)
+	privacyHandler := handlers.NewPrivacyHandler(privacyService, cfg.AdminToken)
+	usageHandler := handlers.NewUsageHandler(usageService, cfg.AdminToken)
+	eventsHandler := handlers.NewEventsHandler(eventBus, cfg.AdminToken)
+	streamTicketsHandler := handlers.NewStreamTicketsHandler(cfg.AdminToken)
+	webhooksHandler := handlers.NewWebhooksHandler(webhookService, cfg.AdminToken)
+	statsFeedHandler := handlers.NewStatsFeedHandler(statsFeed, cfg.StatsFeedFullInterval, cfg.AllowedOrigins, cfg.AdminToken)
+
+	// Métricas internas leídas en cada scrape de /metrics
+	telemetry.Register(telemetry.Sources{
+		Backend:        cfg.StorageBackend,
+		WriteStats:     store.WriteStats,
+		OutboxStatus:   outbox.Status,
+		ActiveCaptures: thumbnailService.ActiveCaptures,
+		EventClients:   eventBus.Subscribers,
+		StatsClients:   statsFeed.Clients,
+	})
 
 	// Registrar rutas
-	http.HandleFunc("/api/v1/publish", publishHandler.Handle)
-	http.HandleFunc("/api/v1/unpublish", unpublishHandler.Handle)
+	http.HandleFunc("/api/v1/publish", telemetry.InstrumentHook("on_publish", publishHandler.Handle))
+	http.HandleFunc("/api/v1/unpublish", telemetry.InstrumentHook("on_unpublish", unpublishHandler.Handle))
 	// Cambio: ruta para callbacks de sesiones (Firma: Cursor)
-	http.HandleFunc("/api/v1/sessions", sessionsHandler.Handle)
-	http.HandleFunc("/api/v1/forward", forwardHandler.Handle)
+	http.HandleFunc("/api/v1/sessions", telemetry.InstrumentHook("sessions", sessionsHandler.Handle))
+	http.HandleFunc("/api/v1/forward", telemetry.InstrumentHook("on_forward", forwardHandler.Handle))
 	http.HandleFunc("/api/v1/stats", statsHandler.Handle)
+	http.HandleFunc("/api/v1/stats/ws", statsFeedHandler.Handle)
 	http.HandleFunc("/api/v1/clients", clientsHandler.Handle)
 	http.HandleFunc("/api/v1/performance", performanceHandler.Handle)
 	http.HandleFunc("/api/v1/summary", summaryHandler.Handle)
+	http.HandleFunc("/api/v1/security/lockouts", securityHandler.HandleLockouts)
+	http.HandleFunc("/api/v1/keys/", keysHandler.Handle)
+	http.HandleFunc("/api/v1/failover", failoverHandler.Handle)
+	http.HandleFunc("/api/v1/outbox/status", outboxHandler.Handle)
+	http.HandleFunc("/api/v1/logging", loggingHandler.Handle)
+	http.HandleFunc("/api/v1/analytics/", analyticsHandler.Handle)
+	http.HandleFunc("/api/v1/policies/", policiesHandler.Handle)
+	http.HandleFunc("/api/v1/privacy/erase", privacyHandler.Handle)
+	http.HandleFunc("/api/v1/usage", usageHandler.Handle)
+	http.HandleFunc("/api/v1/events/stream", eventsHandler.Handle)
+	http.HandleFunc("/api/v1/stream-tickets", streamTicketsHandler.Handle)
+	http.HandleFunc("/api/v1/webhooks", webhooksHandler.Handle)
+	http.HandleFunc("/api/v1/webhooks/", webhooksHandler.Handle)
+	http.Handle("/metrics", telemetry.Handler())
 
 	port := cfg.Port
-	log.Printf("🚀 Backend Go iniciado en puerto %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	server := &http.Server{Addr: ":" + port}
+	// Shutdown no espera a los streams de eventos ni a los WebSocket: se
+	// cierran al empezar
+	server.RegisterOnShutdown(eventBus.Close)
+	server.RegisterOnShutdown(statsFeed.Close)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fatal("❌ Servidor HTTP detenido", err)
+		}
+	}()
+	slog.Info("🚀 Backend Go iniciado", "port", port)
+
+	// Apagado ordenado: SIGTERM de docker stop o SIGINT
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	<-ctx.Done()
+	stop()
+	slog.Info("🛑 Apagando backend", "timeout", cfg.ShutdownTimeout.String())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// 1. Dejar de aceptar conexiones y esperar a los hooks en curso
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("⚠️ Peticiones HTTP sin terminar al apagar", "error", err)
+	}
+	// 2. Último guardado del recolector y parada
+	metricsCollector.Stop(shutdownCtx)
+	// 3. Sin capturas nuevas y sin ffmpeg huérfanos
+	thumbnailService.StopAll()
+	// 4. SRS no enviará on_stop de los viewers conectados
+	if sessionReconciler != nil {
+		sessionReconciler.Stop(shutdownCtx)
+	}
+	closed := sessionTracker.CloseAll(shutdownCtx, services.DisconnectReasonShutdown)
+	slog.Info("🔒 Sesiones cerradas", "sessions", closed, "reason", services.DisconnectReasonShutdown)
+	geoIPService.Stop(shutdownCtx)
+	if retentionService != nil {
+		retentionService.Stop(shutdownCtx)
+	}
+	if cfg.UsageInterval > 0 {
+		usageService.Stop(shutdownCtx)
+	}
+	// Los reintentos programados quedan pendientes y se retoman al arrancar
+	webhookService.Stop(shutdownCtx)
+	// 5. Entregar lo encolado; lo que no llegue queda en el outbox
+	store.Flush()
+	if err := outbox.Drain(shutdownCtx); err != nil {
+		slog.Warn("⚠️ Outbox sin vaciar al apagar", "error", err)
+	}
+	// 6. Después del outbox, para que un heartbeat pendiente no lo reactive
+	if err := store.Servers.MarkInactive(shutdownCtx, cfg.ServerID); err == nil {
+		slog.Info("✅ Servidor marcado como inactivo")
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Warn("⚠️ Error cerrando el exportador de trazas", "error", err)
+	}
+	slog.Info("👋 Backend detenido")
+}
+
+// fatal registra el error y termina el proceso
+func fatal(msg string, err error, args ...any) {
+	slog.Error(msg, append(args, "error", err)...)
+	os.Exit(1)
 }
\ No newline at end of file
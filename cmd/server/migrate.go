@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"srs-backend/internal/config"
+	"srs-backend/internal/migrations"
+	"srs-backend/internal/storage"
+)
+
+// runMigrate implementa "migrate up|down [N]|status" contra DATABASE_URL
+func runMigrate(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "uso: main migrate up|down [N]|status")
+		os.Exit(2)
+	}
+
+	backend, err := storage.NewPostgresBackend(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	defer backend.Close()
+
+	migrator, err := migrations.NewMigrator(backend.DB())
+	if err != nil {
+		log.Fatalf("❌ Error cargando migraciones: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			log.Printf("⬆️ Aplicada %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		if len(applied) == 0 {
+			log.Printf("✅ Esquema al día")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Fatalf("❌ Número de pasos inválido: %s", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			log.Printf("⬇️ Revertida %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		for _, s := range statuses {
+			applied := "pendiente"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d  %-28s  %s\n", s.Version, s.Name, applied)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "subcomando desconocido: %s\n", args[0])
+		os.Exit(2)
+	}
+}
@@ -3,6 +3,9 @@ package config
 import (
 	"net"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -12,6 +15,94 @@ type Config struct {
 	Port             string
 	ServerID         string // ✅ Campo agregado
 	ServerIP         string // ✅ Campo agregado
+	AdminToken       string
+
+	// Protección contra fuerza bruta de claves en on_publish
+	PublishMaxFailuresPerIP     int
+	PublishMaxFailuresPerSubnet int
+	PublishFailureWindow        time.Duration
+	PublishLockoutBase          time.Duration
+	PublishLockoutMax           time.Duration
+
+	// Política ante dos encoders con la misma clave: reject, takeover o failover
+	DuplicatePublishPolicy string
+
+	// Ingesta de respaldo: "<clave><BackupKeySuffix>" publica oculto
+	BackupKeySuffix          string
+	FailoverReconnectTimeout time.Duration
+
+	// Cache de organización/plan por canal para las cuotas
+	TenantCacheTTL time.Duration
+
+	// Backend de persistencia: supabase, postgres o memory
+	StorageBackend string
+	DatabaseURL    string
+
+	// Trazas OpenTelemetry: none, otlp (OTLP/HTTP) o stdout
+	TracesExporter    string
+	TracesSampleRatio float64
+
+	// Logs estructurados: json o logfmt; nivel debug, info, warn o error
+	LogFormat string
+	LogLevel  string
+
+	// Tiempo máximo para el apagado ordenado tras SIGTERM
+	ShutdownTimeout time.Duration
+
+	// Escrituras en lote: filas por insert y acumulación de sesiones
+	MetricsBatchSize     int
+	SessionFlushInterval time.Duration
+
+	// Reconciliación de sesiones con los clientes de SRS; 0 la desactiva
+	SessionReconcileInterval time.Duration
+
+	// Bases GeoIP locales en formato MaxMind; se releen al cambiar
+	GeoIPCityDB         string
+	GeoIPASNDB          string
+	GeoIPReloadInterval time.Duration
+
+	// Privacidad: IPs de clientes en claro (off), con hash o truncadas
+	PrivacyIPMode string
+	PrivacyIPSalt string
+
+	// Retención aplicada desde el backend, en días por tabla; 0 conserva la
+	// tabla y RETENTION_INTERVAL=0 desactiva la retención
+	RetentionInterval      time.Duration
+	RetentionSessions      time.Duration
+	RetentionEvents        time.Duration
+	RetentionStreamMetrics time.Duration
+	RetentionServerMetrics time.Duration
+	RetentionSystemMetrics time.Duration
+
+	// Viewers en vivo escritos en channels_channel: cambio mínimo en % y
+	// espera máxima para escribir un cambio menor
+	LiveViewersMinChangePercent float64
+	LiveViewersMaxDelay         time.Duration
+
+	// Eventos en vivo de /events/stream: últimos eventos guardados para
+	// retomar con Last-Event-ID
+	EventsBufferSize int
+
+	// Feed WebSocket de estadísticas: cada cuánto se envía el snapshot
+	// completo; entre medias solo diffs en cada ciclo del recolector
+	StatsFeedFullInterval time.Duration
+	// Orígenes web que pueden abrir el WebSocket, además del propio host
+	AllowedOrigins []string
+
+	// Webhooks salientes: timeout de cada envío, intentos antes de dejar la
+	// entrega como dead y espera máxima entre reintentos
+	WebhookTimeout     time.Duration
+	WebhookMaxAttempts int
+	WebhookMaxBackoff  time.Duration
+
+	// Consumo diario para facturación; USAGE_INTERVAL=0 desactiva el cálculo
+	UsageInterval     time.Duration
+	UsageLookbackDays int
+
+	// Outbox en disco para escrituras en la base de datos
+	OutboxDir         string
+	OutboxMaxAttempts int
+	OutboxMaxBackoff  time.Duration
 }
 
 func New() *Config {
@@ -22,6 +113,68 @@ func New() *Config {
 		Port:             getEnvOrDefault("PORT", "3000"),
 		ServerID:         getEnvOrDefault("SERVER_ID", "srs-paris-01"),
 		ServerIP:         getEnvOrDefault("SERVER_IP", getOutboundIP()),
+		AdminToken:       os.Getenv("ADMIN_TOKEN"),
+
+		PublishMaxFailuresPerIP:     getEnvInt("PUBLISH_MAX_FAILURES_PER_IP", 5),
+		PublishMaxFailuresPerSubnet: getEnvInt("PUBLISH_MAX_FAILURES_PER_SUBNET", 20),
+		PublishFailureWindow:        getEnvDuration("PUBLISH_FAILURE_WINDOW", 10*time.Minute),
+		PublishLockoutBase:          getEnvDuration("PUBLISH_LOCKOUT_BASE", time.Minute),
+		PublishLockoutMax:           getEnvDuration("PUBLISH_LOCKOUT_MAX", 24*time.Hour),
+
+		DuplicatePublishPolicy: getEnvOrDefault("DUPLICATE_PUBLISH_POLICY", "reject"),
+
+		BackupKeySuffix:          getEnvOrDefault("BACKUP_KEY_SUFFIX", "_backup"),
+		FailoverReconnectTimeout: getEnvDuration("FAILOVER_RECONNECT_TIMEOUT", 30*time.Second),
+
+		TenantCacheTTL: getEnvDuration("TENANT_CACHE_TTL", time.Minute),
+
+		StorageBackend: getEnvOrDefault("STORAGE_BACKEND", "supabase"),
+		DatabaseURL:    os.Getenv("DATABASE_URL"),
+
+		TracesExporter:    getEnvOrDefault("OTEL_TRACES_EXPORTER", "none"),
+		TracesSampleRatio: getEnvFloat("OTEL_TRACES_SAMPLER_ARG", 1),
+
+		LogFormat: getEnvOrDefault("LOG_FORMAT", "json"),
+		LogLevel:  getEnvOrDefault("LOG_LEVEL", "info"),
+
+		ShutdownTimeout: getEnvDuration("SHUTDOWN_TIMEOUT", 25*time.Second),
+
+		MetricsBatchSize:     getEnvInt("METRICS_BATCH_SIZE", 500),
+		SessionFlushInterval: getEnvDuration("SESSION_FLUSH_INTERVAL", time.Second),
+
+		SessionReconcileInterval: getEnvDuration("SESSION_RECONCILE_INTERVAL", time.Minute),
+
+		GeoIPCityDB:         getEnvOrDefault("GEOIP_CITY_DB", "/app/geoip/GeoLite2-City.mmdb"),
+		GeoIPASNDB:          getEnvOrDefault("GEOIP_ASN_DB", "/app/geoip/GeoLite2-ASN.mmdb"),
+		GeoIPReloadInterval: getEnvDuration("GEOIP_RELOAD_INTERVAL", time.Minute),
+
+		PrivacyIPMode: getEnvOrDefault("PRIVACY_IP_MODE", "off"),
+		PrivacyIPSalt: os.Getenv("PRIVACY_IP_SALT"),
+
+		RetentionInterval:      getEnvDuration("RETENTION_INTERVAL", 0),
+		RetentionSessions:      getEnvDays("RETENTION_SESSIONS_DAYS", 90),
+		RetentionEvents:        getEnvDays("RETENTION_EVENTS_DAYS", 90),
+		RetentionStreamMetrics: getEnvDays("RETENTION_STREAM_METRICS_DAYS", 7),
+		RetentionServerMetrics: getEnvDays("RETENTION_SERVER_METRICS_DAYS", 30),
+		RetentionSystemMetrics: getEnvDays("RETENTION_SYSTEM_METRICS_DAYS", 7),
+
+		LiveViewersMinChangePercent: getEnvFloat("LIVE_VIEWERS_MIN_CHANGE_PERCENT", 5),
+		LiveViewersMaxDelay:         getEnvDuration("LIVE_VIEWERS_MAX_DELAY", 2*time.Minute),
+
+		EventsBufferSize:      getEnvInt("EVENTS_BUFFER_SIZE", 1000),
+		StatsFeedFullInterval: getEnvDuration("STATS_FEED_FULL_INTERVAL", 5*time.Minute),
+		AllowedOrigins:        getEnvList("ALLOWED_ORIGINS"),
+
+		WebhookTimeout:     getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookMaxAttempts: getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		WebhookMaxBackoff:  getEnvDuration("WEBHOOK_MAX_BACKOFF", time.Hour),
+
+		UsageInterval:     getEnvDuration("USAGE_INTERVAL", time.Hour),
+		UsageLookbackDays: getEnvInt("USAGE_LOOKBACK_DAYS", 2),
+
+		OutboxDir:         getEnvOrDefault("OUTBOX_DIR", "/app/outbox"),
+		OutboxMaxAttempts: getEnvInt("OUTBOX_MAX_ATTEMPTS", 5),
+		OutboxMaxBackoff:  getEnvDuration("OUTBOX_MAX_BACKOFF", time.Minute),
 	}
 }
 
@@ -32,6 +185,44 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvDuration acepta formato Go (30s, 5m, 1h)
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvList separa una lista "a,b" sin elementos vacíos
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvDays lee un número entero de días
+func getEnvDays(key string, defaultValue int) time.Duration {
+	return time.Duration(getEnvInt(key, defaultValue)) * 24 * time.Hour
+}
+
 // ✅ Obtener IP del servidor automáticamente
 func getOutboundIP() string {
 	conn, err := net.Dial("udp", "8.8.8.8:80")
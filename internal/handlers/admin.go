@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// authorizeAdmin valida el token de administración enviado como
+// "Authorization: Bearer <token>" o en X-Admin-Token. Sin ADMIN_TOKEN
+// configurado los endpoints administrativos quedan deshabilitados.
+func authorizeAdmin(w http.ResponseWriter, r *http.Request, adminToken string) bool {
+	if adminToken == "" {
+		slog.WarnContext(r.Context(), "⚠️ ADMIN_TOKEN no configurado, acceso denegado", "path", r.URL.Path)
+		writeJSONError(w, http.StatusForbidden, "ADMIN_TOKEN no configurado")
+		return false
+	}
+
+	token := r.Header.Get("X-Admin-Token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+		writeJSONError(w, http.StatusUnauthorized, "no autorizado")
+		return false
+	}
+
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"error": message})
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"srs-backend/internal/services"
+)
+
+const (
+	defaultAnalyticsRange = 24 * time.Hour
+	defaultAnalyticsStep  = 5 * time.Minute
+)
+
+type AnalyticsHandler struct {
+	analytics  *services.AnalyticsService
+	adminToken string
+}
+
+func NewAnalyticsHandler(analytics *services.AnalyticsService, adminToken string) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		analytics:  analytics,
+		adminToken: adminToken,
+	}
+}
+
+// Handle atiende /api/v1/analytics/streams/{stream} y
+// /api/v1/analytics/channels/{channel_id} con ?from=&to=&resolution=, el
+// reparto por país y red en .../geo y por dominio de origen en .../domains
+func (h *AnalyticsHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	if !authorizeAdmin(w, r, h.adminToken) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "método no permitido")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/analytics"), "/"), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[1] == "" ||
+		(parts[0] != "streams" && parts[0] != "channels") ||
+		(len(parts) == 3 && parts[2] != "geo" && parts[2] != "domains") {
+		writeJSONError(w, http.StatusNotFound, "ruta inválida, usar /api/v1/analytics/streams/{stream}[/geo|/domains] o /api/v1/analytics/channels/{channel_id}[/geo|/domains]")
+		return
+	}
+	breakdown := ""
+	if len(parts) == 3 {
+		breakdown = parts[2]
+	}
+
+	window, err := parseAnalyticsWindow(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var report interface{}
+	switch {
+	case parts[0] == "streams" && breakdown == "geo":
+		report, err = h.analytics.StreamGeo(r.Context(), parts[1], window)
+	case parts[0] == "streams" && breakdown == "domains":
+		report, err = h.analytics.StreamDomains(r.Context(), parts[1], window)
+	case parts[0] == "streams":
+		report, err = h.analytics.StreamReport(r.Context(), parts[1], window)
+	case breakdown == "geo":
+		report, err = h.analytics.ChannelGeo(r.Context(), parts[1], window)
+	case breakdown == "domains":
+		report, err = h.analytics.ChannelDomains(r.Context(), parts[1], window)
+	default:
+		report, err = h.analytics.ChannelReport(r.Context(), parts[1], window)
+	}
+	if err != nil {
+		if errors.Is(err, services.ErrChannelNotFound) {
+			writeJSONError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		slog.ErrorContext(r.Context(), "❌ Error calculando analíticas", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// parseAnalyticsWindow usa las últimas 24h a 5m si no se indica otra cosa
+func parseAnalyticsWindow(r *http.Request) (services.AnalyticsWindow, error) {
+	query := r.URL.Query()
+	window := services.AnalyticsWindow{To: time.Now().UTC(), Step: defaultAnalyticsStep}
+
+	if value := query.Get("to"); value != "" {
+		to, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return window, errors.New("to debe ser RFC3339")
+		}
+		window.To = to.UTC()
+	}
+	window.From = window.To.Add(-defaultAnalyticsRange)
+	if value := query.Get("from"); value != "" {
+		from, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return window, errors.New("from debe ser RFC3339")
+		}
+		window.From = from.UTC()
+	}
+	if value := query.Get("resolution"); value != "" {
+		step, err := time.ParseDuration(value)
+		if err != nil {
+			return window, errors.New("resolution inválida, usar por ejemplo 1m, 15m o 1h")
+		}
+		window.Step = step
+	}
+	return window, window.Validate()
+}
@@ -2,7 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 
 	"srs-backend/internal/models"
@@ -20,7 +20,7 @@ func (h *ClientsHandler) Handle(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := http.Get("http://srs:1985/api/v1/clients/")
 	if err != nil {
-		log.Printf("❌ Error obteniendo clientes: %v", err)
+		slog.ErrorContext(r.Context(), "❌ Error obteniendo clientes", "error", err)
 		json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
 		return
 	}
@@ -62,5 +62,5 @@ func (h *ClientsHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	json.NewEncoder(w).Encode(response)
-	log.Printf("📊 Clientes solicitados: %d conectados", len(clients))
+	slog.DebugContext(r.Context(), "📊 Clientes solicitados", "connected", len(clients))
 }
\ No newline at end of file
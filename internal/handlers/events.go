@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"srs-backend/internal/services"
+)
+
+// eventsHeartbeat mantiene abierta la conexión a través de proxies que
+// cortan las respuestas inactivas
+const eventsHeartbeat = 15 * time.Second
+
+type EventsHandler struct {
+	events     *services.EventBus
+	adminToken string
+}
+
+func NewEventsHandler(events *services.EventBus, adminToken string) *EventsHandler {
+	return &EventsHandler{
+		events:     events,
+		adminToken: adminToken,
+	}
+}
+
+// Handle atiende GET /api/v1/events/stream como Server-Sent Events, con
+// ?server_id=&app=&channel_id=&type=publish,alert para filtrar y
+// Last-Event-ID (cabecera o ?last_event_id=) para retomar. EventSource no
+// envía cabeceras: el navegador se autentica con ?ticket=.
+func (h *EventsHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	if !authorizeStream(w, r, h.adminToken, r.URL.Query().Get("ticket")) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "método no permitido")
+		return
+	}
+
+	query := r.URL.Query()
+	filter := services.EventFilter{
+		ServerID:  query.Get("server_id"),
+		App:       query.Get("app"),
+		ChannelID: query.Get("channel_id"),
+		Types:     services.ParseEventTypes(query.Get("type")),
+	}
+	for _, t := range filter.Types {
+		if !slices.Contains(services.EventTypes, t) {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("type %q inválido, usar %v", t, services.EventTypes))
+			return
+		}
+	}
+
+	var lastID uint64
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = query.Get("last_event_id")
+	}
+	if lastEventID != "" {
+		id, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Last-Event-ID inválido")
+			return
+		}
+		lastID = id
+	}
+
+	sub, backlog, resync := h.events.Subscribe(filter, lastID)
+	defer h.events.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// nginx no debe acumular la respuesta
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+
+	slog.InfoContext(r.Context(), "📡 Cliente de eventos conectado", "last_event_id", lastID, "backlog", len(backlog), "resync", resync)
+	defer slog.InfoContext(r.Context(), "📡 Cliente de eventos desconectado")
+
+	// Sin id: el cliente conserva su Last-Event-ID hasta el siguiente evento
+	fmt.Fprintf(w, "retry: %d\n\n", (3 * time.Second).Milliseconds())
+	if resync {
+		fmt.Fprint(w, "event: resync\ndata: {}\n\n")
+	}
+	for _, event := range backlog {
+		if writeLiveEvent(w, event) != nil {
+			return
+		}
+	}
+	if rc.Flush() != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				// Suscriptor lento o apagado: el cliente reconecta y retoma
+				return
+			}
+			if writeLiveEvent(w, event) != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+		if rc.Flush() != nil {
+			return
+		}
+	}
+}
+
+func writeLiveEvent(w http.ResponseWriter, event services.LiveEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
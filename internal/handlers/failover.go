@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"srs-backend/internal/services"
+)
+
+type FailoverHandler struct {
+	failover   *services.FailoverService
+	adminToken string
+}
+
+func NewFailoverHandler(failover *services.FailoverService, adminToken string) *FailoverHandler {
+	return &FailoverHandler{
+		failover:   failover,
+		adminToken: adminToken,
+	}
+}
+
+// Handle lista los canales con ingesta de respaldo y la fuente activa. Las
+// IPs de los encoders salen como se guardan en los eventos.
+func (h *FailoverHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	if !authorizeAdmin(w, r, h.adminToken) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	states := h.failover.States()
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"total":    len(states),
+		"channels": states,
+	})
+}
@@ -3,24 +3,27 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 
+	"srs-backend/internal/logging"
 	"srs-backend/internal/models"
+	"srs-backend/internal/services"
 )
 
 type ForwardHandler struct {
 	targetURL string
+	failover  *services.FailoverService
 }
 
-func NewForwardHandler(targetURL string) *ForwardHandler {
-	return &ForwardHandler{targetURL: targetURL}
+func NewForwardHandler(targetURL string, failover *services.FailoverService) *ForwardHandler {
+	return &ForwardHandler{targetURL: targetURL, failover: failover}
 }
 
 func (h *ForwardHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	var cb models.SRSCallback
 	if err := json.NewDecoder(r.Body).Decode(&cb); err != nil {
-		log.Printf("❌ Error decode forward: %v", err)
+		slog.ErrorContext(r.Context(), "❌ Error decode forward", "error", err)
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{"code": 1})
 		return
@@ -30,7 +33,20 @@ func (h *ForwardHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	if h.targetURL == "" {
-		log.Printf("⚠️ TARGET_FORWARD_URL no configurado")
+		slog.WarnContext(r.Context(), "⚠️ TARGET_FORWARD_URL no configurado")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code": 0,
+			"data": map[string]interface{}{
+				"urls": []string{},
+			},
+		})
+		return
+	}
+
+	// Un respaldo oculto no se reenvía; el activo usa la ruta del primario
+	streamName, ok := h.failover.ForwardStream(cb.Stream)
+	if !ok {
+		slog.InfoContext(r.Context(), "🙈 Forward omitido para respaldo oculto")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"code": 0,
 			"data": map[string]interface{}{
@@ -43,10 +59,10 @@ func (h *ForwardHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	resp := map[string]interface{}{
 		"code": 0,
 		"data": map[string]interface{}{
-			"urls": []string{fmt.Sprintf("%s/%s/%s", h.targetURL, cb.App, cb.Stream)},
+			"urls": []string{fmt.Sprintf("%s/%s/%s", h.targetURL, cb.App, streamName)},
 		},
 	}
 
-	log.Printf("➡️ Forwarding a: %s/%s/%s", h.targetURL, cb.App, cb.Stream)
+	slog.InfoContext(r.Context(), "➡️ Forwarding", "target", h.targetURL, "forward_hash", logging.StreamHash(streamName))
 	json.NewEncoder(w).Encode(resp)
 }
\ No newline at end of file
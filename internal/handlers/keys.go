@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"srs-backend/internal/services"
+)
+
+type KeysHandler struct {
+	keys       *services.StreamKeyService
+	adminToken string
+}
+
+func NewKeysHandler(keys *services.StreamKeyService, adminToken string) *KeysHandler {
+	return &KeysHandler{
+		keys:       keys,
+		adminToken: adminToken,
+	}
+}
+
+// Handle atiende /api/v1/keys/{channel_id}[/generate|/rotate|/revoke]
+func (h *KeysHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	if !authorizeAdmin(w, r, h.adminToken) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/keys"), "/"), "/")
+	if len(parts) == 0 || parts[0] == "" || len(parts) > 2 {
+		writeJSONError(w, http.StatusNotFound, "ruta inválida, usar /api/v1/keys/{channel_id}/{acción}")
+		return
+	}
+	channelID := parts[0]
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	if action == "" {
+		if r.Method != http.MethodGet {
+			writeJSONError(w, http.StatusMethodNotAllowed, "método no permitido")
+			return
+		}
+		status, err := h.keys.Status(r.Context(), channelID)
+		if err != nil {
+			h.writeKeyError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(status)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "método no permitido")
+		return
+	}
+
+	var body struct {
+		GraceSeconds int    `json:"grace_seconds"`
+		StreamKey    string `json:"stream_key"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "JSON inválido")
+			return
+		}
+	}
+
+	var (
+		rotation *services.KeyRotation
+		err      error
+	)
+	switch action {
+	case "generate":
+		rotation, err = h.keys.Generate(r.Context(), channelID)
+	case "rotate":
+		if body.GraceSeconds < 0 {
+			writeJSONError(w, http.StatusBadRequest, "grace_seconds no puede ser negativo")
+			return
+		}
+		rotation, err = h.keys.Rotate(r.Context(), channelID, time.Duration(body.GraceSeconds)*time.Second)
+	case "revoke":
+		rotation, err = h.keys.Revoke(r.Context(), channelID, body.StreamKey)
+	default:
+		writeJSONError(w, http.StatusNotFound, "acción no soportada: "+action)
+		return
+	}
+	if err != nil {
+		h.writeKeyError(w, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "🔑 Clave actualizada", "action", action, "channel_id", channelID)
+	json.NewEncoder(w).Encode(rotation)
+}
+
+func (h *KeysHandler) writeKeyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrChannelNotFound), errors.Is(err, services.ErrKeyNotFound):
+		writeJSONError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrKeyExists):
+		writeJSONError(w, http.StatusConflict, err.Error())
+	default:
+		slog.Error("❌ Error gestionando claves", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"srs-backend/internal/logging"
+)
+
+const (
+	defaultStreamDebug = 15 * time.Minute
+	maxStreamDebug     = 24 * time.Hour
+)
+
+type LoggingHandler struct {
+	adminToken string
+}
+
+func NewLoggingHandler(adminToken string) *LoggingHandler {
+	return &LoggingHandler{adminToken: adminToken}
+}
+
+// Handle atiende /api/v1/logging: GET muestra el estado, POST cambia el nivel
+// global o activa debug para un stream, DELETE quita la excepción de un stream.
+func (h *LoggingHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	if !authorizeAdmin(w, r, h.adminToken) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if !h.update(w, r) {
+			return
+		}
+	case http.MethodDelete:
+		hash := r.URL.Query().Get("stream_hash")
+		if hash == "" {
+			writeJSONError(w, http.StatusBadRequest, "stream_hash requerido")
+			return
+		}
+		logging.DisableStreamDebug(hash)
+		slog.InfoContext(r.Context(), "🔇 Debug de stream desactivado", logging.KeyStreamHash, hash)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "método no permitido")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"level":   logging.Level(),
+		"format":  logging.Format(),
+		"streams": logging.StreamDebugs(),
+	})
+}
+
+func (h *LoggingHandler) update(w http.ResponseWriter, r *http.Request) bool {
+	var body struct {
+		Level      string `json:"level"`
+		StreamKey  string `json:"stream_key"`
+		StreamHash string `json:"stream_hash"`
+		Duration   string `json:"duration"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "JSON inválido")
+		return false
+	}
+
+	if body.Level != "" {
+		if err := logging.SetLevel(body.Level); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return false
+		}
+		slog.InfoContext(r.Context(), "🔊 Nivel de log actualizado", "level", logging.Level())
+	}
+
+	hash := body.StreamHash
+	if body.StreamKey != "" {
+		hash = logging.StreamHash(body.StreamKey)
+	}
+	if hash == "" {
+		if body.Level == "" {
+			writeJSONError(w, http.StatusBadRequest, "indicar level, stream_key o stream_hash")
+			return false
+		}
+		return true
+	}
+
+	d := defaultStreamDebug
+	if body.Duration != "" {
+		parsed, err := time.ParseDuration(body.Duration)
+		if err != nil || parsed <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "duration inválida")
+			return false
+		}
+		d = min(parsed, maxStreamDebug)
+	}
+	until := logging.EnableStreamDebug(hash, d)
+	slog.InfoContext(r.Context(), "🔊 Debug de stream activado", logging.KeyStreamHash, hash, "until", until.Format(time.RFC3339))
+	return true
+}
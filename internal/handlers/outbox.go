@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"srs-backend/internal/storage"
+)
+
+type OutboxHandler struct {
+	outbox     *storage.Outbox
+	adminToken string
+}
+
+func NewOutboxHandler(outbox *storage.Outbox, adminToken string) *OutboxHandler {
+	return &OutboxHandler{
+		outbox:     outbox,
+		adminToken: adminToken,
+	}
+}
+
+// Handle expone profundidad y antigüedad de las escrituras pendientes
+func (h *OutboxHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	if !authorizeAdmin(w, r, h.adminToken) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	json.NewEncoder(w).Encode(h.outbox.Status())
+}
@@ -2,7 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 
 	"srs-backend/internal/models"
@@ -59,5 +59,5 @@ func (h *PerformanceHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	json.NewEncoder(w).Encode(perf)
-	log.Printf("📊 Performance solicitado: CPU=%.1f%%, Mem=%dMB, Conn=%d", cpuPercent, memoryMB, totalConnections)
+	slog.DebugContext(r.Context(), "📊 Performance solicitado", "cpu_percent", cpuPercent, "memory_mb", memoryMB, "connections", totalConnections)
 }
\ No newline at end of file
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"srs-backend/internal/models"
+	"srs-backend/internal/services"
+)
+
+type PoliciesHandler struct {
+	policies   *services.ChannelPolicyService
+	adminToken string
+}
+
+func NewPoliciesHandler(policies *services.ChannelPolicyService, adminToken string) *PoliciesHandler {
+	return &PoliciesHandler{
+		policies:   policies,
+		adminToken: adminToken,
+	}
+}
+
+// Handle atiende /api/v1/policies/{channel_id}: GET muestra las
+// restricciones geográficas y de dominio del canal, PUT las reemplaza y
+// DELETE las quita.
+func (h *PoliciesHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	if !authorizeAdmin(w, r, h.adminToken) {
+		return
+	}
+
+	channelID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/policies"), "/")
+	if channelID == "" || strings.Contains(channelID, "/") {
+		writeJSONError(w, http.StatusNotFound, "ruta inválida, usar /api/v1/policies/{channel_id}")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		policy, err := h.policies.Get(r.Context(), channelID)
+		if err != nil {
+			h.writePolicyError(w, err)
+			return
+		}
+		if policy == nil {
+			writeJSONError(w, http.StatusNotFound, "el canal no tiene restricciones")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(policy)
+
+	case http.MethodPut:
+		var policy models.ChannelPolicy
+		if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "JSON inválido")
+			return
+		}
+		policy.ChannelID = channelID
+		saved, err := h.policies.Set(r.Context(), policy)
+		if err != nil {
+			h.writePolicyError(w, err)
+			return
+		}
+		slog.InfoContext(r.Context(), "🌍 Restricciones del canal actualizadas", "channel_id", channelID,
+			"allowed", saved.AllowedCountries, "blocked", saved.BlockedCountries, "apply_to_publish", saved.ApplyToPublish,
+			"allowed_domains", saved.AllowedDomains, "empty_referrer", saved.EmptyReferrer)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(saved)
+
+	case http.MethodDelete:
+		if err := h.policies.Delete(r.Context(), channelID); err != nil {
+			h.writePolicyError(w, err)
+			return
+		}
+		slog.InfoContext(r.Context(), "🌍 Restricciones del canal eliminadas", "channel_id", channelID)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "método no permitido")
+	}
+}
+
+func (h *PoliciesHandler) writePolicyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrChannelNotFound):
+		writeJSONError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrInvalidPolicy):
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+	default:
+		slog.Error("❌ Error gestionando restricciones", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"srs-backend/internal/services"
+)
+
+type PrivacyHandler struct {
+	privacy    *services.PrivacyService
+	adminToken string
+}
+
+func NewPrivacyHandler(privacy *services.PrivacyService, adminToken string) *PrivacyHandler {
+	return &PrivacyHandler{
+		privacy:    privacy,
+		adminToken: adminToken,
+	}
+}
+
+// Handle atiende POST /api/v1/privacy/erase con {"ip"} o {"channel_id"} y
+// mode delete (por defecto) o anonymize
+func (h *PrivacyHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	if !authorizeAdmin(w, r, h.adminToken) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "método no permitido")
+		return
+	}
+
+	var req services.ErasureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "JSON inválido")
+		return
+	}
+
+	result, err := h.privacy.Erase(r.Context(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidErasure):
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrChannelNotFound):
+			writeJSONError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrErasurePending):
+			writeJSONError(w, http.StatusServiceUnavailable, err.Error())
+		default:
+			slog.ErrorContext(r.Context(), "❌ Error aplicando supresión de datos", "error", err)
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -1,68 +1,160 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"srs-backend/internal/models"
 	"srs-backend/internal/services"
+	"srs-backend/internal/storage"
+	"srs-backend/pkg/utils"
 )
 
 type PublishHandler struct {
-	supabase  *services.SupabaseService
+	store     *storage.Store
 	thumbnail *services.ThumbnailService
 	// Cambio: guardar IP del servidor para fallback (Firma: Cursor)
 	serverIP  string
+	guard     *services.PublishGuard
+	keys       *services.StreamKeyService
+	publishers *services.PublisherTracker
+	failover   *services.FailoverService
+	tenants    *services.TenantService
+	policies   *services.ChannelPolicyService
+	sessions   *services.SessionTracker
+	events     *services.EventBus
+	webhooks   *services.WebhookService
 }
 
-func NewPublishHandler(supabase *services.SupabaseService, thumbnail *services.ThumbnailService, serverIP string) *PublishHandler {
+func NewPublishHandler(store *storage.Store, thumbnail *services.ThumbnailService, guard *services.PublishGuard, keys *services.StreamKeyService, publishers *services.PublisherTracker, failover *services.FailoverService, tenants *services.TenantService, policies *services.ChannelPolicyService, sessions *services.SessionTracker, events *services.EventBus, webhooks *services.WebhookService, serverIP string) *PublishHandler {
 	return &PublishHandler{
-		supabase:  supabase,
-		thumbnail: thumbnail,
-		serverIP:  serverIP,
+		store:      store,
+		thumbnail:  thumbnail,
+		serverIP:   serverIP,
+		guard:      guard,
+		keys:       keys,
+		publishers: publishers,
+		failover:   failover,
+		tenants:    tenants,
+		policies:   policies,
+		sessions:   sessions,
+		events:     events,
+		webhooks:   webhooks,
 	}
 }
 
 func (h *PublishHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	var cb models.SRSCallback
 	if err := json.NewDecoder(r.Body).Decode(&cb); err != nil {
-		log.Printf("❌ Error decode: %v", err)
+		slog.ErrorContext(r.Context(), "❌ Error decode", "error", err)
 		w.Write([]byte("1"))
 		return
 	}
 
-	log.Printf("📢 Publish detectado: App=%s, Stream=%s", cb.App, cb.Stream)
-	w.Write([]byte("0"))
+	slog.InfoContext(r.Context(), "📢 Publish detectado", "ip", cb.IP)
 
-	go h.processPublish(cb)
-}
+	// Rechazar fuentes bloqueadas sin consultar la base de datos
+	if locked, until := h.guard.IsLocked(cb.IP); locked {
+		slog.WarnContext(r.Context(), "🚫 Publish rechazado: IP bloqueada", "ip", cb.IP, "until", until.Format(time.RFC3339))
+		w.Write([]byte("1"))
+		return
+	}
 
-func (h *PublishHandler) processPublish(cb models.SRSCallback) {
-	client := h.supabase.GetClient()
+	ctx := r.Context()
+	channelID, err := h.keys.Resolve(ctx, cb.Stream)
+	if err != nil {
+		// Si Supabase no responde se acepta la publicación para no cortar la
+		// ingesta; processPublish reintenta la búsqueda.
+		slog.WarnContext(ctx, "⚠️ Error validando clave, se acepta publish", "error", err)
+	} else if channelID == "" {
+		slog.WarnContext(ctx, "⚠️ Canal no encontrado en Supabase para la clave")
+		h.guard.RecordFailure(ctx, cb.IP, cb.App)
+		w.Write([]byte("1"))
+		return
+	} else {
+		h.guard.RecordSuccess(cb.IP)
+		if !h.tenants.AllowPublish(ctx, channelID) || !h.policies.AllowPublish(ctx, channelID, cb.IP, cb.ClientID) {
+			w.Write([]byte("1"))
+			return
+		}
+	}
 
-	var results []struct{ ID string `json:"id"` }
-	_, err := client.From("channels_channel").Select("id", "1", false).Eq("stream_id", cb.Stream).ExecuteTo(&results)
+	publisher := services.Publisher{
+		StreamKey: cb.Stream,
+		App:       cb.App,
+		ChannelID: channelID,
+		ClientID:  cb.ClientID,
+		IP:        cb.IP,
+	}
+	if !h.publishers.Admit(ctx, publisher) {
+		w.Write([]byte("1"))
+		return
+	}
 
-	if err != nil || len(results) == 0 {
-		log.Printf("⚠️ Canal no encontrado en Supabase para stream_id: %s", cb.Stream)
+	// Debe resolverse antes de responder: SRS pide on_forward justo después
+	visible := true
+	if channelID != "" {
+		visible = h.failover.OnPublish(ctx, channelID, services.IngestSource{
+			StreamKey: cb.Stream,
+			App:       cb.App,
+			ClientID:  cb.ClientID,
+			IP:        cb.IP,
+		})
+	}
+
+	w.Write([]byte("0"))
+
+	// Un respaldo oculto también es una sesión de publicación
+	go h.openSession(context.WithoutCancel(ctx), cb, channelID)
+
+	if !visible {
 		return
 	}
+	// El trabajo en segundo plano sigue en la traza del hook
+	go h.processPublish(context.WithoutCancel(ctx), cb, channelID)
+}
 
-	channelID := results[0].ID
-	fileName := h.supabase.GetPersistentHash(channelID) + ".jpg"
-	log.Printf("✅ Canal encontrado (ID: %s). Generando thumbnail: %s", channelID, fileName)
+func (h *PublishHandler) openSession(ctx context.Context, cb models.SRSCallback, channelID string) {
+	session := services.Session{
+		ClientID:    cb.ClientID,
+		ClientIP:    cb.IP,
+		ClientType:  services.ClientTypePublish,
+		StreamID:    cb.StreamID,
+		StreamName:  cb.Stream,
+		App:         cb.App,
+		ChannelID:   channelID,
+		ConnectedAt: time.Now().UTC(),
+	}
+	if channelID != "" {
+		if tenant, err := h.tenants.ForChannel(ctx, channelID); err == nil {
+			session.OrganizationID = tenant.OrganizationID
+		}
+	}
+	if err := h.sessions.Open(ctx, session); err != nil {
+		slog.ErrorContext(ctx, "❌ Error guardando sesión de publicación", "error", err)
+	}
+}
 
-	updateData := map[string]interface{}{
-		"is_on_live":  true,
-		"last_status": "online",
-		"cover":       fileName,
-		"modified":    time.Now().Format(time.RFC3339),
+func (h *PublishHandler) processPublish(ctx context.Context, cb models.SRSCallback, channelID string) {
+	if channelID == "" {
+		id, err := h.keys.Resolve(ctx, cb.Stream)
+		if err != nil || id == "" {
+			slog.WarnContext(ctx, "⚠️ Canal no encontrado en Supabase para la clave")
+			return
+		}
+		channelID = id
 	}
 
-	client.From("channels_channel").Update(updateData, "", "").Eq("id", channelID).Execute()
+	fileName := utils.GetMD5Hash(channelID) + ".jpg"
+	slog.InfoContext(ctx, "✅ Canal encontrado, generando thumbnail", "channel_id", channelID, "thumbnail", fileName)
+
+	h.store.Channels.SetLive(ctx, channelID, fileName)
+	h.events.Publish(services.EventTypePublish, cb.App, channelID, map[string]interface{}{"thumbnail": fileName})
+	h.webhooks.Emit(ctx, models.WebhookEventChannelLive, channelID, map[string]interface{}{"app": cb.App})
 
 	// Cambio: usar vhost real del callback para evitar fallos de thumbnail (Firma: Cursor)
 	vhost := cb.Vhost
@@ -73,5 +165,5 @@ func (h *PublishHandler) processPublish(cb models.SRSCallback) {
 	rtmpURL := fmt.Sprintf("rtmp://srs:1935/%s/%s?vhost=%s", cb.App, cb.Stream, vhost)
 	outputPath := "/app/thumbnails/" + fileName
 
-	h.thumbnail.StartCapture(cb.Stream, cb.App, fileName, rtmpURL, outputPath)
+	h.thumbnail.StartCapture(ctx, cb.Stream, cb.App, channelID, fileName, rtmpURL, outputPath)
 }
\ No newline at end of file
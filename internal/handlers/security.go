@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"srs-backend/internal/services"
+)
+
+type SecurityHandler struct {
+	guard      *services.PublishGuard
+	adminToken string
+}
+
+func NewSecurityHandler(guard *services.PublishGuard, adminToken string) *SecurityHandler {
+	return &SecurityHandler{
+		guard:      guard,
+		adminToken: adminToken,
+	}
+}
+
+// HandleLockouts lista las IPs y subredes bloqueadas por fuerza bruta
+func (h *SecurityHandler) HandleLockouts(w http.ResponseWriter, r *http.Request) {
+	if !authorizeAdmin(w, r, h.adminToken) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	lockouts := h.guard.Lockouts()
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"total":    len(lockouts),
+		"lockouts": lockouts,
+	})
+}
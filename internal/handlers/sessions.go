@@ -1,10 +1,10 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
-	"sync"
 	"time"
 
 	"srs-backend/internal/models"
@@ -12,121 +12,94 @@ import (
 )
 
 type SessionsHandler struct {
-	supabase *services.SupabaseService
-	serverID string
-	serverIP string
-	mu       sync.Mutex
-	// Cambio: map de sesiones activas por client_id (Firma: Cursor)
-	activeSessions map[string]time.Time
+	tenants  *services.TenantService
+	policies *services.ChannelPolicyService
+	// Cambio: sesiones activas por client_id (Firma: Cursor)
+	sessions *services.SessionTracker
+	webhooks *services.WebhookService
 }
 
 // Cambio: handler para on_play/on_stop de SRS (Firma: Cursor)
-func NewSessionsHandler(supabase *services.SupabaseService, serverID, serverIP string) *SessionsHandler {
+func NewSessionsHandler(tenants *services.TenantService, policies *services.ChannelPolicyService, sessions *services.SessionTracker, webhooks *services.WebhookService) *SessionsHandler {
 	return &SessionsHandler{
-		supabase:       supabase,
-		serverID:       serverID,
-		serverIP:       serverIP,
-		activeSessions: make(map[string]time.Time),
+		tenants:  tenants,
+		policies: policies,
+		sessions: sessions,
+		webhooks: webhooks,
 	}
 }
 
 func (h *SessionsHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	var cb models.SRSCallback
 	if err := json.NewDecoder(r.Body).Decode(&cb); err != nil {
-		log.Printf("❌ Error decode sessions: %v", err)
+		slog.ErrorContext(r.Context(), "❌ Error decode sessions", "error", err)
 		w.Write([]byte("1"))
 		return
 	}
 
 	switch cb.Action {
 	case "on_play":
+		tenant, ok := h.tenants.AllowPlay(r.Context(), cb.Stream)
+		if !ok || !h.policies.AllowPlay(r.Context(), tenant.ChannelID, cb.IP, cb.ClientID, cb.PageURL) {
+			w.Write([]byte("1"))
+			return
+		}
 		w.Write([]byte("0"))
-		go h.processPlay(cb)
+		go h.processPlay(context.WithoutCancel(r.Context()), cb, tenant)
 		return
 	case "on_stop":
 		w.Write([]byte("0"))
-		go h.processStop(cb)
+		go h.processStop(context.WithoutCancel(r.Context()), cb)
 		return
 	default:
-		log.Printf("⚠️ Acción SRS no soportada en sessions: %s", cb.Action)
+		slog.WarnContext(r.Context(), "⚠️ Acción SRS no soportada en sessions", "action", cb.Action)
 		w.Write([]byte("0"))
 		return
 	}
 }
 
-func (h *SessionsHandler) processPlay(cb models.SRSCallback) {
-	client := h.supabase.GetClient()
-	connectedAt := time.Now().UTC()
-
-	insertData := map[string]interface{}{
-		"server_id":    h.serverID,
-		"server_ip":    h.serverIP,
-		// Cambio: persistir client_id y stream_id si están presentes (Firma: Cursor)
-		"client_id":    cb.ClientID,
-		"client_ip":    cb.IP,
-		"client_type":  "play",
-		"stream_id":    cb.StreamID,
-		"stream_name":  cb.Stream,
-		"app":          cb.App,
-		"connected_at": connectedAt,
-	}
-
+func (h *SessionsHandler) processPlay(ctx context.Context, cb models.SRSCallback, tenant services.Tenant) {
 	// Cambio: insertar sesion de cliente on_play (Firma: Cursor)
-	_, _, err := client.From("server_ingest_client_connections").Insert(insertData, false, "", "", "").Execute()
+	err := h.sessions.Open(ctx, services.Session{
+		// Cambio: persistir client_id y stream_id si están presentes (Firma: Cursor)
+		ClientID:       cb.ClientID,
+		ClientIP:       cb.IP,
+		ClientType:     services.ClientTypePlay,
+		StreamID:       cb.StreamID,
+		StreamName:     cb.Stream,
+		App:            cb.App,
+		ChannelID:      tenant.ChannelID,
+		OrganizationID: tenant.OrganizationID,
+		ReferrerDomain: services.ReferrerDomain(cb.PageURL),
+		ConnectedAt:    time.Now().UTC(),
+	})
 	if err != nil {
-		log.Printf("❌ Error guardando server_ingest_client_connections: %v", err)
-		return
-	}
-
-	if cb.ClientID != "" {
-		h.mu.Lock()
-		h.activeSessions[cb.ClientID] = connectedAt
-		h.mu.Unlock()
+		slog.ErrorContext(ctx, "❌ Error guardando server_ingest_client_connections", "error", err)
 	}
+	h.webhooks.Emit(ctx, models.WebhookEventSessionStarted, tenant.ChannelID, map[string]interface{}{
+		"client_id":       cb.ClientID,
+		"app":             cb.App,
+		"referrer_domain": services.ReferrerDomain(cb.PageURL),
+	})
 }
 
-func (h *SessionsHandler) processStop(cb models.SRSCallback) {
-	client := h.supabase.GetClient()
-	disconnectedAt := time.Now().UTC()
-
-	var connectedAt time.Time
-	var ok bool
-
-	if cb.ClientID != "" {
-		h.mu.Lock()
-		connectedAt, ok = h.activeSessions[cb.ClientID]
-		delete(h.activeSessions, cb.ClientID)
-		h.mu.Unlock()
-	}
-
-	durationSeconds := 0
-	if ok {
-		durationSeconds = int(disconnectedAt.Sub(connectedAt).Seconds())
-	}
-
-	updateData := map[string]interface{}{
-		"disconnected_at":  disconnectedAt,
-		"duration_seconds": durationSeconds,
-	}
-
+func (h *SessionsHandler) processStop(ctx context.Context, cb models.SRSCallback) {
 	// Cambio: cerrar sesion on_stop (Firma: Cursor)
-	query := client.From("server_ingest_client_connections").
-		Update(updateData, "", "").
-		Eq("server_id", h.serverID).
-		Eq("app", cb.App).
-		Eq("stream_name", cb.Stream).
-		Eq("client_ip", cb.IP).
-		Eq("client_type", "play")
-
-	if cb.ClientID != "" {
-		// Cambio: cerrar sesión por client_id cuando exista (Firma: Cursor)
-		query = query.Eq("client_id", cb.ClientID)
-	} else if ok {
-		// Cambio: Supabase Eq requiere string para timestamp (Firma: Cursor)
-		query = query.Eq("connected_at", connectedAt.Format(time.RFC3339))
+	err := h.sessions.Close(ctx, services.Session{
+		ClientID:   cb.ClientID,
+		ClientIP:   cb.IP,
+		ClientType: services.ClientTypePlay,
+		StreamName: cb.Stream,
+		App:        cb.App,
+	}, services.DisconnectReasonStop)
+	if err != nil {
+		slog.ErrorContext(ctx, "❌ Error cerrando sesion server_ingest_client_connections", "error", err)
 	}
-
-	if _, _, err := query.Execute(); err != nil {
-		log.Printf("❌ Error cerrando sesion server_ingest_client_connections: %v", err)
+	// on_stop no trae el canal; la clave puede haber rotado durante la sesión
+	if tenant, err := h.tenants.ForStream(ctx, cb.Stream); err == nil {
+		h.webhooks.Emit(ctx, models.WebhookEventSessionEnded, tenant.ChannelID, map[string]interface{}{
+			"client_id": cb.ClientID,
+			"app":       cb.App,
+		})
 	}
 }
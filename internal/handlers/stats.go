@@ -2,7 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -22,7 +22,7 @@ func (h *StatsHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	// 1. Obtener streams del SRS
 	resp, err := http.Get("http://srs:1985/api/v1/streams/")
 	if err != nil {
-		log.Printf("❌ Error obteniendo streams: %v", err)
+		slog.ErrorContext(r.Context(), "❌ Error obteniendo streams", "error", err)
 		json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
 		return
 	}
@@ -117,5 +117,5 @@ func (h *StatsHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	json.NewEncoder(w).Encode(stats)
-	log.Printf("📊 Stats solicitadas: %d streams, %d conexiones", len(streams), totalConnections)
+	slog.DebugContext(r.Context(), "📊 Stats solicitadas", "streams", len(streams), "connections", totalConnections)
 }
\ No newline at end of file
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"srs-backend/internal/models"
+	"srs-backend/internal/services"
+)
+
+const (
+	// Un cliente que no responde al ping en statsPongWait se desconecta
+	statsPongWait   = 60 * time.Second
+	statsPingPeriod = statsPongWait * 9 / 10
+	statsWriteWait  = 10 * time.Second
+	// Los mensajes del cliente son suscripciones pequeñas
+	statsMaxMessage = 16 << 10
+)
+
+// Subprotocolo del feed. El WebSocket del navegador no envía cabeceras: el
+// ticket de /stream-tickets va en ?ticket= o como segundo subprotocolo,
+// "ticket.<ticket>", junto a statsSubprotocol, que es el que se acepta.
+const (
+	statsSubprotocol = "srs-stats"
+	ticketProtocol   = "ticket."
+)
+
+// statsRequest es un mensaje del cliente: {"type":"subscribe","streams":[...],
+// "channels":[...]} o {"type":"snapshot"}
+type statsRequest struct {
+	Type string `json:"type"`
+	services.StatsFilter
+}
+
+type StatsFeedHandler struct {
+	feed         *services.StatsFeed
+	fullInterval time.Duration
+	adminToken   string
+	upgrader     websocket.Upgrader
+}
+
+// NewStatsFeedHandler acepta conexiones sin Origin (clientes que no son un
+// navegador), del propio host o de allowedOrigins
+func NewStatsFeedHandler(feed *services.StatsFeed, fullInterval time.Duration, allowedOrigins []string, adminToken string) *StatsFeedHandler {
+	return &StatsFeedHandler{
+		feed:         feed,
+		fullInterval: fullInterval,
+		adminToken:   adminToken,
+		upgrader: websocket.Upgrader{
+			Subprotocols: []string{statsSubprotocol},
+			CheckOrigin: func(r *http.Request) bool {
+				return originAllowed(r, allowedOrigins)
+			},
+		},
+	}
+}
+
+// originAllowed compara el Origin completo (esquema, host y puerto)
+func originAllowed(r *http.Request, allowedOrigins []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range allowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// statsTicket toma el ticket de la URL o del subprotocolo "ticket.<ticket>"
+func statsTicket(r *http.Request) string {
+	if ticket := r.URL.Query().Get("ticket"); ticket != "" {
+		return ticket
+	}
+	for _, protocol := range websocket.Subprotocols(r) {
+		if ticket, ok := strings.CutPrefix(protocol, ticketProtocol); ok {
+			return ticket
+		}
+	}
+	return ""
+}
+
+// Handle atiende GET /api/v1/stats/ws: snapshot completo al conectar, al
+// suscribirse y cada fullInterval; diffs en cada ciclo del recolector
+func (h *StatsFeedHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	if !authorizeStream(w, r, h.adminToken, statsTicket(r)) {
+		return
+	}
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade ya respondió con el error
+		slog.WarnContext(r.Context(), "⚠️ Error abriendo WebSocket de estadísticas", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	notify := h.feed.Subscribe()
+	defer h.feed.Unsubscribe(notify)
+
+	ctx := r.Context()
+	slog.InfoContext(ctx, "🛰️ Cliente de estadísticas conectado", "remote", r.RemoteAddr)
+	defer slog.InfoContext(ctx, "🛰️ Cliente de estadísticas desconectado", "remote", r.RemoteAddr)
+
+	requests := make(chan statsRequest)
+	done := make(chan struct{})
+	defer close(done)
+	go readStatsRequests(conn, requests, done)
+
+	var (
+		filter   services.StatsFilter
+		sent     models.LiveSnapshot
+		lastFull time.Time
+	)
+	send := func(msg interface{}) error {
+		conn.SetWriteDeadline(time.Now().Add(statsWriteWait))
+		return conn.WriteJSON(msg)
+	}
+	// sendLatest envía el último snapshot completo o, si no toca, el diff
+	// contra lo enviado a esta conexión
+	sendLatest := func(full bool) error {
+		snapshot, ok := h.feed.Latest()
+		if !ok {
+			return nil
+		}
+		filtered := filter.Apply(snapshot)
+		if full || time.Since(lastFull) >= h.fullInterval {
+			sent, lastFull = filtered, time.Now()
+			return send(services.SnapshotMessage(filtered))
+		}
+		msg, changed := services.DiffStats(sent, filtered)
+		sent = filtered
+		if !changed {
+			return nil
+		}
+		return send(msg)
+	}
+
+	if sendLatest(true) != nil {
+		return
+	}
+	ping := time.NewTicker(statsPingPeriod)
+	defer ping.Stop()
+	for {
+		var err error
+		select {
+		case _, ok := <-notify:
+			if !ok {
+				// Apagado del backend
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "apagando"), time.Now().Add(statsWriteWait))
+				return
+			}
+			err = sendLatest(false)
+		case req, ok := <-requests:
+			if !ok {
+				return
+			}
+			switch req.Type {
+			case "subscribe":
+				filter = req.StatsFilter
+				err = sendLatest(true)
+			case "snapshot":
+				err = sendLatest(true)
+			default:
+				err = send(map[string]string{"type": "error", "error": fmt.Sprintf("type %q inválido, usar subscribe o snapshot", req.Type)})
+			}
+		case <-ping.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(statsWriteWait))
+		}
+		if err != nil {
+			slog.DebugContext(ctx, "🛰️ Error escribiendo en WebSocket de estadísticas", "error", err)
+			return
+		}
+	}
+}
+
+// readStatsRequests lee los mensajes del cliente hasta que la conexión se
+// cierra o Handle termina; un mensaje que no es JSON válido cierra la conexión
+func readStatsRequests(conn *websocket.Conn, requests chan<- statsRequest, done <-chan struct{}) {
+	defer close(requests)
+	conn.SetReadLimit(statsMaxMessage)
+	conn.SetReadDeadline(time.Now().Add(statsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(statsPongWait))
+	})
+	for {
+		var req statsRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+		// Un mensaje del cliente también demuestra que sigue vivo
+		conn.SetReadDeadline(time.Now().Add(statsPongWait))
+		select {
+		case requests <- req:
+		case <-done:
+			return
+		}
+	}
+}
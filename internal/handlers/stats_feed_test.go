@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"srs-backend/internal/models"
+	"srs-backend/internal/services"
+)
+
+func TestOriginAllowed(t *testing.T) {
+	allowed := []string{"https://dashboard.example.com", "http://localhost:5173/"}
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"https://dashboard.example.com", true},
+		{"https://Dashboard.Example.com", true},
+		{"http://localhost:5173", true},
+		{"http://backend.example.com:3000", true},
+		{"http://dashboard.example.com", false},
+		{"https://dashboard.example.com.evil.io", false},
+		{"https://evil.io", false},
+		{"http://localhost:3000", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "http://backend.example.com:3000/api/v1/stats/ws", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := originAllowed(r, allowed); got != tt.want {
+			t.Errorf("originAllowed(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestStatsFeedAuth(t *testing.T) {
+	feed := services.NewStatsFeed()
+	feed.Publish(models.LiveSnapshot{Time: time.Now(), Server: models.LiveServer{ServerID: "srv-test"}})
+	defer feed.Close()
+	h := NewStatsFeedHandler(feed, time.Minute, []string{"https://dashboard.example.com"}, "secreto")
+	server := httptest.NewServer(http.HandlerFunc(h.Handle))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	ticket := signStreamTicket("secreto", time.Now().Add(streamTicketTTL))
+
+	tests := []struct {
+		name       string
+		query      string
+		header     http.Header
+		protocols  []string
+		wantStatus int
+	}{
+		{"ticket en subprotocolo", "", http.Header{"Origin": {"https://dashboard.example.com"}},
+			[]string{statsSubprotocol, ticketProtocol + ticket}, http.StatusSwitchingProtocols},
+		{"ticket en la URL", "?ticket=" + ticket, http.Header{"Origin": {"https://dashboard.example.com"}},
+			nil, http.StatusSwitchingProtocols},
+		{"token en cabecera sin Origin", "", http.Header{"X-Admin-Token": {"secreto"}},
+			nil, http.StatusSwitchingProtocols},
+		{"origen no permitido", "?ticket=" + ticket, http.Header{"Origin": {"https://evil.io"}},
+			nil, http.StatusForbidden},
+		{"ticket inválido", "", http.Header{"Origin": {"https://dashboard.example.com"}},
+			[]string{statsSubprotocol, ticketProtocol + "1.abc"}, http.StatusUnauthorized},
+		{"sin credenciales", "", nil, nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialer := websocket.Dialer{Subprotocols: tt.protocols}
+			conn, resp, err := dialer.Dial(wsURL+tt.query, tt.header)
+			if resp == nil {
+				t.Fatalf("sin respuesta: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("estado %d, want %d (%v)", resp.StatusCode, tt.wantStatus, err)
+			}
+			if conn == nil {
+				return
+			}
+			defer conn.Close()
+			if len(tt.protocols) > 0 && conn.Subprotocol() != statsSubprotocol {
+				t.Errorf("subprotocolo %q, want %q", conn.Subprotocol(), statsSubprotocol)
+			}
+			var msg services.StatsMessage
+			if err := conn.ReadJSON(&msg); err != nil || msg.Type != services.StatsMessageSnapshot {
+				t.Errorf("primer mensaje %q (%v), want snapshot", msg.Type, err)
+			}
+		})
+	}
+}
@@ -2,7 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -54,5 +54,5 @@ func (h *SummaryHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	json.NewEncoder(w).Encode(summary)
-	log.Printf("📊 Summary solicitado: %d publishers, %d players", publishers, players)
+	slog.DebugContext(r.Context(), "📊 Summary solicitado", "publishers", publishers, "players", players)
 }
\ No newline at end of file
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Vigencia de un ticket: solo se comprueba al abrir la conexión
+const streamTicketTTL = 60 * time.Second
+
+// StreamTicketsHandler emite tickets de corta duración para los streams que
+// el navegador abre sin cabeceras (EventSource y WebSocket): el ticket va en
+// la URL en lugar del ADMIN_TOKEN.
+type StreamTicketsHandler struct {
+	adminToken string
+}
+
+func NewStreamTicketsHandler(adminToken string) *StreamTicketsHandler {
+	return &StreamTicketsHandler{adminToken: adminToken}
+}
+
+// Handle atiende POST /api/v1/stream-tickets
+func (h *StreamTicketsHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	if !authorizeAdmin(w, r, h.adminToken) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "método no permitido")
+		return
+	}
+
+	expiresAt := time.Now().Add(streamTicketTTL).Truncate(time.Second)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"ticket":     signStreamTicket(h.adminToken, expiresAt),
+		"expires_at": expiresAt.UTC(),
+	})
+}
+
+// signStreamTicket firma el vencimiento con el ADMIN_TOKEN: no hace falta
+// guardar los tickets y cambiar el token los invalida todos
+func signStreamTicket(adminToken string, expiresAt time.Time) string {
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	return expires + "." + streamTicketMAC(adminToken, expires)
+}
+
+func streamTicketMAC(adminToken, expires string) string {
+	mac := hmac.New(sha256.New, []byte(adminToken))
+	mac.Write([]byte("stream-ticket:" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func validStreamTicket(adminToken, ticket string, now time.Time) bool {
+	expires, signature, ok := strings.Cut(ticket, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || !now.Before(time.Unix(unix, 0)) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(signature), []byte(streamTicketMAC(adminToken, expires))) == 1
+}
+
+// authorizeStream acepta un ticket de /stream-tickets o, sin él, el token de
+// administración como authorizeAdmin
+func authorizeStream(w http.ResponseWriter, r *http.Request, adminToken, ticket string) bool {
+	if ticket == "" || adminToken == "" {
+		return authorizeAdmin(w, r, adminToken)
+	}
+	if !validStreamTicket(adminToken, ticket, time.Now()) {
+		writeJSONError(w, http.StatusUnauthorized, "ticket inválido o vencido")
+		return false
+	}
+	return true
+}
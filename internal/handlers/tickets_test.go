@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestValidStreamTicket(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	ticket := signStreamTicket("secreto", now.Add(streamTicketTTL))
+
+	tests := []struct {
+		name   string
+		token  string
+		ticket string
+		at     time.Time
+		want   bool
+	}{
+		{"vigente", "secreto", ticket, now, true},
+		{"al vencer", "secreto", ticket, now.Add(streamTicketTTL), false},
+		{"vencido", "secreto", ticket, now.Add(2 * streamTicketTTL), false},
+		{"otro token", "otro", ticket, now, false},
+		{"vencimiento alterado", "secreto", "9999999999" + ticket[len("1767268860"):], now, false},
+		{"firma alterada", "secreto", ticket[:len(ticket)-1] + "0", now, ticket[len(ticket)-1] == '0'},
+		{"sin firma", "secreto", "1767268860", now, false},
+		{"vacío", "secreto", "", now, false},
+	}
+	for _, tt := range tests {
+		if got := validStreamTicket(tt.token, tt.ticket, tt.at); got != tt.want {
+			t.Errorf("%s: validStreamTicket = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAuthorizeStream(t *testing.T) {
+	valid := signStreamTicket("secreto", time.Now().Add(streamTicketTTL))
+	expired := signStreamTicket("secreto", time.Now().Add(-time.Second))
+
+	tests := []struct {
+		name       string
+		adminToken string
+		header     string
+		ticket     string
+		wantStatus int
+	}{
+		{"ticket vigente", "secreto", "", valid, http.StatusOK},
+		{"ticket vencido", "secreto", "", expired, http.StatusUnauthorized},
+		{"token en cabecera", "secreto", "Bearer secreto", "", http.StatusOK},
+		{"sin credenciales", "secreto", "", "", http.StatusUnauthorized},
+		{"sin ADMIN_TOKEN", "", "", valid, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/events/stream", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			if authorizeStream(w, r, tt.adminToken, tt.ticket) {
+				w.WriteHeader(http.StatusOK)
+			}
+			if w.Code != tt.wantStatus {
+				t.Errorf("estado %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -1,47 +1,94 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
-	"time"
 
 	"srs-backend/internal/models"
 	"srs-backend/internal/services"
+	"srs-backend/internal/storage"
 )
 
 type UnpublishHandler struct {
-	supabase  *services.SupabaseService
+	store     *storage.Store
 	thumbnail *services.ThumbnailService
+	keys       *services.StreamKeyService
+	publishers *services.PublisherTracker
+	failover   *services.FailoverService
+	sessions   *services.SessionTracker
+	events     *services.EventBus
+	webhooks   *services.WebhookService
 }
 
-func NewUnpublishHandler(supabase *services.SupabaseService, thumbnail *services.ThumbnailService) *UnpublishHandler {
+func NewUnpublishHandler(store *storage.Store, thumbnail *services.ThumbnailService, keys *services.StreamKeyService, publishers *services.PublisherTracker, failover *services.FailoverService, sessions *services.SessionTracker, events *services.EventBus, webhooks *services.WebhookService) *UnpublishHandler {
 	return &UnpublishHandler{
-		supabase:  supabase,
-		thumbnail: thumbnail,
+		store:      store,
+		thumbnail:  thumbnail,
+		keys:       keys,
+		publishers: publishers,
+		failover:   failover,
+		sessions:   sessions,
+		events:     events,
+		webhooks:   webhooks,
 	}
 }
 
 func (h *UnpublishHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	var cb models.SRSCallback
 	json.NewDecoder(r.Body).Decode(&cb)
-	log.Printf("🔻 Unpublish detectado: %s", cb.Stream)
+	slog.InfoContext(r.Context(), "🔻 Unpublish detectado")
 	w.Write([]byte("0"))
 
-	go h.processUnpublish(cb)
+	// La sesión se cierra también para un publicador reemplazado
+	go h.closeSession(context.WithoutCancel(r.Context()), cb)
+
+	// Tras un takeover llega el on_unpublish del publicador expulsado; el canal
+	// sigue en vivo con el nuevo encoder.
+	if !h.publishers.Release(cb.Stream, cb.ClientID) {
+		slog.InfoContext(r.Context(), "↪️ Unpublish de publicador reemplazado, canal sigue en vivo")
+		return
+	}
+
+	go h.processUnpublish(context.WithoutCancel(r.Context()), cb)
+}
+
+func (h *UnpublishHandler) closeSession(ctx context.Context, cb models.SRSCallback) {
+	err := h.sessions.Close(ctx, services.Session{
+		ClientID:   cb.ClientID,
+		ClientIP:   cb.IP,
+		ClientType: services.ClientTypePublish,
+		StreamName: cb.Stream,
+		App:        cb.App,
+	}, services.DisconnectReasonStop)
+	if err != nil {
+		slog.ErrorContext(ctx, "❌ Error cerrando sesión de publicación", "error", err)
+	}
 }
 
-func (h *UnpublishHandler) processUnpublish(cb models.SRSCallback) {
+func (h *UnpublishHandler) processUnpublish(ctx context.Context, cb models.SRSCallback) {
 	// Detener captura de thumbnails
 	h.thumbnail.StopCapture(cb.Stream)
 
-	// Actualizar base de datos
-	client := h.supabase.GetClient()
-	updateData := map[string]interface{}{
-		"is_on_live": false,
-		"modified":   time.Now().Format(time.RFC3339),
+	// Una clave en periodo de gracia o de respaldo ya no coincide con
+	// stream_id, por eso se resuelve el canal antes de actualizar.
+	channelID, err := h.keys.Resolve(ctx, cb.Stream)
+	if err == nil && channelID != "" {
+		if !h.failover.OnUnpublish(ctx, channelID, cb.Stream, cb.ClientID) {
+			slog.InfoContext(ctx, "🔀 Canal sigue en vivo con otra fuente", "channel_id", channelID)
+			return
+		}
+		h.store.Channels.SetOffline(ctx, channelID)
+		h.events.Publish(services.EventTypeUnpublish, cb.App, channelID, nil)
+		h.webhooks.Emit(ctx, models.WebhookEventChannelOffline, channelID, map[string]interface{}{"app": cb.App})
+		slog.InfoContext(ctx, "✅ Canal actualizado como offline", "channel_id", channelID)
+		return
 	}
 
-	client.From("channels_channel").Update(updateData, "", "").Eq("stream_id", cb.Stream).Execute()
-	log.Printf("✅ Canal actualizado como offline: %s", cb.Stream)
+	// Actualizar base de datos
+	h.store.Channels.SetOfflineByStreamKey(ctx, cb.Stream)
+	// Sin canal resuelto el evento solo lleva la app
+	h.events.Publish(services.EventTypeUnpublish, cb.App, "", nil)
+	slog.InfoContext(ctx, "✅ Canal actualizado como offline")
 }
\ No newline at end of file
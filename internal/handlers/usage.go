@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"srs-backend/internal/services"
+)
+
+var usageContentTypes = map[string]string{
+	services.UsageFormatCSV:    "text/csv; charset=utf-8",
+	services.UsageFormatNDJSON: "application/x-ndjson",
+}
+
+type UsageHandler struct {
+	usage      *services.UsageService
+	adminToken string
+}
+
+func NewUsageHandler(usage *services.UsageService, adminToken string) *UsageHandler {
+	return &UsageHandler{
+		usage:      usage,
+		adminToken: adminToken,
+	}
+}
+
+// Handle atiende GET /api/v1/usage con ?from=&to= (días UTC incluidos, por
+// defecto del día 1 del mes a hoy), scope=channel|organization,
+// organization_id, channel_id y format=ndjson|csv
+func (h *UsageHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	if !authorizeAdmin(w, r, h.adminToken) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "método no permitido")
+		return
+	}
+
+	query := r.URL.Query()
+	today := time.Now().UTC()
+	from := query.Get("from")
+	if from == "" {
+		from = time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
+	}
+	to := query.Get("to")
+	if to == "" {
+		to = today.Format("2006-01-02")
+	}
+
+	fromDay, toDay, err := services.ParseUsageDays(from, to)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	q := services.UsageQuery{
+		From:           fromDay,
+		To:             toDay,
+		Scope:          query.Get("scope"),
+		OrganizationID: query.Get("organization_id"),
+		ChannelID:      query.Get("channel_id"),
+		Format:         query.Get("format"),
+	}
+	// Validar antes de escribir la cabecera: después ya no hay código de error
+	if err := q.Validate(); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", usageContentTypes[q.Format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"usage_%s_%s_%s.%s\"", q.Scope, from, to, q.Format))
+	rows, err := h.usage.Export(r.Context(), q, w)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidUsageQuery) {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		// La respuesta queda cortada; el cliente lo ve como un error de lectura
+		slog.ErrorContext(r.Context(), "❌ Error exportando consumo", "rows", rows, "error", err)
+		if rows == 0 {
+			w.Header().Del("Content-Disposition")
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	slog.InfoContext(r.Context(), "📒 Consumo exportado", "from", from, "to", to, "scope", q.Scope, "format", q.Format, "rows", rows)
+}
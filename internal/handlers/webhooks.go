@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"srs-backend/internal/models"
+	"srs-backend/internal/services"
+)
+
+const (
+	defaultDeliveriesLimit = 100
+	maxDeliveriesLimit     = 1000
+)
+
+type WebhooksHandler struct {
+	webhooks   *services.WebhookService
+	adminToken string
+}
+
+func NewWebhooksHandler(webhooks *services.WebhookService, adminToken string) *WebhooksHandler {
+	return &WebhooksHandler{
+		webhooks:   webhooks,
+		adminToken: adminToken,
+	}
+}
+
+// Handle atiende /api/v1/webhooks (GET lista con ?organization_id=&channel_id=,
+// POST crea), /api/v1/webhooks/{id} (GET, PUT, DELETE),
+// /api/v1/webhooks/{id}/deliveries (GET con ?status=&limit=) y
+// /api/v1/webhooks/{id}/test (POST)
+func (h *WebhooksHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	if !authorizeAdmin(w, r, h.adminToken) {
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/webhooks"), "/")
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "":
+		h.handleCollection(w, r)
+	case len(parts) == 1:
+		h.handleWebhook(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "deliveries":
+		h.handleDeliveries(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "test":
+		h.handleTest(w, r, parts[0])
+	default:
+		writeJSONError(w, http.StatusNotFound, "ruta inválida, usar /api/v1/webhooks[/{id}[/deliveries|/test]]")
+	}
+}
+
+func (h *WebhooksHandler) handleCollection(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		webhooks, err := h.webhooks.List(r.Context(), query.Get("organization_id"), query.Get("channel_id"))
+		if err != nil {
+			h.writeWebhookError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"webhooks": webhooks})
+
+	case http.MethodPost:
+		var webhook models.Webhook
+		if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "JSON inválido")
+			return
+		}
+		created, err := h.webhooks.Create(r.Context(), webhook)
+		if err != nil {
+			h.writeWebhookError(w, err)
+			return
+		}
+		slog.InfoContext(r.Context(), "🪝 Webhook creado", "webhook_id", created.ID, "channel_id", created.ChannelID,
+			"organization_id", created.OrganizationID, "event_types", created.EventTypes)
+		writeJSON(w, http.StatusCreated, created)
+
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "método no permitido")
+	}
+}
+
+func (h *WebhooksHandler) handleWebhook(w http.ResponseWriter, r *http.Request, id string) {
+	switch r.Method {
+	case http.MethodGet:
+		webhook, err := h.webhooks.Get(r.Context(), id)
+		if err != nil {
+			h.writeWebhookError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, webhook)
+
+	case http.MethodPut:
+		var update services.WebhookUpdate
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "JSON inválido")
+			return
+		}
+		updated, err := h.webhooks.Update(r.Context(), id, update)
+		if err != nil {
+			h.writeWebhookError(w, err)
+			return
+		}
+		slog.InfoContext(r.Context(), "🪝 Webhook actualizado", "webhook_id", id, "event_types", updated.EventTypes,
+			"active", updated.Active, "secret_rotated", update.Secret != nil)
+		writeJSON(w, http.StatusOK, updated)
+
+	case http.MethodDelete:
+		if err := h.webhooks.Delete(r.Context(), id); err != nil {
+			h.writeWebhookError(w, err)
+			return
+		}
+		slog.InfoContext(r.Context(), "🪝 Webhook eliminado", "webhook_id", id)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "método no permitido")
+	}
+}
+
+func (h *WebhooksHandler) handleDeliveries(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "método no permitido")
+		return
+	}
+	limit := defaultDeliveriesLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > maxDeliveriesLimit {
+			writeJSONError(w, http.StatusBadRequest, "limit debe estar entre 1 y "+strconv.Itoa(maxDeliveriesLimit))
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := h.webhooks.Deliveries(r.Context(), id, r.URL.Query().Get("status"), limit)
+	if err != nil {
+		h.writeWebhookError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"deliveries": deliveries})
+}
+
+func (h *WebhooksHandler) handleTest(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "método no permitido")
+		return
+	}
+	delivery, err := h.webhooks.SendTest(r.Context(), id)
+	if err != nil {
+		h.writeWebhookError(w, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "🪝 Evento de prueba enviado", "webhook_id", id, "status", delivery.Status)
+	// 200 aunque el destino falle: el resultado va en la entrega
+	writeJSON(w, http.StatusOK, delivery)
+}
+
+func (h *WebhooksHandler) writeWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrWebhookNotFound), errors.Is(err, services.ErrChannelNotFound):
+		writeJSONError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrInvalidWebhook):
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+	default:
+		slog.Error("❌ Error gestionando webhooks", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,272 @@
+package logging
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Formatos de salida (LOG_FORMAT)
+const (
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+)
+
+// Campos estándar de los registros
+const (
+	KeyServerID   = "server_id"
+	KeyApp        = "app"
+	KeyStreamHash = "stream_hash"
+	KeyClientID   = "client_id"
+	KeyHook       = "hook"
+)
+
+// Campos que nunca se escriben en claro: la clave de transmisión viaja en
+// stream y en param (?key=...).
+var redactedKeys = map[string]bool{
+	"stream":     true,
+	"stream_key": true,
+	"param":      true,
+	"token":      true,
+}
+
+var (
+	level   = new(slog.LevelVar)
+	streams = &streamLevels{until: make(map[string]time.Time)}
+	format  string
+)
+
+// Setup instala el logger por defecto. log.Printf también pasa por él con
+// nivel info.
+func Setup(w io.Writer, logFormat, logLevel, serverID string) error {
+	if err := SetLevel(logLevel); err != nil {
+		return err
+	}
+
+	opts := &slog.HandlerOptions{
+		// El filtrado por nivel lo hace handler para admitir excepciones por stream
+		Level:       slog.LevelDebug,
+		ReplaceAttr: redact,
+	}
+	var inner slog.Handler
+	switch logFormat {
+	case FormatJSON, "":
+		inner = slog.NewJSONHandler(w, opts)
+		logFormat = FormatJSON
+	case FormatLogfmt:
+		inner = slog.NewTextHandler(w, opts)
+	default:
+		return fmt.Errorf("LOG_FORMAT desconocido: %q", logFormat)
+	}
+	format = logFormat
+
+	slog.SetDefault(slog.New(&handler{inner: inner}).With(KeyServerID, serverID))
+	return nil
+}
+
+// SetLevel cambia el nivel global en caliente (debug, info, warn, error)
+func SetLevel(name string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(name)); err != nil {
+		return fmt.Errorf("nivel de log inválido: %q", name)
+	}
+	level.Set(l)
+	return nil
+}
+
+// Level devuelve el nivel global actual
+func Level() string {
+	return strings.ToLower(level.Level().String())
+}
+
+// Format devuelve el formato configurado
+func Format() string {
+	return format
+}
+
+// StreamHash identifica una clave de transmisión en los logs sin revelarla
+func StreamHash(streamKey string) string {
+	if streamKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(streamKey))
+	return hex.EncodeToString(sum[:6])
+}
+
+// Stream devuelve los campos app y stream_hash de un stream
+func Stream(app, streamKey string) []any {
+	return []any{KeyApp, app, KeyStreamHash, StreamHash(streamKey)}
+}
+
+type ctxKey struct{}
+
+// WithAttrs agrega campos a los registros emitidos con este contexto
+// (slog.InfoContext y similares).
+func WithAttrs(ctx context.Context, args ...any) context.Context {
+	attrs := append(contextAttrs(ctx), argsToAttrs(args)...)
+	return context.WithValue(ctx, ctxKey{}, attrs)
+}
+
+func contextAttrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(ctxKey{}).([]slog.Attr)
+	return attrs[:len(attrs):len(attrs)]
+}
+
+func argsToAttrs(args []any) []slog.Attr {
+	var r slog.Record
+	r.Add(args...)
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return attrs
+}
+
+// StreamDebug es una excepción de nivel debug para un stream
+type StreamDebug struct {
+	StreamHash string    `json:"stream_hash"`
+	Until      time.Time `json:"until"`
+}
+
+// EnableStreamDebug escribe en debug los registros del stream hasta que vence
+// la duración, sin cambiar el nivel global.
+func EnableStreamDebug(streamHash string, d time.Duration) time.Time {
+	until := time.Now().UTC().Add(d)
+	streams.mu.Lock()
+	streams.until[streamHash] = until
+	streams.mu.Unlock()
+	return until
+}
+
+// DisableStreamDebug elimina la excepción del stream
+func DisableStreamDebug(streamHash string) {
+	streams.mu.Lock()
+	delete(streams.until, streamHash)
+	streams.mu.Unlock()
+}
+
+// StreamDebugs lista las excepciones vigentes
+func StreamDebugs() []StreamDebug {
+	streams.mu.Lock()
+	defer streams.mu.Unlock()
+
+	now := time.Now()
+	list := []StreamDebug{}
+	for hash, until := range streams.until {
+		if until.After(now) {
+			list = append(list, StreamDebug{StreamHash: hash, Until: until})
+		} else {
+			delete(streams.until, hash)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].StreamHash < list[j].StreamHash })
+	return list
+}
+
+type streamLevels struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+func (s *streamLevels) any() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.until) > 0
+}
+
+func (s *streamLevels) active(streamHash string) bool {
+	if streamHash == "" {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until, ok := s.until[streamHash]
+	return ok && until.After(time.Now())
+}
+
+// handler filtra por el nivel global salvo para los streams en debug y añade
+// trace_id/span_id cuando el registro lleva un contexto con span.
+type handler struct {
+	inner      slog.Handler
+	streamHash string
+}
+
+func (h *handler) Enabled(_ context.Context, l slog.Level) bool {
+	if l >= level.Level() {
+		return true
+	}
+	return l >= slog.LevelDebug && streams.any()
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	extra := contextAttrs(ctx)
+
+	if r.Level < level.Level() {
+		hash := h.streamHash
+		find := func(a slog.Attr) bool {
+			if a.Key == KeyStreamHash {
+				hash = a.Value.String()
+				return false
+			}
+			return true
+		}
+		if hash == "" {
+			r.Attrs(find)
+		}
+		for _, a := range extra {
+			if hash != "" {
+				break
+			}
+			find(a)
+		}
+		if !streams.active(hash) {
+			return nil
+		}
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		extra = append(extra,
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	if len(extra) > 0 {
+		r = r.Clone()
+		r.AddAttrs(extra...)
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	hash := h.streamHash
+	for _, a := range attrs {
+		if a.Key == KeyStreamHash {
+			hash = a.Value.String()
+		}
+	}
+	return &handler{inner: h.inner.WithAttrs(attrs), streamHash: hash}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{inner: h.inner.WithGroup(name), streamHash: h.streamHash}
+}
+
+func redact(_ []string, a slog.Attr) slog.Attr {
+	if redactedKeys[a.Key] && a.Value.String() != "" {
+		return slog.String(a.Key, "[REDACTED]")
+	}
+	return a
+}
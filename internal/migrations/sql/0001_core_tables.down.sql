@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS server_ingest_system_metrics;
+DROP TABLE IF EXISTS server_ingest_system_events;
+DROP TABLE IF EXISTS server_ingest_client_connections;
+DROP TABLE IF EXISTS server_ingest_stream_metrics;
+DROP TABLE IF EXISTS server_ingest_server_metrics;
+DROP TABLE IF EXISTS server_ingest_srs_servers;
@@ -0,0 +1,110 @@
+-- Tablas de métricas, sesiones y eventos escritas por el backend Go
+
+CREATE TABLE IF NOT EXISTS server_ingest_srs_servers (
+    id          BIGSERIAL PRIMARY KEY,
+    server_id   VARCHAR(100) NOT NULL UNIQUE,
+    server_ip   VARCHAR(50),
+    server_name VARCHAR(255),
+    location    VARCHAR(100),
+    is_active   BOOLEAN NOT NULL DEFAULT TRUE,
+    last_seen   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    metadata    JSONB NOT NULL DEFAULT '{}'::jsonb
+);
+
+CREATE TABLE IF NOT EXISTS server_ingest_server_metrics (
+    id                BIGSERIAL PRIMARY KEY,
+    timestamp         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    server_id         VARCHAR(100) NOT NULL,
+    server_ip         VARCHAR(50),
+    cpu_percent       DECIMAL(5,2),
+    memory_mb         BIGINT,
+    total_streams     INTEGER,
+    total_connections INTEGER,
+    publishers        INTEGER,
+    players           INTEGER,
+    -- El collector hace upsert por minuto para no duplicar muestras
+    minute_bucket     TIMESTAMPTZ NOT NULL,
+    CONSTRAINT server_ingest_server_metrics_server_minute_key UNIQUE (server_id, minute_bucket)
+);
+
+CREATE INDEX IF NOT EXISTS idx_server_ingest_server_metrics_server_time
+    ON server_ingest_server_metrics (server_id, timestamp DESC);
+
+CREATE TABLE IF NOT EXISTS server_ingest_stream_metrics (
+    id            BIGSERIAL PRIMARY KEY,
+    timestamp     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    server_id     VARCHAR(100) NOT NULL,
+    server_ip     VARCHAR(50),
+    stream_id     VARCHAR(100),
+    stream_name   VARCHAR(255),
+    app           VARCHAR(100),
+    clients       INTEGER,
+    recv_kbps     INTEGER,
+    send_kbps     INTEGER,
+    is_publishing BOOLEAN,
+    video_codec   VARCHAR(50),
+    resolution    VARCHAR(20)
+);
+
+CREATE INDEX IF NOT EXISTS idx_server_ingest_stream_metrics_server_time
+    ON server_ingest_stream_metrics (server_id, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_server_ingest_stream_metrics_stream_time
+    ON server_ingest_stream_metrics (stream_name, timestamp DESC);
+
+CREATE TABLE IF NOT EXISTS server_ingest_client_connections (
+    id               BIGSERIAL PRIMARY KEY,
+    timestamp        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    server_id        VARCHAR(100) NOT NULL,
+    server_ip        VARCHAR(50),
+    client_id        VARCHAR(100),
+    client_ip        VARCHAR(50),
+    client_type      VARCHAR(50),
+    stream_id        VARCHAR(100),
+    stream_name      VARCHAR(255),
+    app              VARCHAR(100),
+    connected_at     TIMESTAMPTZ,
+    disconnected_at  TIMESTAMPTZ,
+    total_send_mb    DECIMAL(12,2),
+    total_recv_mb    DECIMAL(12,2),
+    duration_seconds INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_server_ingest_client_connections_server_time
+    ON server_ingest_client_connections (server_id, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_server_ingest_client_connections_client
+    ON server_ingest_client_connections (server_id, client_id);
+CREATE INDEX IF NOT EXISTS idx_server_ingest_client_connections_open
+    ON server_ingest_client_connections (client_type)
+    WHERE disconnected_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS server_ingest_system_events (
+    id         BIGSERIAL PRIMARY KEY,
+    timestamp  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    server_id  VARCHAR(100),
+    server_ip  VARCHAR(50),
+    event_type VARCHAR(50) NOT NULL,
+    severity   VARCHAR(20) NOT NULL DEFAULT 'info',
+    message    TEXT,
+    metadata   JSONB
+);
+
+CREATE INDEX IF NOT EXISTS idx_server_ingest_system_events_time
+    ON server_ingest_system_events (timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_server_ingest_system_events_type_time
+    ON server_ingest_system_events (event_type, timestamp DESC);
+
+-- Respuestas crudas de summaries, system_proc_stats, self_proc_stats y meminfos
+CREATE TABLE IF NOT EXISTS server_ingest_system_metrics (
+    id                BIGSERIAL PRIMARY KEY,
+    timestamp         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    server_id         VARCHAR(100) NOT NULL,
+    server_ip         VARCHAR(50),
+    summaries         JSONB,
+    system_proc_stats JSONB,
+    self_proc_stats   JSONB,
+    meminfos          JSONB
+);
+
+CREATE INDEX IF NOT EXISTS idx_server_ingest_system_metrics_server_time
+    ON server_ingest_system_metrics (server_id, timestamp DESC);
@@ -0,0 +1,6 @@
+DROP FUNCTION IF EXISTS mark_inactive_servers();
+DROP FUNCTION IF EXISTS cleanup_old_server_ingest_metrics();
+DROP VIEW IF EXISTS server_ingest_load_distribution;
+DROP VIEW IF EXISTS server_ingest_top_streams_by_server;
+DROP VIEW IF EXISTS server_ingest_stats_by_server_24h;
+DROP VIEW IF EXISTS server_ingest_servers_status;
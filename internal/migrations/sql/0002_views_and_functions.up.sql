@@ -0,0 +1,98 @@
+-- Vistas para el dashboard y funciones de mantenimiento
+
+CREATE OR REPLACE VIEW server_ingest_servers_status AS
+SELECT
+    s.server_id,
+    s.server_ip,
+    s.server_name,
+    s.location,
+    s.is_active,
+    s.last_seen,
+    EXTRACT(EPOCH FROM (NOW() - s.last_seen))::INTEGER AS seconds_since_last_seen,
+    m.total_streams     AS current_streams,
+    m.total_connections AS current_connections,
+    m.cpu_percent       AS current_cpu,
+    m.memory_mb         AS current_memory
+FROM server_ingest_srs_servers s
+LEFT JOIN LATERAL (
+    SELECT total_streams, total_connections, cpu_percent, memory_mb
+    FROM server_ingest_server_metrics sm
+    WHERE sm.server_id = s.server_id
+    ORDER BY sm.timestamp DESC
+    LIMIT 1
+) m ON TRUE;
+
+CREATE OR REPLACE VIEW server_ingest_stats_by_server_24h AS
+SELECT
+    server_id,
+    MAX(server_ip)                          AS server_ip,
+    DATE_TRUNC('hour', timestamp)           AS hour,
+    ROUND(AVG(cpu_percent)::numeric, 2)     AS avg_cpu,
+    MAX(cpu_percent)                        AS max_cpu,
+    ROUND(AVG(memory_mb)::numeric, 0)       AS avg_memory_mb,
+    MAX(memory_mb)                          AS max_memory_mb,
+    ROUND(AVG(total_streams)::numeric, 2)   AS avg_streams,
+    ROUND(AVG(total_connections)::numeric, 2) AS avg_connections
+FROM server_ingest_server_metrics
+WHERE timestamp >= NOW() - INTERVAL '24 hours'
+GROUP BY server_id, DATE_TRUNC('hour', timestamp);
+
+CREATE OR REPLACE VIEW server_ingest_top_streams_by_server AS
+SELECT
+    server_id,
+    MAX(server_ip)                      AS server_ip,
+    stream_name,
+    app,
+    ROUND(AVG(clients)::numeric, 2)     AS avg_clients,
+    ROUND(AVG(recv_kbps)::numeric, 0)   AS avg_recv_kbps,
+    ROUND(AVG(send_kbps)::numeric, 0)   AS avg_send_kbps,
+    COUNT(*)                            AS total_metrics
+FROM server_ingest_stream_metrics
+WHERE timestamp >= NOW() - INTERVAL '1 hour'
+    AND is_publishing = TRUE
+GROUP BY server_id, stream_name, app
+ORDER BY server_id, avg_clients DESC;
+
+-- Última muestra de cada stream en los últimos 5 minutos
+CREATE OR REPLACE VIEW server_ingest_load_distribution AS
+WITH latest AS (
+    SELECT DISTINCT ON (server_id, stream_name)
+        server_id, server_ip, stream_name, clients, recv_kbps, send_kbps
+    FROM server_ingest_stream_metrics
+    WHERE timestamp >= NOW() - INTERVAL '5 minutes'
+        AND is_publishing = TRUE
+    ORDER BY server_id, stream_name, timestamp DESC
+)
+SELECT
+    server_id,
+    MAX(server_ip)                      AS server_ip,
+    COUNT(*)                            AS active_streams,
+    COALESCE(SUM(clients), 0)           AS total_clients,
+    ROUND(AVG(recv_kbps)::numeric, 0)   AS avg_recv_kbps,
+    ROUND(AVG(send_kbps)::numeric, 0)   AS avg_send_kbps
+FROM latest
+GROUP BY server_id;
+
+-- Retención: métricas de servidor 30 días, de streams y sistema 7 días,
+-- sesiones y eventos 90 días.
+CREATE OR REPLACE FUNCTION cleanup_old_server_ingest_metrics() RETURNS void
+LANGUAGE plpgsql AS $$
+BEGIN
+    DELETE FROM server_ingest_server_metrics WHERE timestamp < NOW() - INTERVAL '30 days';
+    DELETE FROM server_ingest_stream_metrics WHERE timestamp < NOW() - INTERVAL '7 days';
+    DELETE FROM server_ingest_system_metrics WHERE timestamp < NOW() - INTERVAL '7 days';
+    DELETE FROM server_ingest_client_connections WHERE timestamp < NOW() - INTERVAL '90 days';
+    DELETE FROM server_ingest_system_events WHERE timestamp < NOW() - INTERVAL '90 days';
+END;
+$$;
+
+-- Un servidor sin heartbeat en 2 minutos (4 ciclos del collector) se marca inactivo
+CREATE OR REPLACE FUNCTION mark_inactive_servers() RETURNS void
+LANGUAGE plpgsql AS $$
+BEGIN
+    UPDATE server_ingest_srs_servers
+    SET is_active = FALSE
+    WHERE is_active
+        AND last_seen < NOW() - INTERVAL '2 minutes';
+END;
+$$;
@@ -0,0 +1,9 @@
+DO $$
+BEGIN
+    IF to_regclass('channels_channel') IS NOT NULL THEN
+        ALTER TABLE channels_channel DROP COLUMN IF EXISTS active_ingest;
+    END IF;
+END;
+$$;
+
+DROP TABLE IF EXISTS server_ingest_stream_key_grace;
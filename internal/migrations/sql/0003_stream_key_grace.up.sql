@@ -0,0 +1,20 @@
+-- Claves rotadas que siguen publicando durante la ventana de gracia
+CREATE TABLE IF NOT EXISTS server_ingest_stream_key_grace (
+    id         BIGSERIAL PRIMARY KEY,
+    channel_id TEXT NOT NULL,
+    stream_key VARCHAR(255) NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_server_ingest_stream_key_grace_channel
+    ON server_ingest_stream_key_grace (channel_id);
+
+-- channels_channel pertenece a otra aplicación: solo se amplía si existe
+DO $$
+BEGIN
+    IF to_regclass('channels_channel') IS NOT NULL THEN
+        ALTER TABLE channels_channel ADD COLUMN IF NOT EXISTS active_ingest VARCHAR(10);
+    END IF;
+END;
+$$;
@@ -0,0 +1,15 @@
+DO $$
+BEGIN
+    IF to_regclass('channels_channel') IS NOT NULL THEN
+        ALTER TABLE channels_channel DROP COLUMN IF EXISTS organization_id;
+    END IF;
+END;
+$$;
+
+DROP INDEX IF EXISTS idx_server_ingest_client_connections_org_open;
+ALTER TABLE server_ingest_client_connections DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE server_ingest_client_connections DROP COLUMN IF EXISTS channel_id;
+
+DROP TABLE IF EXISTS server_ingest_tenant_usage;
+DROP TABLE IF EXISTS server_ingest_organizations;
+DROP TABLE IF EXISTS server_ingest_plans;
@@ -0,0 +1,43 @@
+-- Organizaciones, planes y consumo mensual para las cuotas. Un límite en 0 es ilimitado.
+CREATE TABLE IF NOT EXISTS server_ingest_plans (
+    id                     UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name                   VARCHAR(100) NOT NULL,
+    max_concurrent_streams INTEGER NOT NULL DEFAULT 0,
+    max_concurrent_viewers INTEGER NOT NULL DEFAULT 0,
+    monthly_stream_hours   DECIMAL(12,2) NOT NULL DEFAULT 0,
+    monthly_bandwidth_gb   DECIMAL(12,2) NOT NULL DEFAULT 0,
+    created_at             TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS server_ingest_organizations (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name       VARCHAR(255) NOT NULL,
+    plan_id    UUID REFERENCES server_ingest_plans (id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS server_ingest_tenant_usage (
+    id              BIGSERIAL PRIMARY KEY,
+    organization_id UUID NOT NULL REFERENCES server_ingest_organizations (id) ON DELETE CASCADE,
+    month           DATE NOT NULL,
+    server_id       VARCHAR(100) NOT NULL,
+    stream_seconds  BIGINT NOT NULL DEFAULT 0,
+    bandwidth_bytes BIGINT NOT NULL DEFAULT 0,
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT server_ingest_tenant_usage_org_month_server_key UNIQUE (organization_id, month, server_id)
+);
+
+ALTER TABLE server_ingest_client_connections ADD COLUMN IF NOT EXISTS channel_id TEXT;
+ALTER TABLE server_ingest_client_connections ADD COLUMN IF NOT EXISTS organization_id UUID;
+
+CREATE INDEX IF NOT EXISTS idx_server_ingest_client_connections_org_open
+    ON server_ingest_client_connections (organization_id, client_type)
+    WHERE disconnected_at IS NULL;
+
+DO $$
+BEGIN
+    IF to_regclass('channels_channel') IS NOT NULL THEN
+        ALTER TABLE channels_channel ADD COLUMN IF NOT EXISTS organization_id UUID;
+    END IF;
+END;
+$$;
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS uq_server_ingest_client_connections_idempotency;
+DROP INDEX IF EXISTS uq_server_ingest_system_metrics_idempotency;
+DROP INDEX IF EXISTS uq_server_ingest_system_events_idempotency;
+DROP INDEX IF EXISTS uq_server_ingest_stream_metrics_idempotency;
+
+ALTER TABLE server_ingest_client_connections DROP COLUMN IF EXISTS idempotency_key;
+ALTER TABLE server_ingest_system_metrics DROP COLUMN IF EXISTS idempotency_key;
+ALTER TABLE server_ingest_system_events DROP COLUMN IF EXISTS idempotency_key;
+ALTER TABLE server_ingest_stream_metrics DROP COLUMN IF EXISTS idempotency_key;
@@ -0,0 +1,14 @@
+-- Clave de idempotencia de los inserts del outbox: un reenvío no duplica filas
+ALTER TABLE server_ingest_stream_metrics ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(64);
+ALTER TABLE server_ingest_system_events ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(64);
+ALTER TABLE server_ingest_system_metrics ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(64);
+ALTER TABLE server_ingest_client_connections ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_server_ingest_stream_metrics_idempotency
+    ON server_ingest_stream_metrics (idempotency_key);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_server_ingest_system_events_idempotency
+    ON server_ingest_system_events (idempotency_key);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_server_ingest_system_metrics_idempotency
+    ON server_ingest_system_metrics (idempotency_key);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_server_ingest_client_connections_idempotency
+    ON server_ingest_client_connections (idempotency_key);
@@ -0,0 +1 @@
+ALTER TABLE server_ingest_client_connections DROP COLUMN IF EXISTS disconnect_reason;
@@ -0,0 +1,2 @@
+-- Motivo de cierre de la sesión: stop (on_stop de SRS) o shutdown (apagado del backend)
+ALTER TABLE server_ingest_client_connections ADD COLUMN IF NOT EXISTS disconnect_reason VARCHAR(50);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_server_ingest_client_connections_server_open;
+
+ALTER TABLE server_ingest_client_connections DROP COLUMN IF EXISTS reconciled;
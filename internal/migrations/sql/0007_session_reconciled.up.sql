@@ -0,0 +1,6 @@
+-- Sesiones cerradas o creadas por el reconciliador a partir de /api/v1/clients de SRS
+ALTER TABLE server_ingest_client_connections ADD COLUMN IF NOT EXISTS reconciled BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS idx_server_ingest_client_connections_server_open
+    ON server_ingest_client_connections (server_id)
+    WHERE disconnected_at IS NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE server_ingest_client_connections DROP COLUMN IF EXISTS avg_recv_kbps;
+ALTER TABLE server_ingest_client_connections DROP COLUMN IF EXISTS avg_send_kbps;
@@ -0,0 +1,3 @@
+-- Bitrate medio de la sesión (kbps), calculado con los bytes totales y la duración
+ALTER TABLE server_ingest_client_connections ADD COLUMN IF NOT EXISTS avg_send_kbps INTEGER;
+ALTER TABLE server_ingest_client_connections ADD COLUMN IF NOT EXISTS avg_recv_kbps INTEGER;
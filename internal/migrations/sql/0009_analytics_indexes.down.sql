@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_server_ingest_client_connections_channel_connected;
+DROP INDEX IF EXISTS idx_server_ingest_client_connections_stream_connected;
@@ -0,0 +1,5 @@
+-- Lecturas de /api/v1/analytics por stream y por canal en una ventana de tiempo
+CREATE INDEX IF NOT EXISTS idx_server_ingest_client_connections_stream_connected
+    ON server_ingest_client_connections (stream_name, connected_at);
+CREATE INDEX IF NOT EXISTS idx_server_ingest_client_connections_channel_connected
+    ON server_ingest_client_connections (channel_id, connected_at);
@@ -0,0 +1,5 @@
+ALTER TABLE server_ingest_client_connections DROP COLUMN IF EXISTS as_org;
+ALTER TABLE server_ingest_client_connections DROP COLUMN IF EXISTS asn;
+ALTER TABLE server_ingest_client_connections DROP COLUMN IF EXISTS city;
+ALTER TABLE server_ingest_client_connections DROP COLUMN IF EXISTS region;
+ALTER TABLE server_ingest_client_connections DROP COLUMN IF EXISTS country_code;
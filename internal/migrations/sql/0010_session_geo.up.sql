@@ -0,0 +1,6 @@
+-- Ubicación y red del cliente resueltas con las bases GeoIP locales al abrir la sesión
+ALTER TABLE server_ingest_client_connections ADD COLUMN IF NOT EXISTS country_code VARCHAR(2);
+ALTER TABLE server_ingest_client_connections ADD COLUMN IF NOT EXISTS region VARCHAR(100);
+ALTER TABLE server_ingest_client_connections ADD COLUMN IF NOT EXISTS city VARCHAR(100);
+ALTER TABLE server_ingest_client_connections ADD COLUMN IF NOT EXISTS asn BIGINT;
+ALTER TABLE server_ingest_client_connections ADD COLUMN IF NOT EXISTS as_org VARCHAR(255);
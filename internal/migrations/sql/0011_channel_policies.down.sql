@@ -0,0 +1 @@
+DROP TABLE IF EXISTS server_ingest_channel_policies;
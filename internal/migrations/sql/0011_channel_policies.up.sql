@@ -0,0 +1,9 @@
+-- Restricción geográfica por canal: listas de países ISO 3166 admitidos y bloqueados
+CREATE TABLE IF NOT EXISTS server_ingest_channel_policies (
+    channel_id        TEXT PRIMARY KEY,
+    allowed_countries JSONB NOT NULL DEFAULT '[]'::jsonb,
+    blocked_countries JSONB NOT NULL DEFAULT '[]'::jsonb,
+    block_unknown     BOOLEAN NOT NULL DEFAULT false,
+    apply_to_publish  BOOLEAN NOT NULL DEFAULT false,
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
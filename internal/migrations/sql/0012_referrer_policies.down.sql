@@ -0,0 +1,3 @@
+ALTER TABLE server_ingest_client_connections DROP COLUMN IF EXISTS referrer_domain;
+ALTER TABLE server_ingest_channel_policies DROP COLUMN IF EXISTS empty_referrer;
+ALTER TABLE server_ingest_channel_policies DROP COLUMN IF EXISTS allowed_domains;
@@ -0,0 +1,4 @@
+-- Dominios autorizados para embeber el player de un canal y dominio de origen de cada sesión
+ALTER TABLE server_ingest_channel_policies ADD COLUMN IF NOT EXISTS allowed_domains JSONB NOT NULL DEFAULT '[]'::jsonb;
+ALTER TABLE server_ingest_channel_policies ADD COLUMN IF NOT EXISTS empty_referrer VARCHAR(10) NOT NULL DEFAULT 'allow';
+ALTER TABLE server_ingest_client_connections ADD COLUMN IF NOT EXISTS referrer_domain VARCHAR(255);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_server_ingest_system_events_channel;
+DROP INDEX IF EXISTS idx_server_ingest_system_events_client_ip;
+DROP INDEX IF EXISTS idx_server_ingest_client_connections_client_ip;
+DROP INDEX IF EXISTS idx_server_ingest_system_metrics_time;
+DROP INDEX IF EXISTS idx_server_ingest_server_metrics_time;
+DROP INDEX IF EXISTS idx_server_ingest_stream_metrics_time;
+DROP INDEX IF EXISTS idx_server_ingest_client_connections_time;
@@ -0,0 +1,15 @@
+-- Borrados de la retención por antigüedad y de las solicitudes de supresión
+CREATE INDEX IF NOT EXISTS idx_server_ingest_client_connections_time
+    ON server_ingest_client_connections (timestamp);
+CREATE INDEX IF NOT EXISTS idx_server_ingest_stream_metrics_time
+    ON server_ingest_stream_metrics (timestamp);
+CREATE INDEX IF NOT EXISTS idx_server_ingest_server_metrics_time
+    ON server_ingest_server_metrics (timestamp);
+CREATE INDEX IF NOT EXISTS idx_server_ingest_system_metrics_time
+    ON server_ingest_system_metrics (timestamp);
+CREATE INDEX IF NOT EXISTS idx_server_ingest_client_connections_client_ip
+    ON server_ingest_client_connections (client_ip);
+CREATE INDEX IF NOT EXISTS idx_server_ingest_system_events_client_ip
+    ON server_ingest_system_events ((metadata->>'client_ip'));
+CREATE INDEX IF NOT EXISTS idx_server_ingest_system_events_channel
+    ON server_ingest_system_events ((metadata->>'channel_id'));
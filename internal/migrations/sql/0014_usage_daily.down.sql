@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS server_ingest_usage_daily;
+ALTER TABLE server_ingest_stream_metrics DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE server_ingest_stream_metrics DROP COLUMN IF EXISTS channel_id;
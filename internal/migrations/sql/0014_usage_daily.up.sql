@@ -0,0 +1,25 @@
+-- Consumo diario por canal y por organización para facturación. Las muestras
+-- del recolector guardan el canal para no depender de la clave, que rota.
+-- organization_id es UUID como en 0004; sin organización queda NULL.
+ALTER TABLE server_ingest_stream_metrics ADD COLUMN IF NOT EXISTS channel_id TEXT;
+ALTER TABLE server_ingest_stream_metrics ADD COLUMN IF NOT EXISTS organization_id UUID;
+
+CREATE TABLE IF NOT EXISTS server_ingest_usage_daily (
+    id              BIGSERIAL PRIMARY KEY,
+    day             DATE NOT NULL,
+    scope           VARCHAR(20) NOT NULL,
+    channel_id      TEXT NOT NULL DEFAULT '',
+    organization_id UUID,
+    ingest_seconds  BIGINT NOT NULL DEFAULT 0,
+    viewer_seconds  BIGINT NOT NULL DEFAULT 0,
+    egress_bytes    BIGINT NOT NULL DEFAULT 0,
+    peak_concurrent INTEGER NOT NULL DEFAULT 0,
+    views           INTEGER NOT NULL DEFAULT 0,
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- Con organization_id NULL la restricción no detecta duplicados; no
+    -- aparecen porque cada cálculo borra las filas del día antes de escribir
+    CONSTRAINT server_ingest_usage_daily_day_subject_key UNIQUE (day, scope, channel_id, organization_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_server_ingest_usage_daily_org_day
+    ON server_ingest_usage_daily (organization_id, day);
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS server_ingest_channel_viewers;
+DROP FUNCTION IF EXISTS server_ingest_sync_channel_viewers();
+
+DO $$
+BEGIN
+    IF to_regclass('channels_channel') IS NOT NULL THEN
+        ALTER TABLE channels_channel DROP COLUMN IF EXISTS peak_viewers;
+        ALTER TABLE channels_channel DROP COLUMN IF EXISTS live_viewers;
+    END IF;
+END;
+$$;
@@ -0,0 +1,59 @@
+-- Viewers en vivo de cada canal en channels_channel. Cada servidor guarda
+-- sus viewers por canal en un único upsert por ciclo y el trigger suma los
+-- servidores activos sobre los canales en vivo.
+CREATE TABLE IF NOT EXISTS server_ingest_channel_viewers (
+    channel_id TEXT NOT NULL,
+    server_id  VARCHAR(100) NOT NULL,
+    viewers    INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (channel_id, server_id)
+);
+
+-- Un servidor sin heartbeat en 2 minutos (como mark_inactive_servers) no
+-- suma: sus viewers se descuentan con la siguiente escritura del canal.
+CREATE OR REPLACE FUNCTION server_ingest_sync_channel_viewers() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    UPDATE channels_channel c
+    SET live_viewers = totals.viewers,
+        peak_viewers = GREATEST(COALESCE(c.peak_viewers, 0), totals.viewers)
+    FROM (
+        SELECT v.channel_id,
+               COALESCE(SUM(v.viewers) FILTER (WHERE s.server_id IS NOT NULL), 0)::integer AS viewers
+        FROM server_ingest_channel_viewers v
+        LEFT JOIN server_ingest_srs_servers s
+            ON s.server_id = v.server_id
+            AND s.is_active
+            AND s.last_seen > NOW() - INTERVAL '2 minutes'
+        WHERE v.channel_id IN (SELECT channel_id FROM changed)
+        GROUP BY v.channel_id
+    ) totals
+    WHERE c.id::text = totals.channel_id
+        AND c.is_on_live;
+    RETURN NULL;
+END;
+$$;
+
+-- channels_channel pertenece a otra aplicación: solo se amplía si existe. Un
+-- upsert dispara el trigger de INSERT con las filas nuevas y el de UPDATE
+-- con las existentes.
+DO $$
+BEGIN
+    IF to_regclass('channels_channel') IS NOT NULL THEN
+        ALTER TABLE channels_channel ADD COLUMN IF NOT EXISTS live_viewers INTEGER NOT NULL DEFAULT 0;
+        ALTER TABLE channels_channel ADD COLUMN IF NOT EXISTS peak_viewers INTEGER NOT NULL DEFAULT 0;
+
+        DROP TRIGGER IF EXISTS server_ingest_channel_viewers_insert ON server_ingest_channel_viewers;
+        CREATE TRIGGER server_ingest_channel_viewers_insert
+            AFTER INSERT ON server_ingest_channel_viewers
+            REFERENCING NEW TABLE AS changed
+            FOR EACH STATEMENT EXECUTE FUNCTION server_ingest_sync_channel_viewers();
+
+        DROP TRIGGER IF EXISTS server_ingest_channel_viewers_update ON server_ingest_channel_viewers;
+        CREATE TRIGGER server_ingest_channel_viewers_update
+            AFTER UPDATE ON server_ingest_channel_viewers
+            REFERENCING NEW TABLE AS changed
+            FOR EACH STATEMENT EXECUTE FUNCTION server_ingest_sync_channel_viewers();
+    END IF;
+END;
+$$;
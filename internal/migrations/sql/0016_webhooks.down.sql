@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS server_ingest_webhook_deliveries;
+DROP TABLE IF EXISTS server_ingest_webhooks;
@@ -0,0 +1,38 @@
+-- Webhooks salientes por canal u organización y registro de entregas. Las
+-- entregas que agotan los reintentos quedan con status 'dead'.
+-- organization_id es UUID como en 0004; en un webhook de canal queda NULL.
+CREATE TABLE IF NOT EXISTS server_ingest_webhooks (
+    id              TEXT PRIMARY KEY,
+    organization_id UUID,
+    channel_id      TEXT NOT NULL DEFAULT '',
+    url             TEXT NOT NULL,
+    secret          TEXT NOT NULL,
+    event_types     JSONB NOT NULL DEFAULT '[]'::jsonb,
+    active          BOOLEAN NOT NULL DEFAULT true,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((organization_id IS NULL) <> (channel_id = ''))
+);
+
+CREATE INDEX IF NOT EXISTS idx_server_ingest_webhooks_channel ON server_ingest_webhooks (channel_id) WHERE channel_id <> '';
+CREATE INDEX IF NOT EXISTS idx_server_ingest_webhooks_organization ON server_ingest_webhooks (organization_id) WHERE organization_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS server_ingest_webhook_deliveries (
+    id              TEXT PRIMARY KEY,
+    webhook_id      TEXT NOT NULL REFERENCES server_ingest_webhooks (id) ON DELETE CASCADE,
+    event_id        TEXT NOT NULL,
+    event_type      VARCHAR(50) NOT NULL,
+    payload         JSONB NOT NULL,
+    server_id       VARCHAR(100) NOT NULL,
+    status          VARCHAR(20) NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    response_status INTEGER,
+    last_error      TEXT,
+    next_attempt_at TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    delivered_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_server_ingest_webhook_deliveries_webhook ON server_ingest_webhook_deliveries (webhook_id, created_at DESC);
+-- Cada servidor retoma sus entregas pendientes al arrancar
+CREATE INDEX IF NOT EXISTS idx_server_ingest_webhook_deliveries_pending ON server_ingest_webhook_deliveries (server_id) WHERE status = 'pending';
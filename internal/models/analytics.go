@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// ViewerSession es una sesión de reproducción leída para las analíticas
+type ViewerSession struct {
+	ClientIP        string     `json:"client_ip"`
+	StreamName      string     `json:"stream_name"`
+	ConnectedAt     time.Time  `json:"connected_at"`
+	DisconnectedAt  *time.Time `json:"disconnected_at"`
+	DurationSeconds *int       `json:"duration_seconds"`
+	CountryCode     string     `json:"country_code"`
+	ASN             uint       `json:"asn"`
+	ASOrg           string     `json:"as_org"`
+	ReferrerDomain  string     `json:"referrer_domain"`
+	ChannelID       string     `json:"channel_id"`
+	OrganizationID  string     `json:"organization_id"`
+}
+
+// StreamClientsSample es el número de clientes de un stream en una muestra
+// del recolector de métricas
+type StreamClientsSample struct {
+	ServerID   string    `json:"server_id"`
+	StreamName string    `json:"stream_name"`
+	Timestamp  time.Time `json:"timestamp"`
+	Clients    int       `json:"clients"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// LiveServer son los totales del servidor en un ciclo del recolector
+type LiveServer struct {
+	ServerID     string  `json:"server_id"`
+	ServerIP     string  `json:"server_ip"`
+	CPU          float64 `json:"cpu"`
+	MemoryMB     int64   `json:"memory_mb"`
+	TotalStreams int     `json:"total_streams"`
+	Connections  int     `json:"connections"`
+	Publishers   int     `json:"publishers"`
+	Players      int     `json:"players"`
+}
+
+// LiveStream es un stream en un ciclo del recolector; ChannelID queda vacío
+// si la clave no se resolvió
+type LiveStream struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	App        string `json:"app"`
+	ChannelID  string `json:"channel_id,omitempty"`
+	Clients    int    `json:"clients"`
+	RecvKbps   int    `json:"recv_kbps"`
+	SendKbps   int    `json:"send_kbps"`
+	IsPublish  bool   `json:"is_publish"`
+	VideoCodec string `json:"video_codec"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+}
+
+// LiveSnapshot es el estado del servidor que arma el recolector en cada ciclo
+type LiveSnapshot struct {
+	Time    time.Time    `json:"time"`
+	Server  LiveServer   `json:"server"`
+	Streams []LiveStream `json:"streams"`
+}
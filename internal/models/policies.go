@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Valores de ChannelPolicy.EmptyReferrer
+const (
+	EmptyReferrerAllow = "allow"
+	EmptyReferrerDeny  = "deny"
+)
+
+// ChannelPolicy restringe por país quién puede ver o emitir un canal y desde
+// qué dominios se puede embeber. Con AllowedCountries vacío se admite
+// cualquier país no bloqueado; con AllowedDomains vacío, cualquier dominio.
+type ChannelPolicy struct {
+	ChannelID        string   `json:"channel_id"`
+	AllowedCountries []string `json:"allowed_countries"`
+	BlockedCountries []string `json:"blocked_countries"`
+	// BlockUnknown rechaza las IPs sin país conocido (privadas o sin base GeoIP)
+	BlockUnknown bool `json:"block_unknown"`
+	// ApplyToPublish aplica también las listas de países en on_publish
+	ApplyToPublish bool `json:"apply_to_publish"`
+	// AllowedDomains admite "example.com" y "*.example.com" (subdominios)
+	AllowedDomains []string `json:"allowed_domains"`
+	// EmptyReferrer decide si se admite un play sin pageUrl (apps, players
+	// nativos) cuando hay AllowedDomains: allow o deny
+	EmptyReferrer string    `json:"empty_referrer"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
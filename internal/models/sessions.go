@@ -0,0 +1,28 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// RowID es el id de una fila: numérico en PostgreSQL y Supabase, texto en el
+// backend en memoria.
+type RowID string
+
+func (id *RowID) UnmarshalJSON(b []byte) error {
+	*id = RowID(strings.Trim(string(b), `"`))
+	return nil
+}
+
+// OpenSession es una fila de server_ingest_client_connections sin
+// disconnected_at
+type OpenSession struct {
+	ID          RowID     `json:"id"`
+	ClientID    string    `json:"client_id"`
+	ClientIP    string    `json:"client_ip"`
+	ClientType  string    `json:"client_type"`
+	StreamID    string    `json:"stream_id"`
+	StreamName  string    `json:"stream_name"`
+	App         string    `json:"app"`
+	ConnectedAt time.Time `json:"connected_at"`
+}
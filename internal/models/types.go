@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type SRSCallback struct {
 	Action    string `json:"action"`
 	App       string `json:"app"`
@@ -74,4 +76,30 @@ type ServerSummary struct {
 	Publishers   int    `json:"publishers"`
 	Players      int    `json:"players"`
 	TotalClients int    `json:"total_clients"`
-}
\ No newline at end of file
+}
+
+// Plan define los límites de una organización. Un límite en 0 es ilimitado.
+type Plan struct {
+	ID                   string  `json:"id"`
+	Name                 string  `json:"name"`
+	MaxConcurrentStreams int     `json:"max_concurrent_streams"`
+	MaxConcurrentViewers int     `json:"max_concurrent_viewers"`
+	MonthlyStreamHours   float64 `json:"monthly_stream_hours"`
+	MonthlyBandwidthGB   float64 `json:"monthly_bandwidth_gb"`
+}
+
+// TenantUsage acumula el consumo mensual de una organización en un servidor.
+type TenantUsage struct {
+	OrganizationID string `json:"organization_id"`
+	Month          string `json:"month"`
+	ServerID       string `json:"server_id"`
+	StreamSeconds  int64  `json:"stream_seconds"`
+	BandwidthBytes int64  `json:"bandwidth_bytes"`
+}
+
+// GraceKey es una clave rotada que sigue aceptándose hasta ExpiresAt
+type GraceKey struct {
+	ChannelID string    `json:"channel_id"`
+	StreamKey string    `json:"stream_key"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
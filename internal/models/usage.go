@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Alcance de una fila de consumo diario
+const (
+	UsageScopeChannel      = "channel"
+	UsageScopeOrganization = "organization"
+)
+
+// UsageSample es una muestra del recolector leída para el consumo diario
+type UsageSample struct {
+	ServerID       string    `json:"server_id"`
+	StreamName     string    `json:"stream_name"`
+	ChannelID      string    `json:"channel_id"`
+	OrganizationID string    `json:"organization_id"`
+	Timestamp      time.Time `json:"timestamp"`
+	SendKbps       int       `json:"send_kbps"`
+	IsPublishing   bool      `json:"is_publishing"`
+}
+
+// DailyUsage es el consumo de un canal o de una organización en un día UTC.
+// Las filas de organización tienen ChannelID vacío.
+type DailyUsage struct {
+	Day            string `json:"day"`
+	Scope          string `json:"scope"`
+	ChannelID      string `json:"channel_id"`
+	OrganizationID string `json:"organization_id"`
+	IngestSeconds  int64  `json:"ingest_seconds"`
+	ViewerSeconds  int64  `json:"viewer_seconds"`
+	EgressBytes    int64  `json:"egress_bytes"`
+	PeakConcurrent int    `json:"peak_concurrent"`
+	Views          int    `json:"views"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Eventos que se pueden suscribir en un webhook
+const (
+	WebhookEventChannelLive    = "channel.live"
+	WebhookEventChannelOffline = "channel.offline"
+	WebhookEventSessionStarted = "session.started"
+	WebhookEventSessionEnded   = "session.ended"
+	// WebhookEventTest solo lo envía /webhooks/{id}/test
+	WebhookEventTest = "webhook.test"
+)
+
+var WebhookEventTypes = []string{
+	WebhookEventChannelLive,
+	WebhookEventChannelOffline,
+	WebhookEventSessionStarted,
+	WebhookEventSessionEnded,
+}
+
+// Estados de una entrega
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	// WebhookDeliveryFailed es un envío de prueba que no llegó; no se reintenta
+	WebhookDeliveryFailed = "failed"
+	// WebhookDeliveryDead agotó los reintentos
+	WebhookDeliveryDead = "dead"
+)
+
+// Webhook es una suscripción de un canal o de una organización (todos sus
+// canales): uno de los dos queda vacío
+type Webhook struct {
+	ID             string `json:"id"`
+	OrganizationID string `json:"organization_id"`
+	ChannelID      string `json:"channel_id"`
+	URL            string `json:"url"`
+	// Secret firma los envíos; la API solo lo devuelve al crearlo o cambiarlo
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery es un envío de un evento a un webhook y su registro
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	WebhookID      string          `json:"webhook_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	ServerID       string          `json:"server_id"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"response_status"`
+	LastError      *string         `json:"last_error"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
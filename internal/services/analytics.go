@@ -0,0 +1,604 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"srs-backend/internal/logging"
+	"srs-backend/internal/models"
+	"srs-backend/internal/storage"
+)
+
+// Límites de las consultas de analíticas
+const (
+	MaxAnalyticsRange   = 31 * 24 * time.Hour
+	MinAnalyticsStep    = time.Minute
+	MaxAnalyticsBuckets = 1440
+	// Filas por consulta; con más sesiones el informe se marca truncated
+	analyticsRowLimit = 50000
+	// Redes (ASN) con más viewers incluidas en GeoReport
+	maxGeoNetworks = 50
+	// Dominios con más viewers incluidos en DomainReport
+	maxReportDomains = 100
+)
+
+var ErrInvalidWindow = errors.New("ventana de analíticas inválida")
+
+// Límites inferiores de los tramos de retención, en segundos
+var retentionEdges = []int{0, 60, 5 * 60, 15 * 60, 30 * 60, 60 * 60, 2 * 60 * 60}
+
+// AnalyticsWindow es el intervalo [From, To) de un informe y la resolución
+// de su curva de concurrencia.
+type AnalyticsWindow struct {
+	From time.Time
+	To   time.Time
+	Step time.Duration
+}
+
+// Validate comprueba los límites de rango y de número de tramos
+func (w AnalyticsWindow) Validate() error {
+	switch {
+	case !w.To.After(w.From):
+		return fmt.Errorf("%w: from debe ser anterior a to", ErrInvalidWindow)
+	case w.To.Sub(w.From) > MaxAnalyticsRange:
+		return fmt.Errorf("%w: el rango máximo es de 31 días", ErrInvalidWindow)
+	case w.Step < MinAnalyticsStep:
+		return fmt.Errorf("%w: la resolución mínima es 1m", ErrInvalidWindow)
+	case int(w.To.Sub(w.From)/w.Step) > MaxAnalyticsBuckets:
+		return fmt.Errorf("%w: demasiados tramos, aumentar resolution", ErrInvalidWindow)
+	}
+	return nil
+}
+
+// AnalyticsReport resume la audiencia de un stream o canal en una ventana
+type AnalyticsReport struct {
+	ChannelID  string    `json:"channel_id,omitempty"`
+	StreamHash string    `json:"stream_hash,omitempty"`
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	Resolution string    `json:"resolution"`
+	// Viewers únicos por hash de IP; las IPs no salen del backend
+	UniqueViewers     int   `json:"unique_viewers"`
+	Sessions          int   `json:"sessions"`
+	TotalWatchSeconds int64 `json:"total_watch_seconds"`
+	AvgWatchSeconds   int64 `json:"avg_watch_seconds"`
+	PeakViewers       int   `json:"peak_viewers"`
+	// Las sesiones superaron el límite de filas y el informe es parcial
+	Truncated bool              `json:"truncated"`
+	Timeline  []TimelinePoint   `json:"timeline"`
+	Retention []RetentionBucket `json:"retention"`
+}
+
+// TimelinePoint es un tramo de la curva de concurrencia. SRSClients es el
+// máximo de clientes que el recolector vio en SRS (incluye al publicador),
+// sumado entre servidores.
+type TimelinePoint struct {
+	Time        time.Time `json:"time"`
+	PeakViewers int       `json:"peak_viewers"`
+	AvgViewers  float64   `json:"avg_viewers"`
+	SRSClients  int       `json:"srs_clients"`
+}
+
+// RetentionBucket cuenta las sesiones iniciadas en la ventana por duración.
+// RetainedPercent es el porcentaje que duró al menos MinSeconds.
+type RetentionBucket struct {
+	MinSeconds      int     `json:"min_seconds"`
+	MaxSeconds      int     `json:"max_seconds,omitempty"`
+	Sessions        int     `json:"sessions"`
+	RetainedPercent float64 `json:"retained_percent"`
+}
+
+// GeoReport reparte la audiencia por país y por red (ASN). WatchSeconds se
+// recorta a la ventana como en AnalyticsReport.
+type GeoReport struct {
+	ChannelID     string          `json:"channel_id,omitempty"`
+	StreamHash    string          `json:"stream_hash,omitempty"`
+	From          time.Time       `json:"from"`
+	To            time.Time       `json:"to"`
+	UniqueViewers int             `json:"unique_viewers"`
+	Sessions      int             `json:"sessions"`
+	Truncated     bool            `json:"truncated"`
+	Countries     []CountryBucket `json:"countries"`
+	Networks      []NetworkBucket `json:"networks"`
+}
+
+type CountryBucket struct {
+	CountryCode   string `json:"country_code"`
+	UniqueViewers int    `json:"unique_viewers"`
+	Sessions      int    `json:"sessions"`
+	WatchSeconds  int64  `json:"watch_seconds"`
+}
+
+type NetworkBucket struct {
+	ASN           uint   `json:"asn"`
+	ASOrg         string `json:"as_org"`
+	UniqueViewers int    `json:"unique_viewers"`
+	Sessions      int    `json:"sessions"`
+	WatchSeconds  int64  `json:"watch_seconds"`
+}
+
+// DomainReport reparte la audiencia por dominio de la página que embebe el
+// player. El dominio vacío agrupa apps, players nativos y HLS sin pageUrl.
+type DomainReport struct {
+	ChannelID     string         `json:"channel_id,omitempty"`
+	StreamHash    string         `json:"stream_hash,omitempty"`
+	From          time.Time      `json:"from"`
+	To            time.Time      `json:"to"`
+	UniqueViewers int            `json:"unique_viewers"`
+	Sessions      int            `json:"sessions"`
+	Truncated     bool           `json:"truncated"`
+	Domains       []DomainBucket `json:"domains"`
+}
+
+type DomainBucket struct {
+	Domain        string `json:"domain"`
+	UniqueViewers int    `json:"unique_viewers"`
+	Sessions      int    `json:"sessions"`
+	WatchSeconds  int64  `json:"watch_seconds"`
+}
+
+// AnalyticsService calcula las analíticas de audiencia a partir de
+// server_ingest_client_connections y server_ingest_stream_metrics.
+type AnalyticsService struct {
+	store *storage.Store
+}
+
+func NewAnalyticsService(store *storage.Store) *AnalyticsService {
+	return &AnalyticsService{store: store}
+}
+
+// StreamReport calcula el informe de un stream por su nombre en SRS
+func (a *AnalyticsService) StreamReport(ctx context.Context, streamName string, window AnalyticsWindow) (*AnalyticsReport, error) {
+	ctx, span := tracer.Start(ctx, "analytics.stream")
+	defer span.End()
+
+	views, truncated, err := a.store.Sessions.ListViews(ctx, "stream_name", streamName, window.From, window.To, analyticsRowLimit)
+	if err != nil {
+		return nil, err
+	}
+	samples, err := a.store.Metrics.ListStreamClients(ctx, streamName, window.From, window.To, analyticsRowLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	report := buildReport(views, samples, window, time.Now().UTC())
+	report.StreamHash = logging.StreamHash(streamName)
+	report.Truncated = truncated
+	return report, nil
+}
+
+// ChannelReport calcula el informe de un canal. Incluye todas las claves con
+// que emitió en la ventana, también las rotadas.
+func (a *AnalyticsService) ChannelReport(ctx context.Context, channelID string, window AnalyticsWindow) (*AnalyticsReport, error) {
+	ctx, span := tracer.Start(ctx, "analytics.channel")
+	defer span.End()
+
+	views, truncated, currentKey, err := a.channelViews(ctx, channelID, window)
+	if err != nil {
+		return nil, err
+	}
+
+	streamNames := map[string]bool{}
+	if currentKey != "" {
+		streamNames[currentKey] = true
+	}
+	for _, v := range views {
+		if v.StreamName != "" {
+			streamNames[v.StreamName] = true
+		}
+	}
+	var samples []models.StreamClientsSample
+	for name := range streamNames {
+		rows, err := a.store.Metrics.ListStreamClients(ctx, name, window.From, window.To, analyticsRowLimit)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, rows...)
+	}
+
+	report := buildReport(views, samples, window, time.Now().UTC())
+	report.ChannelID = channelID
+	report.Truncated = truncated
+	return report, nil
+}
+
+// StreamGeo reparte la audiencia de un stream por país y por red
+func (a *AnalyticsService) StreamGeo(ctx context.Context, streamName string, window AnalyticsWindow) (*GeoReport, error) {
+	ctx, span := tracer.Start(ctx, "analytics.stream_geo")
+	defer span.End()
+
+	views, truncated, err := a.store.Sessions.ListViews(ctx, "stream_name", streamName, window.From, window.To, analyticsRowLimit)
+	if err != nil {
+		return nil, err
+	}
+	report := buildGeoReport(views, window, time.Now().UTC())
+	report.StreamHash = logging.StreamHash(streamName)
+	report.Truncated = truncated
+	return report, nil
+}
+
+// ChannelGeo reparte la audiencia de un canal por país y por red
+func (a *AnalyticsService) ChannelGeo(ctx context.Context, channelID string, window AnalyticsWindow) (*GeoReport, error) {
+	ctx, span := tracer.Start(ctx, "analytics.channel_geo")
+	defer span.End()
+
+	views, truncated, _, err := a.channelViews(ctx, channelID, window)
+	if err != nil {
+		return nil, err
+	}
+	report := buildGeoReport(views, window, time.Now().UTC())
+	report.ChannelID = channelID
+	report.Truncated = truncated
+	return report, nil
+}
+
+// StreamDomains reparte la audiencia de un stream por dominio de origen
+func (a *AnalyticsService) StreamDomains(ctx context.Context, streamName string, window AnalyticsWindow) (*DomainReport, error) {
+	ctx, span := tracer.Start(ctx, "analytics.stream_domains")
+	defer span.End()
+
+	views, truncated, err := a.store.Sessions.ListViews(ctx, "stream_name", streamName, window.From, window.To, analyticsRowLimit)
+	if err != nil {
+		return nil, err
+	}
+	report := buildDomainReport(views, window, time.Now().UTC())
+	report.StreamHash = logging.StreamHash(streamName)
+	report.Truncated = truncated
+	return report, nil
+}
+
+// ChannelDomains reparte la audiencia de un canal por dominio de origen
+func (a *AnalyticsService) ChannelDomains(ctx context.Context, channelID string, window AnalyticsWindow) (*DomainReport, error) {
+	ctx, span := tracer.Start(ctx, "analytics.channel_domains")
+	defer span.End()
+
+	views, truncated, _, err := a.channelViews(ctx, channelID, window)
+	if err != nil {
+		return nil, err
+	}
+	report := buildDomainReport(views, window, time.Now().UTC())
+	report.ChannelID = channelID
+	report.Truncated = truncated
+	return report, nil
+}
+
+// channelViews devuelve también la clave actual del canal
+func (a *AnalyticsService) channelViews(ctx context.Context, channelID string, window AnalyticsWindow) ([]models.ViewerSession, bool, string, error) {
+	currentKey, exists, err := a.store.Channels.GetStreamKey(ctx, channelID)
+	if err != nil {
+		return nil, false, "", err
+	}
+	if !exists {
+		return nil, false, "", ErrChannelNotFound
+	}
+
+	views, truncated, err := a.store.Sessions.ListViews(ctx, "channel_id", channelID, window.From, window.To, analyticsRowLimit)
+	if err != nil {
+		return nil, false, "", err
+	}
+	return views, truncated, currentKey, nil
+}
+
+// buildReport recorta cada sesión a la ventana para el tiempo visto y la
+// concurrencia. La retención usa la duración completa de las sesiones
+// iniciadas en la ventana; las abiertas cuentan hasta now.
+func buildReport(views []models.ViewerSession, samples []models.StreamClientsSample, window AnalyticsWindow, now time.Time) *AnalyticsReport {
+	start := window.From.Truncate(window.Step)
+	buckets := int((window.To.Sub(start) + window.Step - 1) / window.Step)
+
+	report := &AnalyticsReport{
+		From:       window.From,
+		To:         window.To,
+		Resolution: window.Step.String(),
+		Sessions:   len(views),
+		Timeline:   make([]TimelinePoint, buckets),
+	}
+	for i := range report.Timeline {
+		report.Timeline[i].Time = start.Add(time.Duration(i) * window.Step)
+	}
+
+	type edge struct {
+		at    time.Time
+		delta int
+	}
+	var edges []edge
+	viewers := make(map[[sha256.Size]byte]bool)
+	watchSeconds := make([]float64, buckets)
+	retention := make([]int, len(retentionEdges))
+	started := 0
+
+	for _, v := range views {
+		if v.ClientIP != "" {
+			viewers[sha256.Sum256([]byte(v.ClientIP))] = true
+		}
+
+		end := sessionEnd(v, now)
+		if !v.ConnectedAt.Before(window.From) {
+			started++
+			seconds := int(end.Sub(v.ConnectedAt).Seconds())
+			for i := len(retentionEdges) - 1; i >= 0; i-- {
+				if seconds >= retentionEdges[i] {
+					retention[i]++
+					break
+				}
+			}
+		}
+
+		from, to, ok := window.clip(v.ConnectedAt, end)
+		if !ok {
+			continue
+		}
+		report.TotalWatchSeconds += int64(to.Sub(from).Seconds())
+		edges = append(edges, edge{from, 1}, edge{to, -1})
+		for i := int(from.Sub(start) / window.Step); i < buckets; i++ {
+			bucketStart := report.Timeline[i].Time
+			bucketEnd := bucketStart.Add(window.Step)
+			if !to.After(bucketStart) {
+				break
+			}
+			watchSeconds[i] += minTime(to, bucketEnd).Sub(maxTime(from, bucketStart)).Seconds()
+		}
+	}
+
+	report.UniqueViewers = len(viewers)
+	if len(views) > 0 {
+		report.AvgWatchSeconds = report.TotalWatchSeconds / int64(len(views))
+	}
+	for i := range report.Timeline {
+		report.Timeline[i].AvgViewers = roundTo(watchSeconds[i]/window.Step.Seconds(), 2)
+	}
+
+	// Barrido de entradas y salidas. Los eventos de un mismo instante se
+	// aplican juntos para no contar dos veces a un viewer que reconecta.
+	sort.Slice(edges, func(i, j int) bool { return edges[i].at.Before(edges[j].at) })
+	current, bucket := 0, 0
+	for next := 0; next < len(edges); {
+		at := edges[next].at
+		i := int(at.Sub(start) / window.Step)
+		if i >= buckets {
+			break
+		}
+		// Un tramo sin eventos al inicio mantiene la concurrencia anterior
+		for ; bucket < i; bucket++ {
+			if bucket+1 < i || at.After(report.Timeline[i].Time) {
+				report.Timeline[bucket+1].PeakViewers = max(report.Timeline[bucket+1].PeakViewers, current)
+			}
+		}
+		for ; next < len(edges) && edges[next].at.Equal(at); next++ {
+			current += edges[next].delta
+		}
+		report.Timeline[i].PeakViewers = max(report.Timeline[i].PeakViewers, current)
+		report.PeakViewers = max(report.PeakViewers, current)
+	}
+	for ; bucket < buckets-1; bucket++ {
+		report.Timeline[bucket+1].PeakViewers = max(report.Timeline[bucket+1].PeakViewers, current)
+	}
+
+	// Máximo por servidor y tramo, sumado entre servidores
+	perServer := make(map[string][]int)
+	for _, s := range samples {
+		if s.Timestamp.Before(start) {
+			continue
+		}
+		i := int(s.Timestamp.Sub(start) / window.Step)
+		if i >= buckets {
+			continue
+		}
+		if perServer[s.ServerID] == nil {
+			perServer[s.ServerID] = make([]int, buckets)
+		}
+		perServer[s.ServerID][i] = max(perServer[s.ServerID][i], s.Clients)
+	}
+	for _, clients := range perServer {
+		for i, n := range clients {
+			report.Timeline[i].SRSClients += n
+		}
+	}
+
+	for i, edgeSeconds := range retentionEdges {
+		bucket := RetentionBucket{MinSeconds: edgeSeconds, Sessions: retention[i]}
+		if i+1 < len(retentionEdges) {
+			bucket.MaxSeconds = retentionEdges[i+1]
+		}
+		if started > 0 {
+			retained := 0
+			for _, n := range retention[i:] {
+				retained += n
+			}
+			bucket.RetainedPercent = roundTo(float64(retained)/float64(started)*100, 2)
+		}
+		report.Retention = append(report.Retention, bucket)
+	}
+	return report
+}
+
+// buildGeoReport agrupa por country_code y por asn. Las sesiones sin datos
+// GeoIP quedan al final, en el grupo con código vacío o asn 0.
+func buildGeoReport(views []models.ViewerSession, window AnalyticsWindow, now time.Time) *GeoReport {
+	report := &GeoReport{From: window.From, To: window.To, Sessions: len(views)}
+
+	// Un país (asn 0) o una red (country vacío)
+	type groupKey struct {
+		country string
+		asn     uint
+	}
+	type group struct {
+		viewers      map[[sha256.Size]byte]bool
+		sessions     int
+		watchSeconds int64
+	}
+	add := func(groups map[groupKey]*group, key groupKey, viewer [sha256.Size]byte, hasViewer bool, seconds int64) {
+		g, ok := groups[key]
+		if !ok {
+			g = &group{viewers: make(map[[sha256.Size]byte]bool)}
+			groups[key] = g
+		}
+		if hasViewer {
+			g.viewers[viewer] = true
+		}
+		g.sessions++
+		g.watchSeconds += seconds
+	}
+
+	viewers := make(map[[sha256.Size]byte]bool)
+	countries := make(map[groupKey]*group)
+	networks := make(map[groupKey]*group)
+	asOrgs := make(map[uint]string)
+	for _, v := range views {
+		viewer := sha256.Sum256([]byte(v.ClientIP))
+		hasViewer := v.ClientIP != ""
+		if hasViewer {
+			viewers[viewer] = true
+		}
+
+		var seconds int64
+		if from, to, ok := window.clip(v.ConnectedAt, sessionEnd(v, now)); ok {
+			seconds = int64(to.Sub(from).Seconds())
+		}
+		add(countries, groupKey{country: v.CountryCode}, viewer, hasViewer, seconds)
+		add(networks, groupKey{asn: v.ASN}, viewer, hasViewer, seconds)
+		if v.ASOrg != "" {
+			asOrgs[v.ASN] = v.ASOrg
+		}
+	}
+	report.UniqueViewers = len(viewers)
+
+	for key, g := range countries {
+		report.Countries = append(report.Countries, CountryBucket{
+			CountryCode:   key.country,
+			UniqueViewers: len(g.viewers),
+			Sessions:      g.sessions,
+			WatchSeconds:  g.watchSeconds,
+		})
+	}
+	sort.Slice(report.Countries, func(i, j int) bool {
+		a, b := report.Countries[i], report.Countries[j]
+		if (a.CountryCode == "") != (b.CountryCode == "") {
+			return b.CountryCode == ""
+		}
+		if a.UniqueViewers != b.UniqueViewers {
+			return a.UniqueViewers > b.UniqueViewers
+		}
+		return a.CountryCode < b.CountryCode
+	})
+
+	for key, g := range networks {
+		report.Networks = append(report.Networks, NetworkBucket{
+			ASN:           key.asn,
+			ASOrg:         asOrgs[key.asn],
+			UniqueViewers: len(g.viewers),
+			Sessions:      g.sessions,
+			WatchSeconds:  g.watchSeconds,
+		})
+	}
+	sort.Slice(report.Networks, func(i, j int) bool {
+		a, b := report.Networks[i], report.Networks[j]
+		if (a.ASN == 0) != (b.ASN == 0) {
+			return b.ASN == 0
+		}
+		if a.UniqueViewers != b.UniqueViewers {
+			return a.UniqueViewers > b.UniqueViewers
+		}
+		return a.ASN < b.ASN
+	})
+	if len(report.Networks) > maxGeoNetworks {
+		report.Networks = report.Networks[:maxGeoNetworks]
+	}
+	return report
+}
+
+// buildDomainReport agrupa por referrer_domain; las sesiones sin dominio
+// quedan al final
+func buildDomainReport(views []models.ViewerSession, window AnalyticsWindow, now time.Time) *DomainReport {
+	report := &DomainReport{From: window.From, To: window.To, Sessions: len(views)}
+
+	type group struct {
+		viewers      map[[sha256.Size]byte]bool
+		sessions     int
+		watchSeconds int64
+	}
+	viewers := make(map[[sha256.Size]byte]bool)
+	domains := make(map[string]*group)
+	for _, v := range views {
+		g, ok := domains[v.ReferrerDomain]
+		if !ok {
+			g = &group{viewers: make(map[[sha256.Size]byte]bool)}
+			domains[v.ReferrerDomain] = g
+		}
+		if v.ClientIP != "" {
+			viewer := sha256.Sum256([]byte(v.ClientIP))
+			viewers[viewer] = true
+			g.viewers[viewer] = true
+		}
+		g.sessions++
+		if from, to, ok := window.clip(v.ConnectedAt, sessionEnd(v, now)); ok {
+			g.watchSeconds += int64(to.Sub(from).Seconds())
+		}
+	}
+	report.UniqueViewers = len(viewers)
+
+	for domain, g := range domains {
+		report.Domains = append(report.Domains, DomainBucket{
+			Domain:        domain,
+			UniqueViewers: len(g.viewers),
+			Sessions:      g.sessions,
+			WatchSeconds:  g.watchSeconds,
+		})
+	}
+	sort.Slice(report.Domains, func(i, j int) bool {
+		a, b := report.Domains[i], report.Domains[j]
+		if (a.Domain == "") != (b.Domain == "") {
+			return b.Domain == ""
+		}
+		if a.UniqueViewers != b.UniqueViewers {
+			return a.UniqueViewers > b.UniqueViewers
+		}
+		return a.Domain < b.Domain
+	})
+	if len(report.Domains) > maxReportDomains {
+		report.Domains = report.Domains[:maxReportDomains]
+	}
+	return report
+}
+
+// clip recorta una sesión a la ventana; ok es false si no se solapan
+func (w AnalyticsWindow) clip(start, end time.Time) (time.Time, time.Time, bool) {
+	from, to := maxTime(start, w.From), minTime(end, w.To)
+	return from, to, to.After(from)
+}
+
+// sessionEnd usa disconnected_at, o connected_at + duration_seconds si solo
+// se guardó la duración; una sesión abierta llega hasta now.
+func sessionEnd(v models.ViewerSession, now time.Time) time.Time {
+	switch {
+	case v.DisconnectedAt != nil:
+		return *v.DisconnectedAt
+	case v.DurationSeconds != nil:
+		return v.ConnectedAt.Add(time.Duration(*v.DurationSeconds) * time.Second)
+	}
+	return now
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func roundTo(value float64, decimals int) float64 {
+	factor := math.Pow(10, float64(decimals))
+	return math.Round(value*factor) / factor
+}
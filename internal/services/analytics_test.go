@@ -0,0 +1,184 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"srs-backend/internal/models"
+)
+
+func TestAnalyticsWindowValidate(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		window  AnalyticsWindow
+		wantErr bool
+	}{
+		{"una hora", AnalyticsWindow{from, from.Add(time.Hour), time.Minute}, false},
+		{"ventana vacía", AnalyticsWindow{from, from, time.Minute}, true},
+		{"to antes que from", AnalyticsWindow{from, from.Add(-time.Hour), time.Minute}, true},
+		{"rango máximo", AnalyticsWindow{from, from.Add(MaxAnalyticsRange), time.Hour}, false},
+		{"rango máximo más un segundo", AnalyticsWindow{from, from.Add(MaxAnalyticsRange + time.Second), time.Hour}, true},
+		{"resolución mínima", AnalyticsWindow{from, from.Add(time.Hour), MinAnalyticsStep}, false},
+		{"resolución bajo el mínimo", AnalyticsWindow{from, from.Add(time.Hour), MinAnalyticsStep - time.Second}, true},
+		{"máximo de tramos", AnalyticsWindow{from, from.Add(MaxAnalyticsBuckets * time.Minute), time.Minute}, false},
+		{"un tramo de más", AnalyticsWindow{from, from.Add((MaxAnalyticsBuckets + 1) * time.Minute), time.Minute}, true},
+	}
+	for _, tt := range tests {
+		err := tt.window.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidWindow) {
+			t.Errorf("%s: %v no es ErrInvalidWindow", tt.name, err)
+		}
+	}
+}
+
+// Ventana 10:05-11:00 en tramos de 15m: el primero empieza a las 10:00
+var reportTestWindow = AnalyticsWindow{
+	From: time.Date(2026, 1, 1, 10, 5, 0, 0, time.UTC),
+	To:   time.Date(2026, 1, 1, 11, 0, 0, 0, time.UTC),
+	Step: 15 * time.Minute,
+}
+
+func reportTestView(from, to string) models.ViewerSession {
+	at := func(clock string) time.Time {
+		parsed, err := time.Parse("15:04", clock)
+		if err != nil {
+			panic(err)
+		}
+		return time.Date(2026, 1, 1, parsed.Hour(), parsed.Minute(), 0, 0, time.UTC)
+	}
+	v := models.ViewerSession{ClientIP: "190.237.26.0", ConnectedAt: at(from)}
+	if to != "" {
+		end := at(to)
+		v.DisconnectedAt = &end
+	}
+	return v
+}
+
+func TestBuildReportBuckets(t *testing.T) {
+	now := time.Date(2026, 1, 1, 10, 50, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		views     []models.ViewerSession
+		wantPeaks []int
+		wantAvg   []float64
+		wantWatch int64
+	}{
+		{
+			name:      "ventana sin sesiones",
+			wantPeaks: []int{0, 0, 0, 0},
+			wantAvg:   []float64{0, 0, 0, 0},
+		},
+		{
+			name:      "sesión que cruza el borde de un tramo",
+			views:     []models.ViewerSession{reportTestView("10:14", "10:16")},
+			wantPeaks: []int{1, 1, 0, 0},
+			wantAvg:   []float64{0.07, 0.07, 0, 0},
+			wantWatch: 120,
+		},
+		{
+			name:      "termina justo en el borde",
+			views:     []models.ViewerSession{reportTestView("10:20", "10:30")},
+			wantPeaks: []int{0, 1, 0, 0},
+			wantAvg:   []float64{0, 0.67, 0, 0},
+			wantWatch: 600,
+		},
+		{
+			name:      "empieza antes de la ventana",
+			views:     []models.ViewerSession{reportTestView("09:50", "10:10")},
+			wantPeaks: []int{1, 0, 0, 0},
+			wantAvg:   []float64{0.33, 0, 0, 0},
+			wantWatch: 300,
+		},
+		{
+			name:      "abierta hasta now",
+			views:     []models.ViewerSession{reportTestView("10:10", "")},
+			wantPeaks: []int{1, 1, 1, 1},
+			wantAvg:   []float64{0.33, 1, 1, 0.33},
+			wantWatch: 2400,
+		},
+		{
+			name:      "reconexión en el mismo instante",
+			views:     []models.ViewerSession{reportTestView("10:20", "10:40"), reportTestView("10:40", "10:50")},
+			wantPeaks: []int{0, 1, 1, 1},
+			wantAvg:   []float64{0, 0.67, 1, 0.33},
+			wantWatch: 1800,
+		},
+	}
+	for _, tt := range tests {
+		report := buildReport(tt.views, nil, reportTestWindow, now)
+
+		var times []string
+		var peaks []int
+		var avg []float64
+		for _, point := range report.Timeline {
+			times = append(times, point.Time.Format("15:04"))
+			peaks = append(peaks, point.PeakViewers)
+			avg = append(avg, point.AvgViewers)
+		}
+		if want := []string{"10:00", "10:15", "10:30", "10:45"}; !slices.Equal(times, want) {
+			t.Errorf("%s: tramos %v, want %v", tt.name, times, want)
+		}
+		if !slices.Equal(peaks, tt.wantPeaks) {
+			t.Errorf("%s: peak_viewers %v, want %v", tt.name, peaks, tt.wantPeaks)
+		}
+		if !slices.Equal(avg, tt.wantAvg) {
+			t.Errorf("%s: avg_viewers %v, want %v", tt.name, avg, tt.wantAvg)
+		}
+		if report.TotalWatchSeconds != tt.wantWatch {
+			t.Errorf("%s: total_watch_seconds %d, want %d", tt.name, report.TotalWatchSeconds, tt.wantWatch)
+		}
+		if report.Sessions != len(tt.views) {
+			t.Errorf("%s: sessions %d, want %d", tt.name, report.Sessions, len(tt.views))
+		}
+	}
+}
+
+func TestBuildReportEmptyRetention(t *testing.T) {
+	report := buildReport(nil, nil, reportTestWindow, reportTestWindow.To)
+	if report.UniqueViewers != 0 || report.PeakViewers != 0 || report.AvgWatchSeconds != 0 {
+		t.Errorf("informe vacío con audiencia: %+v", report)
+	}
+	if len(report.Retention) != len(retentionEdges) {
+		t.Fatalf("%d tramos de retención, want %d", len(report.Retention), len(retentionEdges))
+	}
+	for _, bucket := range report.Retention {
+		if bucket.Sessions != 0 || bucket.RetainedPercent != 0 {
+			t.Errorf("tramo de retención %+v, want vacío", bucket)
+		}
+	}
+}
+
+func TestBuildReportSRSClients(t *testing.T) {
+	at := func(hour, minute, second int) time.Time {
+		return time.Date(2026, 1, 1, hour, minute, second, 0, time.UTC)
+	}
+	samples := []models.StreamClientsSample{
+		{ServerID: "srv-a", Timestamp: at(10, 14, 59), Clients: 3},
+		// El borde del tramo pertenece al siguiente
+		{ServerID: "srv-a", Timestamp: at(10, 15, 0), Clients: 5},
+		// Máximo por servidor, no suma
+		{ServerID: "srv-a", Timestamp: at(10, 20, 0), Clients: 4},
+		// Suma entre servidores
+		{ServerID: "srv-b", Timestamp: at(10, 16, 0), Clients: 2},
+		// Fuera de los tramos
+		{ServerID: "srv-a", Timestamp: at(9, 59, 59), Clients: 9},
+		{ServerID: "srv-a", Timestamp: at(11, 0, 0), Clients: 9},
+	}
+
+	report := buildReport(nil, samples, reportTestWindow, reportTestWindow.To)
+	var got []int
+	for _, point := range report.Timeline {
+		got = append(got, point.SRSClients)
+	}
+	if want := []int{3, 7, 0, 0}; !slices.Equal(got, want) {
+		t.Errorf("srs_clients %v, want %v", got, want)
+	}
+}
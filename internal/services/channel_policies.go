@@ -0,0 +1,348 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"srs-backend/internal/models"
+	"srs-backend/internal/storage"
+	"srs-backend/internal/telemetry"
+)
+
+var ErrInvalidPolicy = errors.New("restricción inválida")
+
+// Motivos de rechazo guardados en los eventos geo_restricted y
+// referrer_restricted
+const (
+	geoRuleNotAllowed      = "not_allowed"
+	geoRuleBlocked         = "blocked"
+	geoRuleUnknown         = "unknown_country"
+	referrerRuleNotAllowed = "domain_not_allowed"
+	referrerRuleEmpty      = "empty_referrer"
+)
+
+type cachedPolicy struct {
+	policy    *models.ChannelPolicy
+	expiresAt time.Time
+}
+
+// ChannelPolicyService aplica la restricción geográfica de cada canal en
+// on_play y, si el canal lo pide, en on_publish, y los dominios autorizados
+// para embeber el player en on_play. Ante errores de base de datos no
+// bloquea (fail-open), igual que las cuotas.
+type ChannelPolicyService struct {
+	store    *storage.Store
+	geoip    *GeoIPService
+	serverID string
+	serverIP string
+	cacheTTL time.Duration
+
+	mu       sync.Mutex
+	policies map[string]cachedPolicy
+	// último evento por tipo, canal, IP y hook: un player que reintenta no
+	// genera un evento por intento
+	lastEventAt map[string]time.Time
+}
+
+func NewChannelPolicyService(store *storage.Store, geoip *GeoIPService, serverID, serverIP string, cacheTTL time.Duration) *ChannelPolicyService {
+	return &ChannelPolicyService{
+		store:       store,
+		geoip:       geoip,
+		serverID:    serverID,
+		serverIP:    serverIP,
+		cacheTTL:    cacheTTL,
+		policies:    make(map[string]cachedPolicy),
+		lastEventAt: make(map[string]time.Time),
+	}
+}
+
+// Get devuelve la restricción del canal (cacheada) o nil si no tiene
+func (p *ChannelPolicyService) Get(ctx context.Context, channelID string) (*models.ChannelPolicy, error) {
+	now := time.Now()
+	p.mu.Lock()
+	if cached, ok := p.policies[channelID]; ok && cached.expiresAt.After(now) {
+		p.mu.Unlock()
+		return cached.policy, nil
+	}
+	p.mu.Unlock()
+
+	policy, err := p.store.Channels.GetPolicy(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.policies[channelID] = cachedPolicy{policy: policy, expiresAt: now.Add(p.cacheTTL)}
+	p.mu.Unlock()
+	return policy, nil
+}
+
+// Set valida y guarda la restricción. Los códigos se normalizan a
+// mayúsculas; otros servidores la ven al expirar su caché.
+func (p *ChannelPolicyService) Set(ctx context.Context, policy models.ChannelPolicy) (*models.ChannelPolicy, error) {
+	_, exists, err := p.store.Channels.GetStreamKey(ctx, policy.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrChannelNotFound
+	}
+
+	if policy.AllowedCountries, err = normalizeCountries(policy.AllowedCountries); err != nil {
+		return nil, err
+	}
+	if policy.BlockedCountries, err = normalizeCountries(policy.BlockedCountries); err != nil {
+		return nil, err
+	}
+	if policy.AllowedDomains, err = normalizeDomains(policy.AllowedDomains); err != nil {
+		return nil, err
+	}
+	switch policy.EmptyReferrer {
+	case "":
+		policy.EmptyReferrer = models.EmptyReferrerAllow
+	case models.EmptyReferrerAllow, models.EmptyReferrerDeny:
+	default:
+		return nil, fmt.Errorf("%w: empty_referrer debe ser allow o deny", ErrInvalidPolicy)
+	}
+	policy.UpdatedAt = time.Now().UTC()
+
+	if err := p.store.Channels.UpsertPolicy(ctx, policy); err != nil {
+		return nil, err
+	}
+	p.forget(policy.ChannelID)
+	return &policy, nil
+}
+
+func (p *ChannelPolicyService) Delete(ctx context.Context, channelID string) error {
+	if err := p.store.Channels.DeletePolicy(ctx, channelID); err != nil {
+		return err
+	}
+	p.forget(channelID)
+	return nil
+}
+
+// AllowPlay comprueba el país del viewer y el dominio de la página que
+// embebe el player contra la restricción del canal
+func (p *ChannelPolicyService) AllowPlay(ctx context.Context, channelID, ip, clientID, pageURL string) bool {
+	policy := p.policy(ctx, "on_play", channelID)
+	if policy == nil {
+		return true
+	}
+	return p.allowCountry(ctx, "on_play", policy, ip, clientID) && p.allowReferrer(ctx, policy, ip, clientID, pageURL)
+}
+
+// AllowPublish solo restringe por país y si el canal tiene apply_to_publish
+func (p *ChannelPolicyService) AllowPublish(ctx context.Context, channelID, ip, clientID string) bool {
+	policy := p.policy(ctx, "on_publish", channelID)
+	if policy == nil || !policy.ApplyToPublish {
+		return true
+	}
+	return p.allowCountry(ctx, "on_publish", policy, ip, clientID)
+}
+
+// policy devuelve nil si no hay nada que comprobar
+func (p *ChannelPolicyService) policy(ctx context.Context, hook, channelID string) *models.ChannelPolicy {
+	if channelID == "" {
+		return nil
+	}
+	policy, err := p.Get(ctx, channelID)
+	if err != nil {
+		slog.WarnContext(ctx, "⚠️ Error obteniendo restricciones del canal, se permite", "hook", hook, "channel_id", channelID, "error", err)
+		return nil
+	}
+	return policy
+}
+
+func (p *ChannelPolicyService) allowCountry(ctx context.Context, hook string, policy *models.ChannelPolicy, ip, clientID string) bool {
+	channelID := policy.ChannelID
+	country := p.geoip.Lookup(ip).CountryCode
+	rule := geoRule(policy, country)
+	if rule == "" {
+		return true
+	}
+
+	slog.WarnContext(ctx, "🚫 Rechazado por restricción geográfica", "hook", hook, "channel_id", channelID, "country", country, "rule", rule)
+	telemetry.RecordGeoRejection(hook, country)
+	p.recordRejection(ctx, "geo_restricted", hook, channelID, ip, map[string]interface{}{
+		"channel_id":   channelID,
+		"hook":         hook,
+		"rule":         rule,
+		"country_code": country,
+		"client_ip":    ip,
+		"client_id":    clientID,
+	})
+	return false
+}
+
+// allowReferrer solo restringe si el canal tiene allowed_domains
+func (p *ChannelPolicyService) allowReferrer(ctx context.Context, policy *models.ChannelPolicy, ip, clientID, pageURL string) bool {
+	if len(policy.AllowedDomains) == 0 {
+		return true
+	}
+
+	domain := ReferrerDomain(pageURL)
+	rule := ""
+	switch {
+	case domain == "":
+		if policy.EmptyReferrer == models.EmptyReferrerDeny {
+			rule = referrerRuleEmpty
+		}
+	case !matchesDomain(policy.AllowedDomains, domain):
+		rule = referrerRuleNotAllowed
+	}
+	if rule == "" {
+		return true
+	}
+
+	slog.WarnContext(ctx, "🚫 Play rechazado por dominio no autorizado", "channel_id", policy.ChannelID, "referrer_domain", domain, "rule", rule)
+	telemetry.RecordReferrerRejection(rule)
+	p.recordRejection(ctx, "referrer_restricted", "on_play", policy.ChannelID, ip, map[string]interface{}{
+		"channel_id":      policy.ChannelID,
+		"hook":            "on_play",
+		"rule":            rule,
+		"referrer_domain": domain,
+		"client_ip":       ip,
+		"client_id":       clientID,
+	})
+	return false
+}
+
+// ReferrerDomain devuelve el host en minúsculas y sin puerto del pageUrl
+// de SRS, o "" si no hay. Solo se guarda el dominio: la ruta puede llevar
+// tokens.
+func ReferrerDomain(pageURL string) string {
+	pageURL = strings.TrimSpace(pageURL)
+	if pageURL == "" {
+		return ""
+	}
+	if !strings.Contains(pageURL, "://") {
+		pageURL = "http://" + pageURL
+	}
+	parsed, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+}
+
+// matchesDomain admite el dominio exacto o, con "*.example.com", cualquier
+// subdominio de example.com (no el propio example.com)
+func matchesDomain(patterns []string, domain string) bool {
+	for _, pattern := range patterns {
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+			if strings.HasSuffix(domain, suffix) {
+				return true
+			}
+			continue
+		}
+		if domain == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// geoRule devuelve el motivo de rechazo o "" si el país se admite
+func geoRule(policy *models.ChannelPolicy, country string) string {
+	switch {
+	case country == "":
+		if policy.BlockUnknown {
+			return geoRuleUnknown
+		}
+		return ""
+	case slices.Contains(policy.BlockedCountries, country):
+		return geoRuleBlocked
+	case len(policy.AllowedCountries) > 0 && !slices.Contains(policy.AllowedCountries, country):
+		return geoRuleNotAllowed
+	}
+	return ""
+}
+
+// recordRejection registra como máximo un evento por minuto por tipo,
+// canal, IP y hook
+func (p *ChannelPolicyService) recordRejection(ctx context.Context, eventType, hook, channelID, ip string, metadata map[string]interface{}) {
+	key := eventType + "|" + hook + "|" + channelID + "|" + ip
+	now := time.Now()
+	p.mu.Lock()
+	if last, ok := p.lastEventAt[key]; ok && now.Sub(last) < time.Minute {
+		p.mu.Unlock()
+		return
+	}
+	p.lastEventAt[key] = now
+	// Las entradas viejas no sirven para limitar y se descartan
+	for k, last := range p.lastEventAt {
+		if now.Sub(last) >= time.Minute {
+			delete(p.lastEventAt, k)
+		}
+	}
+	p.mu.Unlock()
+
+	metadata["server_id"] = p.serverID
+	message := fmt.Sprintf("%s rechazado por restricción geográfica en canal %s", hook, channelID)
+	if eventType == "referrer_restricted" {
+		message = fmt.Sprintf("%s rechazado por dominio no autorizado en canal %s", hook, channelID)
+	}
+	go p.store.Events.Record(context.WithoutCancel(ctx), p.serverID, p.serverIP, eventType, "warning", message, metadata)
+}
+
+func (p *ChannelPolicyService) forget(channelID string) {
+	p.mu.Lock()
+	delete(p.policies, channelID)
+	p.mu.Unlock()
+}
+
+// normalizeCountries exige códigos ISO 3166-1 alfa-2 y quita duplicados
+func normalizeCountries(codes []string) ([]string, error) {
+	normalized := make([]string, 0, len(codes))
+	for _, code := range codes {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+			return nil, fmt.Errorf("%w: %q no es un código de país ISO 3166-1 alfa-2", ErrInvalidPolicy, code)
+		}
+		if !slices.Contains(normalized, code) {
+			normalized = append(normalized, code)
+		}
+	}
+	return normalized, nil
+}
+
+// normalizeDomains acepta "example.com" o "*.example.com", en minúsculas y
+// sin duplicados
+func normalizeDomains(domains []string) ([]string, error) {
+	normalized := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+		host := strings.TrimPrefix(domain, "*.")
+		if !validHostname(host) {
+			return nil, fmt.Errorf("%w: %q no es un dominio válido, usar example.com o *.example.com", ErrInvalidPolicy, domain)
+		}
+		if !slices.Contains(normalized, domain) {
+			normalized = append(normalized, domain)
+		}
+	}
+	return normalized, nil
+}
+
+func validHostname(host string) bool {
+	if host == "" || len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"srs-backend/internal/models"
+)
+
+func TestGeoRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  models.ChannelPolicy
+		country string
+		want    string
+	}{
+		{"sin listas", models.ChannelPolicy{}, "ES", ""},
+		{"país permitido", models.ChannelPolicy{AllowedCountries: []string{"ES", "PT"}}, "PT", ""},
+		{"fuera de la lista permitida", models.ChannelPolicy{AllowedCountries: []string{"ES", "PT"}}, "FR", geoRuleNotAllowed},
+		{"país bloqueado", models.ChannelPolicy{BlockedCountries: []string{"RU"}}, "RU", geoRuleBlocked},
+		{"no bloqueado", models.ChannelPolicy{BlockedCountries: []string{"RU"}}, "ES", ""},
+		{"bloqueo antes que permiso", models.ChannelPolicy{AllowedCountries: []string{"ES"}, BlockedCountries: []string{"ES"}}, "ES", geoRuleBlocked},
+		{"desconocido admitido", models.ChannelPolicy{AllowedCountries: []string{"ES"}}, "", ""},
+		{"desconocido bloqueado", models.ChannelPolicy{AllowedCountries: []string{"ES"}, BlockUnknown: true}, "", geoRuleUnknown},
+	}
+	for _, tt := range tests {
+		if got := geoRule(&tt.policy, tt.country); got != tt.want {
+			t.Errorf("%s: geoRule(%q) = %q, want %q", tt.name, tt.country, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeCountries(t *testing.T) {
+	tests := []struct {
+		codes   []string
+		want    string
+		wantErr bool
+	}{
+		{nil, "[]", false},
+		{[]string{"es", " PT ", "ES"}, "[ES PT]", false},
+		{[]string{"ESP"}, "", true},
+		{[]string{"E1"}, "", true},
+		{[]string{""}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := normalizeCountries(tt.codes)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidPolicy) {
+				t.Errorf("normalizeCountries(%q) error = %v, want ErrInvalidPolicy", tt.codes, err)
+			}
+			continue
+		}
+		if err != nil || fmt.Sprint(got) != tt.want {
+			t.Errorf("normalizeCountries(%q) = %v, %v; want %s", tt.codes, got, err, tt.want)
+		}
+	}
+}
+
+func TestMatchesDomain(t *testing.T) {
+	patterns := []string{"example.com", "*.partner.io"}
+	tests := []struct {
+		domain string
+		want   bool
+	}{
+		{"example.com", true},
+		{"www.example.com", false},
+		{"notexample.com", false},
+		{"player.partner.io", true},
+		{"a.b.partner.io", true},
+		{"partner.io", false},
+		{"evilpartner.io", false},
+		{"partner.io.evil.com", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := matchesDomain(patterns, tt.domain); got != tt.want {
+			t.Errorf("matchesDomain(%q) = %v, want %v", tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeDomains(t *testing.T) {
+	tests := []struct {
+		domains []string
+		want    string
+		wantErr bool
+	}{
+		{nil, "[]", false},
+		{[]string{" Example.COM. ", "example.com", "*.Partner.io"}, "[example.com *.partner.io]", false},
+		{[]string{"xn--bcher-kva.example"}, "[xn--bcher-kva.example]", false},
+		{[]string{"https://example.com"}, "", true},
+		{[]string{"example.com/embed"}, "", true},
+		{[]string{"*example.com"}, "", true},
+		{[]string{"*.*.example.com"}, "", true},
+		{[]string{"-example.com"}, "", true},
+		{[]string{"example..com"}, "", true},
+		{[]string{""}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := normalizeDomains(tt.domains)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidPolicy) {
+				t.Errorf("normalizeDomains(%q) error = %v, want ErrInvalidPolicy", tt.domains, err)
+			}
+			continue
+		}
+		if err != nil || fmt.Sprint(got) != tt.want {
+			t.Errorf("normalizeDomains(%q) = %v, %v; want %s", tt.domains, got, err, tt.want)
+		}
+	}
+}
+
+func TestReferrerDomain(t *testing.T) {
+	tests := []struct {
+		pageURL string
+		want    string
+	}{
+		{"https://www.Example.com/watch?token=secreto", "www.example.com"},
+		{"http://example.com:8080/player", "example.com"},
+		{"example.com/embed", "example.com"},
+		{"https://example.com./", "example.com"},
+		{"  ", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := ReferrerDomain(tt.pageURL); got != tt.want {
+			t.Errorf("ReferrerDomain(%q) = %q, want %q", tt.pageURL, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tipos de evento del stream en vivo
+const (
+	EventTypePublish   = "publish"
+	EventTypeUnpublish = "unpublish"
+	EventTypeViewers   = "viewers"
+	EventTypeAlert     = "alert"
+	EventTypeThumbnail = "thumbnail"
+)
+
+// EventTypes son los tipos que acepta el filtro type
+var EventTypes = []string{EventTypePublish, EventTypeUnpublish, EventTypeViewers, EventTypeAlert, EventTypeThumbnail}
+
+// subscriberBuffer eventos pendientes por suscriptor; si se llena, el
+// suscriptor se desconecta y retoma con Last-Event-ID
+const subscriberBuffer = 256
+
+// Los IDs de eventos empiezan en el milisegundo de arranque por
+// eventIDsPerMilli: los de una ejecución anterior quedan por debajo y un
+// Last-Event-ID de antes del reinicio provoca resync. Se mantienen por debajo
+// de 2^53 para que JavaScript los lea sin perder precisión.
+const eventIDsPerMilli = 1000
+
+// LiveEvent es un evento del backend para /events/stream. Data va serializado
+// una sola vez al publicarlo.
+type LiveEvent struct {
+	ID        uint64          `json:"id"`
+	Type      string          `json:"type"`
+	ServerID  string          `json:"server_id"`
+	App       string          `json:"app,omitempty"`
+	ChannelID string          `json:"channel_id,omitempty"`
+	Time      time.Time       `json:"time"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// EventFilter limita los eventos de un suscriptor; un campo vacío no filtra
+type EventFilter struct {
+	ServerID  string
+	App       string
+	ChannelID string
+	// Types vacío acepta todos los tipos
+	Types []string
+}
+
+func (f EventFilter) Match(e LiveEvent) bool {
+	if f.ServerID != "" && f.ServerID != e.ServerID {
+		return false
+	}
+	if f.App != "" && f.App != e.App {
+		return false
+	}
+	if f.ChannelID != "" && f.ChannelID != e.ChannelID {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if t == e.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseEventTypes separa una lista "publish,alert"
+func ParseEventTypes(s string) []string {
+	var types []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// EventSubscription recibe los eventos en C. C se cierra si el suscriptor
+// no lee a tiempo o el bus se cierra.
+type EventSubscription struct {
+	C      <-chan LiveEvent
+	ch     chan LiveEvent
+	filter EventFilter
+}
+
+// EventBus reparte los eventos del servidor a los suscriptores y guarda los
+// últimos en un buffer circular para retomar tras una reconexión
+type EventBus struct {
+	serverID string
+
+	mu          sync.Mutex
+	buffer      []LiveEvent
+	next        int // posición del siguiente evento en buffer
+	lastID      uint64
+	subscribers map[*EventSubscription]struct{}
+	closed      bool
+}
+
+func NewEventBus(serverID string, bufferSize int) *EventBus {
+	return &EventBus{
+		serverID:    serverID,
+		lastID:      uint64(time.Now().UnixMilli()) * eventIDsPerMilli,
+		buffer:      make([]LiveEvent, 0, max(1, bufferSize)),
+		subscribers: make(map[*EventSubscription]struct{}),
+	}
+}
+
+// Publish emite un evento de este servidor. data se serializa a JSON; un
+// bus nil no hace nada.
+func (b *EventBus) Publish(eventType, app, channelID string, data interface{}) {
+	if b == nil {
+		return
+	}
+	var raw json.RawMessage
+	if data != nil {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			slog.Warn("⚠️ Evento sin serializar", "type", eventType, "error", err)
+			return
+		}
+		raw = encoded
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.lastID++
+	event := LiveEvent{
+		ID:        b.lastID,
+		Type:      eventType,
+		ServerID:  b.serverID,
+		App:       app,
+		ChannelID: channelID,
+		Time:      time.Now().UTC(),
+		Data:      raw,
+	}
+	if len(b.buffer) < cap(b.buffer) {
+		b.buffer = append(b.buffer, event)
+	} else {
+		b.buffer[b.next] = event
+	}
+	b.next = (b.next + 1) % cap(b.buffer)
+
+	for sub := range b.subscribers {
+		if !sub.filter.Match(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			// Suscriptor lento: se corta y retoma desde el buffer
+			delete(b.subscribers, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// Alert publica como alerta los eventos de sistema que no son "info". Se
+// usa como Store.SetEventHook.
+func (b *EventBus) Alert(eventType, severity, message string, metadata map[string]interface{}) {
+	if severity == "info" {
+		return
+	}
+	app, _ := metadata["app"].(string)
+	channelID, _ := metadata["channel_id"].(string)
+	b.Publish(EventTypeAlert, app, channelID, map[string]interface{}{
+		"event_type": eventType,
+		"severity":   severity,
+		"message":    message,
+		"metadata":   metadata,
+	})
+}
+
+// Subscribe registra un suscriptor. Con lastID > 0 devuelve además los
+// eventos posteriores que siguen en el buffer; resync indica que parte de
+// ellos ya se descartó (o el ID es de antes de un reinicio) y el cliente
+// debe recargar el estado completo.
+func (b *EventBus) Subscribe(filter EventFilter, lastID uint64) (sub *EventSubscription, backlog []LiveEvent, resync bool) {
+	ch := make(chan LiveEvent, subscriberBuffer)
+	sub = &EventSubscription{C: ch, ch: ch, filter: filter}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return sub, nil, false
+	}
+	b.subscribers[sub] = struct{}{}
+
+	if lastID == 0 {
+		return sub, nil, false
+	}
+	if lastID > b.lastID {
+		return sub, nil, true
+	}
+	oldest := b.lastID - uint64(len(b.buffer)) + 1
+	resync = lastID+1 < oldest
+	for i := 0; i < len(b.buffer); i++ {
+		event := b.buffer[(b.next-len(b.buffer)+i+cap(b.buffer))%cap(b.buffer)]
+		if event.ID > lastID && filter.Match(event) {
+			backlog = append(backlog, event)
+		}
+	}
+	return sub, backlog, resync
+}
+
+// Unsubscribe quita el suscriptor; se puede llamar aunque ya esté cerrado
+func (b *EventBus) Unsubscribe(sub *EventSubscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+// Subscribers devuelve cuántos clientes están conectados
+func (b *EventBus) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// Close desconecta a todos los suscriptores para que server.Shutdown no
+// espere a los streams abiertos
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subscribers {
+		delete(b.subscribers, sub)
+		close(sub.ch)
+	}
+}
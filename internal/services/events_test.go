@@ -0,0 +1,72 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func publishN(bus *EventBus, n int) []uint64 {
+	for i := 0; i < n; i++ {
+		bus.Publish(EventTypeViewers, "live", "", map[string]int{"viewers": i})
+	}
+	_, backlog, _ := bus.Subscribe(EventFilter{}, 1)
+	ids := make([]uint64, len(backlog))
+	for i, e := range backlog {
+		ids[i] = e.ID
+	}
+	return ids
+}
+
+func TestEventBusResume(t *testing.T) {
+	bus := NewEventBus("srv-test", 3)
+	ids := publishN(bus, 5)
+	if len(ids) != 3 {
+		t.Fatalf("buffer con %d eventos, want 3", len(ids))
+	}
+	first, last := ids[0], ids[2]
+
+	tests := []struct {
+		name        string
+		lastID      uint64
+		wantBacklog int
+		wantResync  bool
+	}{
+		{"conexión nueva", 0, 0, false},
+		{"al día", last, 0, false},
+		{"dentro del buffer", first, 2, false},
+		{"justo antes del buffer", first - 1, 3, false},
+		{"eventos descartados", first - 2, 3, true},
+		{"ID de una ejecución anterior", 42, 3, true},
+		{"ID posterior al último", last + 1, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, backlog, resync := bus.Subscribe(EventFilter{}, tt.lastID)
+			defer bus.Unsubscribe(sub)
+			if len(backlog) != tt.wantBacklog || resync != tt.wantResync {
+				t.Errorf("Subscribe(%d) = %d eventos, resync %v; want %d, %v",
+					tt.lastID, len(backlog), resync, tt.wantBacklog, tt.wantResync)
+			}
+		})
+	}
+}
+
+func TestEventBusIDsAcrossRestart(t *testing.T) {
+	before := NewEventBus("srv-test", 10)
+	ids := publishN(before, 3)
+	stale := ids[len(ids)-1]
+
+	// Un reinicio sin eventos todavía: el ID anterior pide resync. Arrancar
+	// lleva más de un milisegundo.
+	time.Sleep(2 * time.Millisecond)
+	after := NewEventBus("srv-test", 10)
+	if _, _, resync := after.Subscribe(EventFilter{}, stale); !resync {
+		t.Errorf("Last-Event-ID %d de la ejecución anterior sin resync", stale)
+	}
+	if next := publishN(after, 1); next[0] <= stale {
+		t.Errorf("primer ID tras reiniciar %d, want mayor que %d", next[0], stale)
+	}
+	if max := uint64(1) << 53; stale >= max {
+		t.Errorf("ID %d no cabe en un número de JavaScript", stale)
+	}
+}
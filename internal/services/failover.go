@@ -0,0 +1,308 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"srs-backend/internal/logging"
+	"srs-backend/internal/storage"
+)
+
+const (
+	IngestPrimary = "primary"
+	IngestBackup  = "backup"
+)
+
+// IngestSource es un encoder (primario o respaldo) de un canal.
+type IngestSource struct {
+	StreamKey string `json:"-"`
+	App       string `json:"app"`
+	ClientID  string `json:"client_id"`
+	IP        string `json:"ip"`
+	Live      bool   `json:"live"`
+}
+
+// FailoverState resume la ingesta de un canal con respaldo.
+type FailoverState struct {
+	ChannelID    string                   `json:"channel_id"`
+	Active       string                   `json:"active"`
+	Sources      map[string]*IngestSource `json:"sources"`
+	LastSwitchAt *time.Time               `json:"last_switch_at,omitempty"`
+	Switches     int                      `json:"switches"`
+
+	// client_id expulsado a propósito para que SRS vuelva a pedir on_forward
+	kickedClientID string
+}
+
+// FailoverService mantiene publicado pero oculto el encoder de respaldo de un
+// canal y lo promueve cuando cae el primario.
+//
+// SRS consulta on_forward una sola vez al iniciar la publicación, así que
+// para cambiar la fuente reenviada se expulsa al respaldo: el encoder se
+// reconecta en segundos y el nuevo on_forward ya refleja la fuente activa.
+type FailoverService struct {
+	store            *storage.Store
+	keys             *StreamKeyService
+	srsClient        *SRSClient
+	serverID         string
+	serverIP         string
+	reconnectTimeout time.Duration
+	mu               sync.Mutex
+	channels         map[string]*FailoverState
+
+	// Reloj y temporizador, reemplazables en los tests
+	now       func() time.Time
+	afterFunc func(time.Duration, func()) *time.Timer
+}
+
+func NewFailoverService(store *storage.Store, keys *StreamKeyService, serverID, serverIP string, reconnectTimeout time.Duration) *FailoverService {
+	return &FailoverService{
+		store:            store,
+		keys:             keys,
+		srsClient:        NewSRSClient(),
+		serverID:         serverID,
+		serverIP:         serverIP,
+		reconnectTimeout: reconnectTimeout,
+		channels:         make(map[string]*FailoverState),
+		now:              time.Now,
+		afterFunc:        time.AfterFunc,
+	}
+}
+
+// OnPublish registra el encoder y devuelve si debe mostrarse al público. Un
+// respaldo con el primario en vivo queda oculto.
+func (f *FailoverService) OnPublish(ctx context.Context, channelID string, src IngestSource) bool {
+	_, isBackup := f.keys.SplitBackupKey(src.StreamKey)
+	role := IngestPrimary
+	if isBackup {
+		role = IngestBackup
+	}
+
+	f.mu.Lock()
+	st, ok := f.channels[channelID]
+	if !ok {
+		st = &FailoverState{ChannelID: channelID, Sources: make(map[string]*IngestSource)}
+		f.channels[channelID] = st
+	}
+
+	src.Live = true
+	st.Sources[role] = &src
+
+	visible := true
+	previous := st.Active
+	kick := ""
+	switch role {
+	case IngestPrimary:
+		st.Active = IngestPrimary
+		if previous == IngestBackup {
+			// El respaldo deja de reenviarse; vuelve oculto al reconectar
+			if backup := st.Sources[IngestBackup]; backup != nil && backup.Live {
+				kick = backup.ClientID
+				st.kickedClientID = kick
+			}
+		}
+	case IngestBackup:
+		if primary := st.Sources[IngestPrimary]; primary != nil && primary.Live {
+			visible = false
+		} else {
+			st.Active = IngestBackup
+		}
+	}
+	active := st.Active
+	switched := previous != "" && previous != active
+	if switched {
+		f.markSwitchLocked(st)
+	}
+	f.mu.Unlock()
+
+	if switched {
+		f.recordSwitch(ctx, channelID, previous, active)
+	}
+	if kick != "" {
+		f.kick(kick)
+	}
+	if !visible {
+		slog.InfoContext(ctx, "🙈 Respaldo publicado y oculto", "channel_id", channelID)
+	}
+	return visible
+}
+
+// OnUnpublish devuelve si el canal debe pasar a offline. Si cae el primario con
+// un respaldo en vivo, el respaldo pasa a ser la fuente pública.
+func (f *FailoverService) OnUnpublish(ctx context.Context, channelID, streamKey, clientID string) bool {
+	_, isBackup := f.keys.SplitBackupKey(streamKey)
+	role := IngestPrimary
+	if isBackup {
+		role = IngestBackup
+	}
+
+	f.mu.Lock()
+	st, ok := f.channels[channelID]
+	if !ok {
+		f.mu.Unlock()
+		return true
+	}
+
+	// Desconexión provocada por un cambio de fuente: el encoder reconecta
+	if clientID != "" && clientID == st.kickedClientID {
+		st.kickedClientID = ""
+		if src := st.Sources[role]; src != nil && src.ClientID == clientID {
+			src.Live = false
+		}
+		f.mu.Unlock()
+		return false
+	}
+
+	src := st.Sources[role]
+	if src == nil || (clientID != "" && src.ClientID != clientID) {
+		// on_unpublish de una conexión anterior ya reemplazada
+		f.mu.Unlock()
+		return false
+	}
+	src.Live = false
+
+	offline := false
+	previous := st.Active
+	kick := ""
+	backup := st.Sources[IngestBackup]
+	switch role {
+	case IngestPrimary:
+		if backup != nil && backup.Live {
+			st.Active = IngestBackup
+			kick = backup.ClientID
+			st.kickedClientID = kick
+		} else {
+			offline = true
+		}
+	case IngestBackup:
+		primary := st.Sources[IngestPrimary]
+		offline = primary == nil || !primary.Live
+	}
+
+	active := st.Active
+	switched := previous != active
+	if switched {
+		f.markSwitchLocked(st)
+	}
+	if offline {
+		delete(f.channels, channelID)
+	}
+	f.mu.Unlock()
+
+	if switched {
+		f.recordSwitch(ctx, channelID, previous, active)
+	}
+	if kick != "" {
+		f.kick(kick)
+		f.afterFunc(f.reconnectTimeout, func() { f.checkReconnected(channelID) })
+	}
+	return offline
+}
+
+// ForwardStream devuelve el nombre con el que se reenvía el stream. El
+// respaldo activo se reenvía con la clave del primario para que la URL de
+// reproducción no cambie; oculto no se reenvía.
+func (f *FailoverService) ForwardStream(streamKey string) (string, bool) {
+	primaryKey, isBackup := f.keys.SplitBackupKey(streamKey)
+	if !isBackup {
+		return streamKey, true
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, st := range f.channels {
+		if backup := st.Sources[IngestBackup]; backup != nil && backup.StreamKey == streamKey {
+			if st.Active == IngestBackup {
+				return primaryKey, true
+			}
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// States devuelve el estado de los canales que tienen o tuvieron respaldo,
+// con las IPs anonimizadas como en la base de datos
+func (f *FailoverService) States() []FailoverState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	states := make([]FailoverState, 0)
+	for _, st := range f.channels {
+		if _, hasBackup := st.Sources[IngestBackup]; !hasBackup {
+			continue
+		}
+		copyState := *st
+		copyState.Sources = make(map[string]*IngestSource, len(st.Sources))
+		for role, src := range st.Sources {
+			srcCopy := *src
+			srcCopy.IP = f.store.ClientIP(src.IP)
+			copyState.Sources[role] = &srcCopy
+		}
+		states = append(states, copyState)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].ChannelID < states[j].ChannelID
+	})
+	return states
+}
+
+// checkReconnected marca offline el canal si el respaldo expulsado no volvió
+func (f *FailoverService) checkReconnected(channelID string) {
+	f.mu.Lock()
+	st, ok := f.channels[channelID]
+	if !ok || st.Active != IngestBackup {
+		f.mu.Unlock()
+		return
+	}
+	backup := st.Sources[IngestBackup]
+	if backup != nil && backup.Live {
+		f.mu.Unlock()
+		return
+	}
+	delete(f.channels, channelID)
+	f.mu.Unlock()
+
+	slog.Warn("⚠️ El respaldo del canal no reconectó, canal offline", "channel_id", channelID, "timeout", f.reconnectTimeout.String())
+	f.store.Channels.SetOffline(context.Background(), channelID)
+}
+
+func (f *FailoverService) markSwitchLocked(st *FailoverState) {
+	now := f.now().UTC()
+	st.LastSwitchAt = &now
+	st.Switches++
+}
+
+func (f *FailoverService) kick(clientID string) {
+	if err := f.srsClient.KickClient(clientID); err != nil {
+		slog.Error("❌ Error expulsando encoder para cambio de fuente", logging.KeyClientID, clientID, "error", err)
+	}
+}
+
+func (f *FailoverService) recordSwitch(ctx context.Context, channelID, from, to string) {
+	eventType := "ingest_failover"
+	severity := "warning"
+	if to == IngestPrimary {
+		eventType = "ingest_failover_revert"
+		severity = "info"
+	}
+
+	slog.InfoContext(ctx, "🔀 Canal cambia de fuente", "channel_id", channelID, "from", from, "to", to)
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		f.store.Channels.SetActiveIngest(ctx, channelID, to)
+		f.store.Events.Record(ctx, f.serverID, f.serverIP, eventType, severity,
+			fmt.Sprintf("Canal %s cambia de %s a %s", channelID, from, to),
+			map[string]interface{}{
+				"server_id":  f.serverID,
+				"channel_id": channelID,
+				"from":       from,
+				"to":         to,
+			})
+	}()
+}
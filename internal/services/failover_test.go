@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"srs-backend/internal/storage"
+)
+
+// failoverClock reemplaza time.Now y time.AfterFunc: los temporizadores
+// solo se disparan con advance
+type failoverClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []failoverTimer
+}
+
+type failoverTimer struct {
+	at time.Time
+	fn func()
+}
+
+func (c *failoverClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *failoverClock) AfterFunc(d time.Duration, fn func()) *time.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timers = append(c.timers, failoverTimer{at: c.now.Add(d), fn: fn})
+	return nil
+}
+
+func (c *failoverClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	var due []func()
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.at.After(c.now) {
+			pending = append(pending, timer)
+		} else {
+			due = append(due, timer.fn)
+		}
+	}
+	c.timers = pending
+	c.mu.Unlock()
+
+	for _, fn := range due {
+		fn()
+	}
+}
+
+// newTestFailover arma el servicio sobre el backend en memoria, con SRS
+// simulado que anota los client_id expulsados
+func newTestFailover(t *testing.T) (*FailoverService, *storage.MemoryBackend, *failoverClock, func() []string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var kicked []string
+	srs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			mu.Lock()
+			kicked = append(kicked, strings.TrimPrefix(r.URL.Path, "/clients/"))
+			mu.Unlock()
+		}
+		w.Write([]byte(`{"code":0}`))
+	}))
+	t.Cleanup(srs.Close)
+
+	backend := storage.NewMemoryBackend()
+	if err := backend.Seed("channels_channel", map[string]interface{}{"id": "ch1", "stream_id": "key1", "is_on_live": true}); err != nil {
+		t.Fatal(err)
+	}
+	store := storage.NewStore(backend)
+	keys := NewStreamKeyService(store, "srv-test", "", "_backup")
+
+	clock := &failoverClock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	f := NewFailoverService(store, keys, "srv-test", "", 30*time.Second)
+	f.srsClient = &SRSClient{baseURL: srs.URL}
+	f.now = clock.Now
+	f.afterFunc = clock.AfterFunc
+
+	takeKicked := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		out := kicked
+		kicked = nil
+		return out
+	}
+	return f, backend, clock, takeKicked
+}
+
+func TestFailoverStateMachine(t *testing.T) {
+	ctx := context.Background()
+	f, _, clock, takeKicked := newTestFailover(t)
+
+	// want es la visibilidad en publish y el paso a offline en unpublish
+	steps := []struct {
+		name       string
+		publish    bool
+		streamKey  string
+		clientID   string
+		want       bool
+		wantActive string
+		wantKick   string
+	}{
+		{"entra el primario", true, "key1", "p1", true, IngestPrimary, ""},
+		{"respaldo oculto", true, "key1_backup", "b1", false, IngestPrimary, ""},
+		{"cae el primario", false, "key1", "p1", false, IngestBackup, "b1"},
+		{"sale el respaldo expulsado", false, "key1_backup", "b1", false, IngestBackup, ""},
+		{"respaldo reconecta activo", true, "key1_backup", "b2", true, IngestBackup, ""},
+		{"vuelve el primario", true, "key1", "p2", true, IngestPrimary, "b2"},
+		{"sale el respaldo expulsado otra vez", false, "key1_backup", "b2", false, IngestPrimary, ""},
+		{"respaldo reconecta oculto", true, "key1_backup", "b3", false, IngestPrimary, ""},
+		{"unpublish de una conexión reemplazada", false, "key1", "p1", false, IngestPrimary, ""},
+		{"cae el respaldo con el primario en vivo", false, "key1_backup", "b3", false, IngestPrimary, ""},
+	}
+	for _, step := range steps {
+		var got bool
+		if step.publish {
+			got = f.OnPublish(ctx, "ch1", IngestSource{StreamKey: step.streamKey, App: "live", ClientID: step.clientID})
+		} else {
+			got = f.OnUnpublish(ctx, "ch1", step.streamKey, step.clientID)
+		}
+		if got != step.want {
+			t.Errorf("%s: devolvió %v, want %v", step.name, got, step.want)
+		}
+
+		if active := activeIngest(f, "ch1"); active != step.wantActive {
+			t.Errorf("%s: fuente activa %q, want %q", step.name, active, step.wantActive)
+		}
+
+		kicked := takeKicked()
+		switch {
+		case step.wantKick == "" && len(kicked) > 0:
+			t.Errorf("%s: expulsó %v, want ninguno", step.name, kicked)
+		case step.wantKick != "" && (len(kicked) != 1 || kicked[0] != step.wantKick):
+			t.Errorf("%s: expulsó %v, want [%s]", step.name, kicked, step.wantKick)
+		}
+	}
+
+	state := f.States()[0]
+	if state.Switches != 2 {
+		t.Errorf("switches = %d, want 2", state.Switches)
+	}
+	if state.LastSwitchAt == nil || !state.LastSwitchAt.Equal(clock.Now()) {
+		t.Errorf("last_switch_at = %v, want %s", state.LastSwitchAt, clock.Now())
+	}
+	if f.OnUnpublish(ctx, "ch1", "key1", "p2") != true {
+		t.Error("cae el primario sin respaldo: want offline")
+	}
+	if active := activeIngest(f, "ch1"); active != "" {
+		t.Errorf("canal offline sigue con fuente activa %q", active)
+	}
+}
+
+// activeIngest lee la fuente activa aunque el canal aún no tenga respaldo,
+// que States omite
+func activeIngest(f *FailoverService, channelID string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if st, ok := f.channels[channelID]; ok {
+		return st.Active
+	}
+	return ""
+}
+
+func TestFailoverReconnectTimeout(t *testing.T) {
+	tests := []struct {
+		name        string
+		reconnect   bool
+		wait        time.Duration
+		wantOffline bool
+	}{
+		{"el respaldo reconecta", true, 30 * time.Second, false},
+		{"antes del timeout", false, 29 * time.Second, false},
+		{"timeout vencido", false, 30 * time.Second, true},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		f, backend, clock, _ := newTestFailover(t)
+
+		f.OnPublish(ctx, "ch1", IngestSource{StreamKey: "key1", ClientID: "p1"})
+		f.OnPublish(ctx, "ch1", IngestSource{StreamKey: "key1_backup", ClientID: "b1"})
+		f.OnUnpublish(ctx, "ch1", "key1", "p1")
+		f.OnUnpublish(ctx, "ch1", "key1_backup", "b1")
+		if tt.reconnect {
+			f.OnPublish(ctx, "ch1", IngestSource{StreamKey: "key1_backup", ClientID: "b2"})
+		}
+		clock.advance(tt.wait)
+
+		if offline := activeIngest(f, "ch1") == ""; offline != tt.wantOffline {
+			t.Errorf("%s: canal descartado = %v, want %v", tt.name, offline, tt.wantOffline)
+		}
+
+		if err := f.store.Drain(ctx); err != nil {
+			t.Fatal(err)
+		}
+		var rows []struct {
+			IsOnLive bool `json:"is_on_live"`
+		}
+		if err := backend.Select(ctx, storage.Query{Table: "channels_channel"}, &rows); err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 || rows[0].IsOnLive == tt.wantOffline {
+			t.Errorf("%s: channels_channel = %+v, want is_on_live %v", tt.name, rows, !tt.wantOffline)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// GeoInfo es la ubicación y red de una IP según las bases locales
+type GeoInfo struct {
+	CountryCode string
+	Region      string
+	City        string
+	ASN         uint
+	ASOrg       string
+}
+
+// addColumns agrega a la fila de sesión los campos resueltos
+func (g GeoInfo) addColumns(row map[string]interface{}) {
+	if g.CountryCode != "" {
+		row["country_code"] = g.CountryCode
+	}
+	if g.Region != "" {
+		row["region"] = g.Region
+	}
+	if g.City != "" {
+		row["city"] = g.City
+	}
+	if g.ASN != 0 {
+		row["asn"] = g.ASN
+		row["as_org"] = g.ASOrg
+	}
+}
+
+type cityRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+type asnRecord struct {
+	Number       uint   `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// geoDatabase es un archivo .mmdb. modTime y missing solo los usa reload.
+type geoDatabase struct {
+	path   string
+	reader *maxminddb.Reader
+	// Fecha del último archivo que se intentó abrir, con éxito o no
+	modTime time.Time
+	missing bool
+}
+
+// GeoIPService resuelve IPs con bases en formato MaxMind (City y ASN) sin
+// consultas de red. Los archivos se vuelven a abrir cuando cambian; si no
+// existen la resolución queda vacía hasta que aparezcan.
+type GeoIPService struct {
+	interval time.Duration
+
+	mu   sync.RWMutex
+	city geoDatabase
+	asn  geoDatabase
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewGeoIPService(cityPath, asnPath string, interval time.Duration) *GeoIPService {
+	g := &GeoIPService{
+		interval: interval,
+		city:     geoDatabase{path: cityPath},
+		asn:      geoDatabase{path: asnPath},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	g.reload()
+	return g
+}
+
+// Start revisa los archivos cada interval. Bloquea; usar con go.
+func (g *GeoIPService) Start() {
+	defer close(g.done)
+	if g.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			g.reload()
+		case <-g.stop:
+			return
+		}
+	}
+}
+
+// Stop detiene la revisión y cierra las bases
+func (g *GeoIPService) Stop(ctx context.Context) {
+	close(g.stop)
+	select {
+	case <-g.done:
+	case <-ctx.Done():
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, db := range []*geoDatabase{&g.city, &g.asn} {
+		if db.reader != nil {
+			db.reader.Close()
+			db.reader = nil
+		}
+	}
+}
+
+// Lookup devuelve un GeoInfo vacío si la IP no es válida o no hay bases
+func (g *GeoIPService) Lookup(ip string) GeoInfo {
+	var info GeoInfo
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return info
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if g.city.reader != nil {
+		var record cityRecord
+		if err := g.city.reader.Lookup(parsed, &record); err == nil {
+			info.CountryCode = record.Country.ISOCode
+			info.City = record.City.Names["en"]
+			if len(record.Subdivisions) > 0 {
+				info.Region = record.Subdivisions[0].Names["en"]
+			}
+		}
+	}
+	if g.asn.reader != nil {
+		var record asnRecord
+		if err := g.asn.reader.Lookup(parsed, &record); err == nil {
+			info.ASN = record.Number
+			info.ASOrg = record.Organization
+		}
+	}
+	return info
+}
+
+// Enabled indica si hay alguna base cargada
+func (g *GeoIPService) Enabled() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.city.reader != nil || g.asn.reader != nil
+}
+
+func (g *GeoIPService) reload() {
+	g.reloadDatabase(&g.city)
+	g.reloadDatabase(&g.asn)
+}
+
+// reloadDatabase abre el archivo si cambió su fecha de modificación. El
+// reader anterior se cierra con el lock tomado: ninguna búsqueda lo usa.
+func (g *GeoIPService) reloadDatabase(db *geoDatabase) {
+	if db.path == "" {
+		return
+	}
+
+	info, err := os.Stat(db.path)
+	if err != nil {
+		if !db.missing {
+			db.missing = true
+			g.mu.RLock()
+			loaded := db.reader != nil
+			g.mu.RUnlock()
+			if loaded {
+				// Se mantiene la base abierta hasta que haya un archivo nuevo
+				slog.Warn("⚠️ Base GeoIP no disponible, se mantiene la cargada", "path", db.path, "error", err)
+			}
+		}
+		return
+	}
+	db.missing = false
+	if info.ModTime().Equal(db.modTime) {
+		return
+	}
+	db.modTime = info.ModTime()
+
+	reader, err := maxminddb.Open(db.path)
+	if err != nil {
+		slog.Error("❌ Error abriendo base GeoIP", "path", db.path, "error", err)
+		return
+	}
+
+	g.mu.Lock()
+	previous := db.reader
+	db.reader = reader
+	if previous != nil {
+		previous.Close()
+	}
+	g.mu.Unlock()
+
+	slog.Info("🌍 Base GeoIP cargada", "path", db.path, "type", reader.Metadata.DatabaseType,
+		"built", time.Unix(int64(reader.Metadata.BuildEpoch), 0).UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"srs-backend/internal/storage"
+)
+
+type writtenViewers struct {
+	viewers int
+	at      time.Time
+}
+
+type announcedViewers struct {
+	viewers int
+	app     string
+}
+
+// LiveViewers publica los viewers de este servidor por canal para que
+// channels_channel tenga live_viewers y peak_viewers sin consultar métricas.
+// El recolector llama a Sync en cada ciclo: se escriben, en un único upsert,
+// los canales cuyo recuento cambió al menos minChangePercent, pasó de o a
+// cero, o cambió y lleva maxDelay sin escribirse. En /events/stream se
+// anuncia cualquier cambio, sin ese umbral.
+type LiveViewers struct {
+	store            *storage.Store
+	sessions         *SessionTracker
+	events           *EventBus
+	serverID         string
+	minChangePercent float64
+	maxDelay         time.Duration
+
+	mu        sync.Mutex
+	written   map[string]writtenViewers   // channel_id → último recuento escrito
+	announced map[string]announcedViewers // channel_id → último recuento anunciado
+}
+
+func NewLiveViewers(store *storage.Store, sessions *SessionTracker, events *EventBus, serverID string, minChangePercent float64, maxDelay time.Duration) *LiveViewers {
+	return &LiveViewers{
+		store:            store,
+		sessions:         sessions,
+		events:           events,
+		serverID:         serverID,
+		minChangePercent: minChangePercent,
+		maxDelay:         maxDelay,
+		written:          make(map[string]writtenViewers),
+		announced:        make(map[string]announcedViewers),
+	}
+}
+
+// Sync escribe los recuentos que cambiaron. Si la escritura falla se
+// reintenta en el ciclo siguiente con el recuento de ese momento.
+func (l *LiveViewers) Sync(ctx context.Context) {
+	ctx, span := tracer.Start(ctx, "live_viewers.sync")
+	defer span.End()
+
+	current, apps := l.count()
+	now := time.Now()
+	l.announce(current, apps)
+
+	changed := make(map[string]int)
+	l.mu.Lock()
+	for channelID, viewers := range current {
+		if l.shouldWrite(channelID, viewers, now) {
+			changed[channelID] = viewers
+		}
+	}
+	// Canales sin viewers en este servidor desde la última escritura
+	for channelID, w := range l.written {
+		if _, ok := current[channelID]; !ok && w.viewers > 0 {
+			changed[channelID] = 0
+		}
+	}
+	l.mu.Unlock()
+
+	if len(changed) == 0 {
+		return
+	}
+	if err := l.store.Channels.SaveViewers(ctx, l.serverID, changed); err != nil {
+		return
+	}
+	l.remember(changed, now)
+	slog.DebugContext(ctx, "👥 Viewers por canal actualizados", "channels", len(changed))
+}
+
+// Reset pone a cero los canales de este servidor al apagar, para que no
+// sigan sumando hasta que expire su heartbeat
+func (l *LiveViewers) Reset(ctx context.Context) {
+	l.mu.Lock()
+	zeros := make(map[string]int, len(l.written))
+	for channelID := range l.written {
+		zeros[channelID] = 0
+	}
+	l.mu.Unlock()
+
+	if len(zeros) == 0 {
+		return
+	}
+	if err := l.store.Channels.SaveViewers(ctx, l.serverID, zeros); err == nil {
+		l.remember(zeros, time.Now())
+	}
+}
+
+// count cuenta las sesiones de reproducción abiertas por canal y devuelve
+// también la app de cada canal
+func (l *LiveViewers) count() (map[string]int, map[string]string) {
+	counts := make(map[string]int)
+	apps := make(map[string]string)
+	for _, s := range l.sessions.Active() {
+		if s.ClientType == ClientTypePlay && s.ChannelID != "" {
+			counts[s.ChannelID]++
+			apps[s.ChannelID] = s.App
+		}
+	}
+	return counts, apps
+}
+
+// announce publica un evento viewers por cada canal cuyo recuento cambió
+// desde el ciclo anterior; un canal sin viewers se anuncia una vez con 0
+func (l *LiveViewers) announce(current map[string]int, apps map[string]string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for channelID, viewers := range current {
+		if l.announced[channelID].viewers != viewers {
+			l.events.Publish(EventTypeViewers, apps[channelID], channelID, map[string]int{"viewers": viewers})
+			l.announced[channelID] = announcedViewers{viewers: viewers, app: apps[channelID]}
+		}
+	}
+	for channelID, last := range l.announced {
+		if _, ok := current[channelID]; !ok {
+			l.events.Publish(EventTypeViewers, last.app, channelID, map[string]int{"viewers": 0})
+			delete(l.announced, channelID)
+		}
+	}
+}
+
+// shouldWrite se llama con mu tomado
+func (l *LiveViewers) shouldWrite(channelID string, viewers int, now time.Time) bool {
+	last, ok := l.written[channelID]
+	switch {
+	case !ok || last.viewers == 0:
+		return viewers > 0
+	case viewers == last.viewers:
+		return false
+	case viewers == 0 || now.Sub(last.at) >= l.maxDelay:
+		return true
+	}
+	diff := viewers - last.viewers
+	if diff < 0 {
+		diff = -diff
+	}
+	return float64(diff) >= max(1, float64(last.viewers)*l.minChangePercent/100)
+}
+
+// remember guarda lo escrito; un cero ya no necesita seguimiento
+func (l *LiveViewers) remember(written map[string]int, at time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for channelID, viewers := range written {
+		if viewers == 0 {
+			delete(l.written, channelID)
+			continue
+		}
+		l.written[channelID] = writtenViewers{viewers: viewers, at: at}
+	}
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLiveViewersShouldWrite(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	recent := now.Add(-30 * time.Second)
+	stale := now.Add(-3 * time.Minute)
+
+	tests := []struct {
+		name    string
+		written *writtenViewers
+		viewers int
+		want    bool
+	}{
+		{"primer viewer", nil, 1, true},
+		{"canal nuevo sin viewers", nil, 0, false},
+		{"sin cambios", &writtenViewers{100, recent}, 100, false},
+		{"sin cambios tras maxDelay", &writtenViewers{100, stale}, 100, false},
+		{"cambio menor que el umbral", &writtenViewers{100, recent}, 104, false},
+		{"cambio en el umbral", &writtenViewers{100, recent}, 105, true},
+		{"bajada en el umbral", &writtenViewers{100, recent}, 95, true},
+		{"cambio menor tras maxDelay", &writtenViewers{100, stale}, 101, true},
+		{"pocos viewers: basta uno", &writtenViewers{3, recent}, 4, true},
+		{"a cero", &writtenViewers{100, recent}, 0, true},
+		{"desde cero", &writtenViewers{0, recent}, 1, true},
+	}
+	for _, tt := range tests {
+		l := NewLiveViewers(nil, nil, nil, "srv-test", 5, 2*time.Minute)
+		if tt.written != nil {
+			l.written["ch1"] = *tt.written
+		}
+		if got := l.shouldWrite("ch1", tt.viewers, now); got != tt.want {
+			t.Errorf("%s: shouldWrite(%d) = %v, want %v", tt.name, tt.viewers, got, tt.want)
+		}
+	}
+}
+
+func TestLiveViewersRemember(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := NewLiveViewers(nil, nil, nil, "srv-test", 5, 2*time.Minute)
+	l.remember(map[string]int{"ch1": 10, "ch2": 4}, now)
+	l.remember(map[string]int{"ch2": 0}, now.Add(time.Minute))
+
+	if w, ok := l.written["ch1"]; !ok || w.viewers != 10 || !w.at.Equal(now) {
+		t.Errorf("ch1 = %+v, want 10 viewers escritos en %s", w, now)
+	}
+	if _, ok := l.written["ch2"]; ok {
+		t.Error("un canal escrito a cero sigue en seguimiento")
+	}
+}
@@ -1,47 +1,92 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
+
+	"srs-backend/internal/models"
+	"srs-backend/internal/storage"
+	"srs-backend/internal/telemetry"
+	"srs-backend/pkg/utils"
 )
 
+// Intervalo entre recolecciones de métricas
+const collectInterval = 30 * time.Second
+
 type MetricsCollector struct {
-	supabase  *SupabaseService
+	store     *storage.Store
 	srsClient *SRSClient
+	tenants   *TenantService
+	viewers   *LiveViewers
+	feed      *StatsFeed
 	serverID  string
 	serverIP  string
+
+	// Acumulado de escrituras al final del ciclo anterior
+	lastWrites storage.WriteStats
+
+	stop chan struct{}
+	done chan struct{}
 }
 
-func NewMetricsCollector(supabase *SupabaseService, serverID, serverIP string) *MetricsCollector {
+func NewMetricsCollector(store *storage.Store, tenants *TenantService, viewers *LiveViewers, feed *StatsFeed, serverID, serverIP string) *MetricsCollector {
 	return &MetricsCollector{
-		supabase:  supabase,
+		store:     store,
 		srsClient: NewSRSClient(),
+		tenants:   tenants,
+		viewers:   viewers,
+		feed:      feed,
 		serverID:  serverID,
 		serverIP:  serverIP,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
 	}
 }
 
 func (m *MetricsCollector) Start() {
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(collectInterval)
 	defer ticker.Stop()
 
-	log.Printf("📊 Recolector de métricas iniciado para servidor %s (cada 30s)", m.serverID)
+	slog.Info("📊 Recolector de métricas iniciado", "interval", collectInterval.String())
 
-for range ticker.C {
-  m.collectAndSaveMetrics()
+	defer close(m.done)
+	for {
+		select {
+		case <-ticker.C:
+			m.collectAndSaveMetrics()
+		case <-m.stop:
+			return
+		}
+	}
 }
+
+// Stop espera a que termine el ciclo en curso, guarda el consumo acumulado
+// de las organizaciones y retira los viewers de este servidor. Las filas de
+// consumo quedan encoladas; Store.Flush y el outbox las entregan.
+func (m *MetricsCollector) Stop(ctx context.Context) {
+	close(m.stop)
+	select {
+	case <-m.done:
+	case <-ctx.Done():
+		return
+	}
+	m.tenants.FlushUsage(ctx)
+	m.viewers.Reset(ctx)
+	slog.InfoContext(ctx, "📊 Recolector de métricas detenido")
 }
 
 func (m *MetricsCollector) collectAndSaveMetrics() {
-	client := m.supabase.GetClient()
+	ctx, span := tracer.Start(context.Background(), "metrics.collect")
+	defer span.End()
 
 	// 1. Obtener streams
-	resp, err := http.Get("http://srs:1985/api/v1/streams/")
+	resp, err := http.Get(m.srsClient.listURL("streams"))
 	if err != nil {
-		log.Printf("❌ Error obteniendo streams para métricas: %v", err)
+		slog.ErrorContext(ctx, "❌ Error obteniendo streams para métricas", "error", err)
 		return
 	}
 	defer resp.Body.Close()
@@ -68,7 +113,7 @@ func (m *MetricsCollector) collectAndSaveMetrics() {
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&srsStreamsResponse); err != nil {
-  log.Printf("❌ Error decode streams: %v", err)
+  slog.ErrorContext(ctx, "❌ Error decode streams", "error", err)
   return
 }
 
@@ -90,10 +135,10 @@ func (m *MetricsCollector) collectAndSaveMetrics() {
 			cpuPercent = rusage.Data.Percent
 			memoryMB = rusage.Data.MemKB / 1024
 		} else {
-			log.Printf("⚠️ Error decode rusages, usando summaries: %v", err)
+			slog.WarnContext(ctx, "⚠️ Error decode rusages, usando summaries", "error", err)
 		}
 	} else {
-		log.Printf("⚠️ Error obteniendo rusages, usando summaries: %v", err)
+		slog.WarnContext(ctx, "⚠️ Error obteniendo rusages, usando summaries", "error", err)
 	}
 
 	// Cambio: obtener summaries y otras métricas del sistema (Firma: Cursor)
@@ -103,16 +148,16 @@ func (m *MetricsCollector) collectAndSaveMetrics() {
 	meminfosPayload, meminfosErr := fetchSRSJSONExpect("http://srs:1985/api/v1/meminfos", true)
 
 	if summariesErr != nil {
-		log.Printf("⚠️ Error obteniendo summaries: %v", summariesErr)
+		slog.WarnContext(ctx, "⚠️ Error obteniendo summaries", "error", summariesErr)
 	}
 	if systemProcErr != nil {
-		log.Printf("⚠️ Error obteniendo system_proc_stats: %v", systemProcErr)
+		slog.WarnContext(ctx, "⚠️ Error obteniendo system_proc_stats", "error", systemProcErr)
 	}
 	if selfProcErr != nil {
-		log.Printf("⚠️ Error obteniendo self_proc_stats: %v", selfProcErr)
+		slog.WarnContext(ctx, "⚠️ Error obteniendo self_proc_stats", "error", selfProcErr)
 	}
 	if meminfosErr != nil {
-		log.Printf("⚠️ Error obteniendo meminfos: %v", meminfosErr)
+		slog.WarnContext(ctx, "⚠️ Error obteniendo meminfos", "error", meminfosErr)
 	}
 
 	// Fallback a summaries si rusages falla o devuelve 0
@@ -134,7 +179,7 @@ minuteBucket := time.Now().UTC().Truncate(time.Minute)
 
 
 	// 3. Contar conexiones
-	respClients, _ := http.Get("http://srs:1985/api/v1/clients/")
+	respClients, _ := http.Get(m.srsClient.listURL("clients"))
 	publishers := 0
 	players := 0
 	totalConnections := 0
@@ -173,26 +218,35 @@ minuteBucket := time.Now().UTC().Truncate(time.Minute)
 	}
 
 	// Cambio: usar upsert para evitar duplicados por minuto (Firma: Cursor)
-	_, _, err = client.From("server_ingest_server_metrics").
-		Upsert(serverMetric, "server_id,minute_bucket", "", "").
-		Execute()
+	err = m.store.Metrics.SaveServerMetric(ctx, serverMetric)
 	if err != nil {
-		log.Printf("❌ Error guardando server_ingest_server_metrics: %v", err)
+		slog.ErrorContext(ctx, "❌ Error guardando server_ingest_server_metrics", "error", err)
 	}
 	// Cambio: actualizar last_seen del servidor (Firma: Cursor)
-	if err := m.supabase.UpdateServerHeartbeat(m.serverID, m.serverIP); err != nil {
-		log.Printf("⚠️ Error actualizando last_seen: %v", err)
+	if err := m.store.Servers.Heartbeat(ctx, m.serverID, m.serverIP); err != nil {
+		slog.WarnContext(ctx, "⚠️ Error actualizando last_seen", "error", err)
 	}
 
-	// 5. Guardar métricas de streams - ✅ CORREGIDO: Capturar 3 valores
+	// 5. Guardar métricas de streams en lote - ✅ CORREGIDO: Capturar 3 valores
+	streamMetrics := make([]map[string]interface{}, 0, len(srsStreamsResponse.Streams))
+	streamSamples := make([]telemetry.StreamSample, 0, len(srsStreamsResponse.Streams))
+	liveStreams := make([]models.LiveStream, 0, len(srsStreamsResponse.Streams))
+	// Mismo instante para todo el ciclo, aunque el outbox entregue las filas más tarde
+	sampledAt := time.Now().UTC()
 	for _, stream := range srsStreamsResponse.Streams {
 		resolution := ""
 		codec := ""
+		width, height := 0, 0
 		if stream.Video != nil {
 			resolution = fmt.Sprintf("%dx%d", stream.Video.Width, stream.Video.Height)
 			codec = stream.Video.Codec
+			width, height = stream.Video.Width, stream.Video.Height
 		}
 
+		// Canal y organización para el consumo diario: la clave puede rotar.
+		// Se resuelve una vez por stream y ciclo
+		tenant, _ := m.tenants.ForStream(ctx, stream.Name)
+
 		streamMetric := map[string]interface{}{
 			"server_id":     m.serverID,
 			"server_ip":     m.serverIP,
@@ -205,30 +259,75 @@ minuteBucket := time.Now().UTC().Truncate(time.Minute)
 			"is_publishing": stream.Publish.Active,
 			"video_codec":   codec,
 			"resolution":    resolution,
+			"channel_id":    tenant.ChannelID,
+			"timestamp":     sampledAt,
+		}
+		// organization_id es UUID: sin organización queda NULL
+		if tenant.OrganizationID != "" {
+			streamMetric["organization_id"] = tenant.OrganizationID
 		}
 
-		// Cambio: prefijo de tabla actualizado a server_ingest_ (Firma: Cursor)
-		_, _, err = client.From("server_ingest_stream_metrics").Insert(streamMetric, false, "", "", "").Execute()
-		if err != nil {
-			log.Printf("❌ Error guardando server_ingest_stream_metrics: %v", err)
+		streamMetrics = append(streamMetrics, streamMetric)
+		streamSamples = append(streamSamples, telemetry.StreamSample{
+			App:      stream.App,
+			PublicID: publicID(tenant),
+			RecvKbps: stream.Kbps.RecvKbps,
+			SendKbps: stream.Kbps.SendKbps,
+			Clients:  stream.Clients,
+		})
+		liveStreams = append(liveStreams, models.LiveStream{
+			ID:         stream.ID,
+			Name:       stream.Name,
+			App:        stream.App,
+			ChannelID:  tenant.ChannelID,
+			Clients:    stream.Clients,
+			RecvKbps:   stream.Kbps.RecvKbps,
+			SendKbps:   stream.Kbps.SendKbps,
+			IsPublish:  stream.Publish.Active,
+			VideoCodec: codec,
+			Width:      width,
+			Height:     height,
+		})
+
+		// Consumo mensual por organización: tiempo emitido y bytes de ingesta + salida
+		if stream.Publish.Active {
+			kbps := int64(stream.Kbps.RecvKbps + stream.Kbps.SendKbps)
+			bytes := kbps * 1000 / 8 * int64(collectInterval.Seconds())
+			m.tenants.RecordStreamSample(tenant, collectInterval, bytes)
 		}
 	}
+	// Cambio: prefijo de tabla actualizado a server_ingest_ (Firma: Cursor)
+	if err := m.store.Metrics.SaveStreamMetrics(ctx, streamMetrics); err != nil {
+		slog.ErrorContext(ctx, "❌ Error guardando server_ingest_stream_metrics", "error", err)
+	}
+	m.tenants.FlushUsage(ctx)
+	// Viewers en vivo por canal en channels_channel, en un único upsert
+	m.viewers.Sync(ctx)
+	telemetry.RecordServer(cpuPercent, memoryMB, publishers, players, streamSamples)
+	// Snapshot para el feed WebSocket, sin otra consulta a SRS
+	m.feed.Publish(models.LiveSnapshot{
+		Time: sampledAt,
+		Server: models.LiveServer{
+			ServerID:     m.serverID,
+			ServerIP:     m.serverIP,
+			CPU:          cpuPercent,
+			MemoryMB:     memoryMB,
+			TotalStreams: len(srsStreamsResponse.Streams),
+			Connections:  totalConnections,
+			Publishers:   publishers,
+			Players:      players,
+		},
+		Streams: liveStreams,
+	})
 
 	// 6. Alertas - ✅ CORREGIDO: Capturar 3 valores
 	if cpuPercent > 80 {
-		alert := map[string]interface{}{
-			"server_id":  m.serverID,
-			"server_ip":  m.serverIP,
-			"event_type": "high_cpu",
-			"severity":   "warning",
-			"message":    fmt.Sprintf("CPU alto en %s: %.1f%%", m.serverID, cpuPercent),
-			"metadata": map[string]interface{}{
+		m.store.Events.Record(ctx, m.serverID, m.serverIP, "high_cpu", "warning",
+			fmt.Sprintf("CPU alto en %s: %.1f%%", m.serverID, cpuPercent),
+			map[string]interface{}{
 				"server_id": m.serverID,
 				"cpu":       cpuPercent,
-			},
-		}
-		// Cambio: prefijo de tabla actualizado a server_ingest_ (Firma: Cursor)
-		client.From("server_ingest_system_events").Insert(alert, false, "", "", "").Execute()
+			})
 	}
 
 	// Cambio: guardar métricas de OS/IO/Others en tabla dedicada (Firma: Cursor)
@@ -242,14 +341,39 @@ minuteBucket := time.Now().UTC().Truncate(time.Minute)
 			"meminfos":          meminfosPayload,
 		}
 
-		_, _, err = client.From("server_ingest_system_metrics").Insert(systemMetrics, false, "", "", "").Execute()
+		err = m.store.Metrics.SaveSystemMetrics(ctx, systemMetrics)
 		if err != nil {
-			log.Printf("❌ Error guardando server_ingest_system_metrics: %v", err)
+			slog.ErrorContext(ctx, "❌ Error guardando server_ingest_system_metrics", "error", err)
 		}
 	}
 
-	log.Printf("✅ [%s] Métricas: CPU=%.1f%%, Mem=%dMB, Streams=%d, Conn=%d",
-		m.serverID, cpuPercent, memoryMB, len(srsStreamsResponse.Streams), totalConnections)
+	slog.InfoContext(ctx, "✅ Métricas recolectadas", "cpu_percent", cpuPercent, "memory_mb", memoryMB,
+		"streams", len(srsStreamsResponse.Streams), "connections", totalConnections)
+	m.logWrites()
+}
+
+// publicID identifica el stream en /metrics sin exponer la clave: el MD5 del
+// canal, igual que el nombre del thumbnail
+func publicID(tenant Tenant) string {
+	if tenant.ChannelID == "" {
+		return "unresolved"
+	}
+	return utils.GetMD5Hash(tenant.ChannelID)
+}
+
+// logWrites registra las peticiones a la base de datos desde el ciclo
+// anterior. Con outbox incluye las entregas en segundo plano de ese intervalo.
+func (m *MetricsCollector) logWrites() {
+	current := m.store.WriteStats()
+	delta := current.Sub(m.lastWrites)
+	m.lastWrites = current
+
+	avg := time.Duration(0)
+	if delta.Requests > 0 {
+		avg = delta.Latency / time.Duration(delta.Requests)
+	}
+	slog.Info("🗄️ Escrituras del ciclo", "requests", delta.Requests, "errors", delta.Errors,
+		"latency", delta.Latency.Round(time.Millisecond).String(), "avg_latency", avg.Round(time.Microsecond).String())
 }
 
 // Cambio: helper para leer JSON desde SRS (Firma: Cursor)
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"srs-backend/internal/storage"
+)
+
+// Formas de guardar las IPs de clientes (PRIVACY_IP_MODE)
+const (
+	PrivacyIPModeOff      = "off"
+	PrivacyIPModeHash     = "hash"
+	PrivacyIPModeTruncate = "truncate"
+)
+
+// Qué hace una solicitud de supresión con las sesiones
+const (
+	ErasureModeDelete    = "delete"
+	ErasureModeAnonymize = "anonymize"
+)
+
+// Prefijos que se conservan al truncar: la red de la IP, no el cliente
+const (
+	truncateIPv4Bits = 24
+	truncateIPv6Bits = 48
+)
+
+// Caracteres hex del hash guardado; client_ip es VARCHAR(50)
+const ipHashLength = 32
+
+// Espera máxima a que el outbox entregue lo pendiente antes de suprimir
+const erasureDrainTimeout = 15 * time.Second
+
+var (
+	ErrInvalidErasure = errors.New("solicitud de supresión inválida")
+	// ErrErasurePending indica que quedaban escrituras sin entregar: se
+	// aplicarían después del borrado y volverían a guardar los datos
+	ErrErasurePending = errors.New("escrituras pendientes en el outbox, reintentar la supresión")
+)
+
+// ErasureRequest pide borrar o anonimizar los datos de una IP o de un canal
+type ErasureRequest struct {
+	IP        string `json:"ip"`
+	ChannelID string `json:"channel_id"`
+	Mode      string `json:"mode"`
+}
+
+// ErasureResult cuenta las filas afectadas por tabla
+type ErasureResult struct {
+	Mode          string `json:"mode"`
+	Sessions      int64  `json:"sessions"`
+	Events        int64  `json:"events"`
+	StreamMetrics int64  `json:"stream_metrics"`
+}
+
+// PrivacyService decide cómo se guardan las IPs de clientes y atiende las
+// solicitudes de supresión. La geolocalización y las restricciones por país
+// usan la IP original: el anonimizado se aplica al guardar.
+type PrivacyService struct {
+	store    *storage.Store
+	mode     string
+	salt     []byte
+	serverID string
+	serverIP string
+}
+
+// NewPrivacyService falla con un modo desconocido o con hash sin sal, para
+// no guardar IPs en claro por un error de configuración.
+func NewPrivacyService(store *storage.Store, mode, salt, serverID, serverIP string) (*PrivacyService, error) {
+	switch mode {
+	case "", PrivacyIPModeOff:
+		mode = PrivacyIPModeOff
+	case PrivacyIPModeHash:
+		if salt == "" {
+			return nil, errors.New("PRIVACY_IP_SALT es obligatorio con PRIVACY_IP_MODE=hash")
+		}
+	case PrivacyIPModeTruncate:
+	default:
+		return nil, fmt.Errorf("PRIVACY_IP_MODE desconocido: %q (off, hash o truncate)", mode)
+	}
+	return &PrivacyService{
+		store:    store,
+		mode:     mode,
+		salt:     []byte(salt),
+		serverID: serverID,
+		serverIP: serverIP,
+	}, nil
+}
+
+func (p *PrivacyService) Mode() string {
+	return p.mode
+}
+
+// AnonymizeIP devuelve la IP tal como se guarda según el modo
+func (p *PrivacyService) AnonymizeIP(ip string) string {
+	switch p.mode {
+	case PrivacyIPModeHash:
+		return p.hashIP(ip)
+	case PrivacyIPModeTruncate:
+		return truncateIP(ip)
+	}
+	return ip
+}
+
+// hashIP es un HMAC-SHA256 con la sal: sin ella no se puede recorrer el
+// espacio de IPv4 para revertirlo. La misma IP da el mismo valor, así que
+// los viewers únicos se siguen contando.
+func (p *PrivacyService) hashIP(ip string) string {
+	mac := hmac.New(sha256.New, p.salt)
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))[:ipHashLength]
+}
+
+// truncateIP pone a cero los bits de host; "" si no es una IP
+func truncateIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(truncateIPv4Bits, 32)).String()
+	}
+	return parsed.Mask(net.CIDRMask(truncateIPv6Bits, 128)).String()
+}
+
+// Erase borra o anonimiza las sesiones de la IP o del canal y borra sus
+// eventos. Para un canal también borra las métricas de su clave actual y de
+// las claves en gracia (con delete). Las IPs truncadas no se buscan: la
+// misma red la comparten otros clientes.
+func (p *PrivacyService) Erase(ctx context.Context, req ErasureRequest) (*ErasureResult, error) {
+	ctx, span := tracer.Start(ctx, "privacy.erase")
+	defer span.End()
+
+	switch req.Mode {
+	case "":
+		req.Mode = ErasureModeDelete
+	case ErasureModeDelete, ErasureModeAnonymize:
+	default:
+		return nil, fmt.Errorf("%w: mode debe ser delete o anonymize", ErrInvalidErasure)
+	}
+	if (req.IP == "") == (req.ChannelID == "") {
+		return nil, fmt.Errorf("%w: indicar ip o channel_id", ErrInvalidErasure)
+	}
+
+	// Las sesiones acumuladas y lo que espera en el outbox se entregan antes
+	// de borrar; si no, llegarían después y recrearían los datos
+	drainCtx, cancel := context.WithTimeout(ctx, erasureDrainTimeout)
+	err := p.store.Drain(drainCtx)
+	cancel()
+	if err != nil {
+		slog.WarnContext(ctx, "⚠️ Supresión aplazada por escrituras pendientes", "error", err)
+		return nil, fmt.Errorf("%w: %v", ErrErasurePending, err)
+	}
+
+	result := &ErasureResult{Mode: req.Mode}
+	anonymize := req.Mode == ErasureModeAnonymize
+	if req.IP != "" {
+		err = p.eraseIP(ctx, req.IP, anonymize, result)
+	} else {
+		err = p.eraseChannel(ctx, req.ChannelID, anonymize, result)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Registro de la supresión, sin la IP
+	metadata := map[string]interface{}{
+		"target":         "ip",
+		"mode":           req.Mode,
+		"sessions":       result.Sessions,
+		"events":         result.Events,
+		"stream_metrics": result.StreamMetrics,
+	}
+	if req.ChannelID != "" {
+		metadata["target"] = "channel"
+		metadata["channel_id"] = req.ChannelID
+	}
+	go p.store.Events.Record(context.WithoutCancel(ctx), p.serverID, p.serverIP, "privacy_erasure", "info",
+		fmt.Sprintf("Supresión de datos por %s (%s)", metadata["target"], req.Mode), metadata)
+	return result, nil
+}
+
+func (p *PrivacyService) eraseIP(ctx context.Context, ip string, anonymize bool, result *ErasureResult) error {
+	if net.ParseIP(ip) == nil {
+		return fmt.Errorf("%w: %q no es una IP", ErrInvalidErasure, ip)
+	}
+	// Filas guardadas en claro y, si hay sal, con hash (también las de antes
+	// de un cambio de modo)
+	stored := []string{ip}
+	if len(p.salt) > 0 {
+		stored = append(stored, p.hashIP(ip))
+	}
+
+	for _, value := range stored {
+		n, err := p.store.Privacy.EraseSessions(ctx, "client_ip", value, anonymize)
+		if err != nil {
+			return err
+		}
+		result.Sessions += n
+		for _, key := range storage.EventIPKeys {
+			n, err := p.store.Privacy.DeleteEvents(ctx, key, value)
+			if err != nil {
+				return err
+			}
+			result.Events += n
+		}
+	}
+	slog.InfoContext(ctx, "🧽 Supresión por IP aplicada", "mode", result.Mode, "sessions", result.Sessions, "events", result.Events)
+	return nil
+}
+
+func (p *PrivacyService) eraseChannel(ctx context.Context, channelID string, anonymize bool, result *ErasureResult) error {
+	currentKey, exists, err := p.store.Channels.GetStreamKey(ctx, channelID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrChannelNotFound
+	}
+
+	if result.Sessions, err = p.store.Privacy.EraseSessions(ctx, "channel_id", channelID, anonymize); err != nil {
+		return err
+	}
+	if result.Events, err = p.store.Privacy.DeleteEvents(ctx, "channel_id", channelID); err != nil {
+		return err
+	}
+
+	// Las métricas por stream no tienen IPs; solo se borran con delete
+	if !anonymize {
+		var keys []string
+		if currentKey != "" {
+			keys = append(keys, currentKey)
+		}
+		grace, err := p.store.Channels.ListGraceKeys(ctx, channelID)
+		if err != nil {
+			return err
+		}
+		for _, g := range grace {
+			keys = append(keys, g.StreamKey)
+		}
+		for _, key := range keys {
+			n, err := p.store.Privacy.DeleteStreamMetrics(ctx, key)
+			if err != nil {
+				return err
+			}
+			result.StreamMetrics += n
+		}
+	}
+	slog.InfoContext(ctx, "🧽 Supresión por canal aplicada", "channel_id", channelID, "mode", result.Mode,
+		"sessions", result.Sessions, "events", result.Events, "stream_metrics", result.StreamMetrics)
+	return nil
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"srs-backend/internal/storage"
+)
+
+func TestTruncateIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"190.237.26.247", "190.237.26.0"},
+		{"190.237.26.0", "190.237.26.0"},
+		{"::ffff:190.237.26.247", "190.237.26.0"},
+		{"2001:db8:abcd:12:3:4:5:6", "2001:db8:abcd::"},
+		{"2001:db8::1", "2001:db8::"},
+		{"", ""},
+		{"no-es-una-ip", ""},
+	}
+	for _, tt := range tests {
+		if got := truncateIP(tt.ip); got != tt.want {
+			t.Errorf("truncateIP(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestHashIP(t *testing.T) {
+	store := storage.NewStore(storage.NewMemoryBackend())
+	p, err := NewPrivacyService(store, PrivacyIPModeHash, "sal-1", "srv-test", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewPrivacyService(store, PrivacyIPModeHash, "sal-2", "srv-test", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := p.AnonymizeIP("190.237.26.247")
+	tests := []struct {
+		name  string
+		got   string
+		equal bool
+	}{
+		{"misma IP y sal", p.AnonymizeIP("190.237.26.247"), true},
+		{"otra IP", p.AnonymizeIP("190.237.26.248"), false},
+		{"otra sal", other.AnonymizeIP("190.237.26.247"), false},
+	}
+	if len(h) != ipHashLength {
+		t.Fatalf("hash de %d caracteres, want %d", len(h), ipHashLength)
+	}
+	for _, tt := range tests {
+		if (tt.got == h) != tt.equal {
+			t.Errorf("%s: %q frente a %q, iguales = %v, want %v", tt.name, tt.got, h, tt.got == h, tt.equal)
+		}
+	}
+}
+
+func TestNewPrivacyService(t *testing.T) {
+	tests := []struct {
+		mode    string
+		salt    string
+		want    string
+		wantErr bool
+	}{
+		{"", "", PrivacyIPModeOff, false},
+		{PrivacyIPModeOff, "", PrivacyIPModeOff, false},
+		{PrivacyIPModeTruncate, "", PrivacyIPModeTruncate, false},
+		{PrivacyIPModeHash, "sal", PrivacyIPModeHash, false},
+		{PrivacyIPModeHash, "", "", true},
+		{"md5", "sal", "", true},
+	}
+	for _, tt := range tests {
+		p, err := NewPrivacyService(nil, tt.mode, tt.salt, "srv-test", "10.0.0.1")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewPrivacyService(%q, %q) error = %v, wantErr %v", tt.mode, tt.salt, err, tt.wantErr)
+			continue
+		}
+		if err == nil && p.Mode() != tt.want {
+			t.Errorf("NewPrivacyService(%q, %q) modo %q, want %q", tt.mode, tt.salt, p.Mode(), tt.want)
+		}
+	}
+}
+
+// newOutboxStore encola las escrituras en un outbox que aún no entrega
+func newOutboxStore(t *testing.T) (*storage.Store, *storage.MemoryBackend, *storage.Outbox) {
+	t.Helper()
+	backend := storage.NewMemoryBackend()
+	store := storage.NewStore(backend)
+	outbox, err := storage.NewOutbox(t.TempDir(), store.Deliver, 3, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetOutbox(outbox)
+	return store, backend, outbox
+}
+
+func TestEraseWaitsForOutbox(t *testing.T) {
+	ctx := context.Background()
+	store, backend, outbox := newOutboxStore(t)
+	p, err := NewPrivacyService(store, PrivacyIPModeOff, "", "srv-test", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Una sesión de la IP sigue en el outbox al pedir la supresión
+	store.Sessions.Open(ctx, map[string]interface{}{
+		"server_id":   "srv-test",
+		"client_id":   "c1",
+		"client_ip":   "190.237.26.247",
+		"client_type": "play",
+	})
+
+	// Sin entregar no se borra: la sesión llegaría después
+	shortCtx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer cancel()
+	if _, err := p.Erase(shortCtx, ErasureRequest{IP: "190.237.26.247"}); !errors.Is(err, ErrErasurePending) {
+		t.Fatalf("Erase con el outbox detenido: error = %v, want ErrErasurePending", err)
+	}
+
+	go outbox.Start()
+	result, err := p.Erase(ctx, ErasureRequest{IP: "190.237.26.247"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Sessions != 1 {
+		t.Errorf("sesiones borradas = %d, want 1", result.Sessions)
+	}
+	if err := store.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var rows []map[string]interface{}
+	backend.Select(ctx, storage.Query{Table: "server_ingest_client_connections"}, &rows)
+	if len(rows) != 0 {
+		t.Errorf("%d sesiones tras la supresión, want 0", len(rows))
+	}
+}
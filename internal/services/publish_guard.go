@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"srs-backend/internal/storage"
+)
+
+// PublishGuardLimits define los umbrales de la protección contra fuerza bruta
+// de claves de transmisión.
+type PublishGuardLimits struct {
+	MaxFailuresPerIP     int
+	MaxFailuresPerSubnet int
+	FailureWindow        time.Duration
+	BaseLockout          time.Duration
+	MaxLockout           time.Duration
+}
+
+// Lockout describe una fuente (IP o subred) bloqueada para publicar.
+type Lockout struct {
+	Source        string    `json:"source"`
+	Scope         string    `json:"scope"`
+	Failures      int       `json:"failures"`
+	Level         int       `json:"level"`
+	LockedUntil   time.Time `json:"locked_until"`
+	RemainingSecs int       `json:"remaining_seconds"`
+	LastFailureAt time.Time `json:"last_failure_at"`
+}
+
+type guardEntry struct {
+	failures    int
+	firstAt     time.Time
+	lastAt      time.Time
+	level       int
+	lockedUntil time.Time
+}
+
+// PublishGuard cuenta los intentos fallidos de publicación por IP y por subred
+// y aplica bloqueos exponenciales cuando se supera el umbral.
+type PublishGuard struct {
+	store     *storage.Store
+	serverID  string
+	serverIP  string
+	limits    PublishGuardLimits
+	mu        sync.Mutex
+	ips       map[string]*guardEntry
+	subnets   map[string]*guardEntry
+	lastPrune time.Time
+}
+
+func NewPublishGuard(store *storage.Store, serverID, serverIP string, limits PublishGuardLimits) *PublishGuard {
+	return &PublishGuard{
+		store:    store,
+		serverID: serverID,
+		serverIP: serverIP,
+		limits:   limits,
+		ips:      make(map[string]*guardEntry),
+		subnets:  make(map[string]*guardEntry),
+	}
+}
+
+// IsLocked indica si la IP o su subred están bloqueadas en este momento.
+func (g *PublishGuard) IsLocked(ip string) (bool, time.Time) {
+	now := time.Now()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	until := time.Time{}
+	if e, ok := g.ips[ip]; ok && e.lockedUntil.After(now) {
+		until = e.lockedUntil
+	}
+	if subnet := subnetOf(ip); subnet != "" {
+		if e, ok := g.subnets[subnet]; ok && e.lockedUntil.After(until) {
+			until = e.lockedUntil
+		}
+	}
+
+	return until.After(now), until
+}
+
+// RecordFailure registra un intento fallido y bloquea la IP o la subred si
+// superan su umbral dentro de la ventana.
+func (g *PublishGuard) RecordFailure(ctx context.Context, ip, app string) {
+	now := time.Now()
+	subnet := subnetOf(ip)
+
+	g.mu.Lock()
+	g.pruneLocked(now)
+	var triggered []Lockout
+	if l, ok := g.registerLocked(g.ips, ip, "ip", g.limits.MaxFailuresPerIP, now); ok {
+		triggered = append(triggered, l)
+	}
+	if subnet != "" {
+		if l, ok := g.registerLocked(g.subnets, subnet, "subnet", g.limits.MaxFailuresPerSubnet, now); ok {
+			triggered = append(triggered, l)
+		}
+	}
+	g.mu.Unlock()
+
+	for _, l := range triggered {
+		slog.WarnContext(ctx, "🚫 Fuerza bruta de claves detectada", "scope", l.Scope, "source", l.Source,
+			"failures", l.Failures, "locked_seconds", l.RemainingSecs)
+		// Con PRIVACY_IP_MODE la IP se guarda anonimizada; la subred ya es una red
+		source := l.Source
+		if l.Scope == "ip" {
+			source = g.store.ClientIP(source)
+		}
+		go g.store.Events.Record(context.WithoutCancel(ctx), g.serverID, g.serverIP, "publish_key_bruteforce", "warning",
+			fmt.Sprintf("Intentos de publicación con claves inválidas desde %s %s", l.Scope, source),
+			map[string]interface{}{
+				"server_id":       g.serverID,
+				"source":          source,
+				"scope":           l.Scope,
+				"client_ip":       ip,
+				"app":             app,
+				"failures":        l.Failures,
+				"lockout_level":   l.Level,
+				"lockout_seconds": l.RemainingSecs,
+				"locked_until":    l.LockedUntil,
+			})
+	}
+}
+
+// RecordSuccess limpia los fallos de la IP tras una publicación válida. La
+// subred conserva su contador para no perdonar ataques distribuidos.
+func (g *PublishGuard) RecordSuccess(ip string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if e, ok := g.ips[ip]; ok && !e.lockedUntil.After(time.Now()) {
+		delete(g.ips, ip)
+	}
+}
+
+// Lockouts devuelve las fuentes bloqueadas actualmente.
+func (g *PublishGuard) Lockouts() []Lockout {
+	now := time.Now()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	lockouts := []Lockout{}
+	collect := func(entries map[string]*guardEntry, scope string) {
+		for source, e := range entries {
+			if e.lockedUntil.After(now) {
+				lockouts = append(lockouts, toLockout(source, scope, e, now))
+			}
+		}
+	}
+	collect(g.ips, "ip")
+	collect(g.subnets, "subnet")
+
+	sort.Slice(lockouts, func(i, j int) bool {
+		return lockouts[i].LockedUntil.After(lockouts[j].LockedUntil)
+	})
+	return lockouts
+}
+
+func (g *PublishGuard) registerLocked(entries map[string]*guardEntry, source, scope string, max int, now time.Time) (Lockout, bool) {
+	if max <= 0 {
+		return Lockout{}, false
+	}
+
+	e, ok := entries[source]
+	if !ok {
+		e = &guardEntry{firstAt: now}
+		entries[source] = e
+	}
+	if e.lockedUntil.After(now) {
+		e.lastAt = now
+		return Lockout{}, false
+	}
+	// Tras expirar un bloqueo el contador vuelve a cero, pero el nivel se
+	// conserva para que el siguiente bloqueo dure el doble.
+	if now.Sub(e.firstAt) > g.limits.FailureWindow || e.failures >= max {
+		e.failures = 0
+		e.firstAt = now
+	}
+
+	e.failures++
+	e.lastAt = now
+	if e.failures < max {
+		return Lockout{}, false
+	}
+
+	// Bloqueo exponencial: base * 2^nivel, con tope en MaxLockout. El nivel
+	// deja de subir al llegar al tope, así el desplazamiento no desborda.
+	duration := g.limits.MaxLockout
+	if g.limits.BaseLockout > 0 && e.level < 63 && g.limits.BaseLockout <= g.limits.MaxLockout>>e.level {
+		duration = g.limits.BaseLockout << e.level
+	}
+	if duration < g.limits.MaxLockout {
+		e.level++
+	}
+	e.lockedUntil = now.Add(duration)
+
+	return toLockout(source, scope, e, now), true
+}
+
+// pruneLocked elimina entradas sin bloqueo activo ni fallos recientes. El
+// nivel de bloqueo se olvida tras MaxLockout sin actividad.
+func (g *PublishGuard) pruneLocked(now time.Time) {
+	if now.Sub(g.lastPrune) < time.Minute {
+		return
+	}
+	g.lastPrune = now
+
+	for _, entries := range []map[string]*guardEntry{g.ips, g.subnets} {
+		for source, e := range entries {
+			if e.lockedUntil.After(now) {
+				continue
+			}
+			idle := now.Sub(e.lastAt)
+			if (e.level == 0 && idle > g.limits.FailureWindow) || idle > g.limits.MaxLockout {
+				delete(entries, source)
+			}
+		}
+	}
+}
+
+func toLockout(source, scope string, e *guardEntry, now time.Time) Lockout {
+	return Lockout{
+		Source:        source,
+		Scope:         scope,
+		Failures:      e.failures,
+		Level:         e.level,
+		LockedUntil:   e.lockedUntil,
+		RemainingSecs: int(e.lockedUntil.Sub(now).Seconds()),
+		LastFailureAt: e.lastAt,
+	}
+}
+
+// subnetOf agrupa IPv4 por /24 e IPv6 por /64.
+func subnetOf(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}).String()
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"srs-backend/internal/storage"
+)
+
+func newTestGuard(limits PublishGuardLimits) *PublishGuard {
+	return NewPublishGuard(storage.NewStore(storage.NewMemoryBackend()), "srv-test", "10.0.0.1", limits)
+}
+
+func TestSubnetOf(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"203.0.113.7", "203.0.113.0/24"},
+		{"203.0.113.250", "203.0.113.0/24"},
+		{"::ffff:203.0.113.7", "203.0.113.0/24"},
+		{"2001:db8:1:2:3:4:5:6", "2001:db8:1:2::/64"},
+		{"2001:db8:1:2::1", "2001:db8:1:2::/64"},
+		{"", ""},
+		{"no-es-una-ip", ""},
+	}
+	for _, tt := range tests {
+		if got := subnetOf(tt.ip); got != tt.want {
+			t.Errorf("subnetOf(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestPublishGuardLockoutEscalation(t *testing.T) {
+	limits := PublishGuardLimits{
+		MaxFailuresPerIP: 3,
+		FailureWindow:    time.Minute,
+		BaseLockout:      time.Minute,
+		MaxLockout:       5 * time.Minute,
+	}
+	g := newTestGuard(limits)
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Cada ronda son MaxFailuresPerIP fallos tras expirar el bloqueo anterior
+	tests := []struct {
+		name     string
+		wantLock time.Duration
+		level    int
+	}{
+		{"primer bloqueo", time.Minute, 1},
+		{"segundo dobla", 2 * time.Minute, 2},
+		{"tercero dobla", 4 * time.Minute, 3},
+		{"cuarto con tope", 5 * time.Minute, 3},
+		{"quinto con tope sin subir de nivel", 5 * time.Minute, 3},
+	}
+	now := start
+	for _, tt := range tests {
+		var lockout Lockout
+		var locked bool
+		for i := 0; i < limits.MaxFailuresPerIP; i++ {
+			if locked {
+				t.Fatalf("%s: bloqueado antes del umbral, fallo %d", tt.name, i)
+			}
+			lockout, locked = g.registerLocked(g.ips, "203.0.113.7", "ip", limits.MaxFailuresPerIP, now)
+			now = now.Add(time.Second)
+		}
+		if !locked {
+			t.Fatalf("%s: no bloqueado tras %d fallos", tt.name, limits.MaxFailuresPerIP)
+		}
+		lockedAt := now.Add(-time.Second)
+		if got := lockout.LockedUntil.Sub(lockedAt); got != tt.wantLock {
+			t.Errorf("%s: bloqueo de %s, want %s", tt.name, got, tt.wantLock)
+		}
+		if lockout.Level != tt.level {
+			t.Errorf("%s: nivel %d, want %d", tt.name, lockout.Level, tt.level)
+		}
+
+		// Durante el bloqueo los fallos no lo alargan
+		if _, again := g.registerLocked(g.ips, "203.0.113.7", "ip", limits.MaxFailuresPerIP, now); again {
+			t.Errorf("%s: un fallo durante el bloqueo volvió a bloquear", tt.name)
+		}
+		now = lockout.LockedUntil.Add(time.Second)
+	}
+}
+
+func TestPublishGuardLockoutLevelCap(t *testing.T) {
+	limits := PublishGuardLimits{
+		MaxFailuresPerIP: 1,
+		FailureWindow:    time.Minute,
+		BaseLockout:      time.Minute,
+		MaxLockout:       24 * time.Hour,
+	}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Entradas con nivel alto, como tras muchas rondas de fallos
+	tests := []struct {
+		level     int
+		wantLock  time.Duration
+		wantLevel int
+	}{
+		{0, time.Minute, 1},
+		{10, 1024 * time.Minute, 11},
+		{11, 24 * time.Hour, 11},
+		{60, 24 * time.Hour, 60},
+		{62, 24 * time.Hour, 62},
+		{63, 24 * time.Hour, 63},
+		{64, 24 * time.Hour, 64},
+		{100, 24 * time.Hour, 100},
+	}
+	for _, tt := range tests {
+		g := newTestGuard(limits)
+		g.ips["203.0.113.7"] = &guardEntry{level: tt.level, firstAt: now, lastAt: now}
+		lockout, locked := g.registerLocked(g.ips, "203.0.113.7", "ip", limits.MaxFailuresPerIP, now)
+		if !locked {
+			t.Fatalf("nivel %d: no bloqueado", tt.level)
+		}
+		if got := lockout.LockedUntil.Sub(now); got != tt.wantLock {
+			t.Errorf("nivel %d: bloqueo de %s, want %s", tt.level, got, tt.wantLock)
+		}
+		if lockout.Level != tt.wantLevel {
+			t.Errorf("nivel %d: queda en %d, want %d", tt.level, lockout.Level, tt.wantLevel)
+		}
+	}
+
+	// Desde cero el nivel se detiene en el tope aunque sigan los bloqueos
+	g := newTestGuard(limits)
+	for i := 0; i < 70; i++ {
+		lockout, locked := g.registerLocked(g.ips, "203.0.113.7", "ip", limits.MaxFailuresPerIP, now)
+		if !locked {
+			t.Fatalf("ronda %d: no bloqueado", i)
+		}
+		if got := lockout.LockedUntil.Sub(now); got <= 0 || got > limits.MaxLockout {
+			t.Fatalf("ronda %d: bloqueo de %s fuera de (0, %s]", i, got, limits.MaxLockout)
+		}
+		now = lockout.LockedUntil.Add(time.Second)
+	}
+	if level := g.ips["203.0.113.7"].level; level != 11 {
+		t.Errorf("nivel tras 70 bloqueos = %d, want 11", level)
+	}
+}
+
+func TestPublishGuardFailureWindow(t *testing.T) {
+	limits := PublishGuardLimits{
+		MaxFailuresPerIP: 3,
+		FailureWindow:    time.Minute,
+		BaseLockout:      time.Minute,
+		MaxLockout:       time.Hour,
+	}
+	g := newTestGuard(limits)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Fallos espaciados más que la ventana no se acumulan
+	for i := 0; i < 5; i++ {
+		if _, locked := g.registerLocked(g.ips, "203.0.113.7", "ip", limits.MaxFailuresPerIP, now); locked {
+			t.Fatalf("bloqueado en el fallo %d", i)
+		}
+		now = now.Add(2 * time.Minute)
+	}
+	if e := g.ips["203.0.113.7"]; e.level != 0 {
+		t.Errorf("nivel %d con fallos fuera de la ventana, want 0", e.level)
+	}
+
+	// Un umbral de 0 desactiva el bloqueo
+	for i := 0; i < 10; i++ {
+		if _, locked := g.registerLocked(g.subnets, "203.0.113.0/24", "subnet", 0, now); locked {
+			t.Fatal("bloqueado con umbral 0")
+		}
+	}
+}
+
+func TestPublishGuardSubnetAndSuccess(t *testing.T) {
+	g := newTestGuard(PublishGuardLimits{
+		MaxFailuresPerIP:     100,
+		MaxFailuresPerSubnet: 4,
+		FailureWindow:        time.Minute,
+		BaseLockout:          time.Minute,
+		MaxLockout:           time.Hour,
+	})
+	ctx := context.Background()
+
+	// Un ataque repartido entre IPs de la misma /24 bloquea la subred
+	for _, ip := range []string{"198.51.100.1", "198.51.100.2", "198.51.100.3"} {
+		g.RecordFailure(ctx, ip, "live")
+	}
+	if locked, _ := g.IsLocked("198.51.100.9"); locked {
+		t.Fatal("subred bloqueada antes del umbral")
+	}
+	g.RecordFailure(ctx, "198.51.100.4", "live")
+	if locked, _ := g.IsLocked("198.51.100.9"); !locked {
+		t.Fatal("subred sin bloquear tras el umbral")
+	}
+	if locked, _ := g.IsLocked("198.51.101.9"); locked {
+		t.Fatal("bloqueada otra subred")
+	}
+
+	lockouts := g.Lockouts()
+	if len(lockouts) != 1 || lockouts[0].Scope != "subnet" || lockouts[0].Source != "198.51.100.0/24" {
+		t.Fatalf("Lockouts() = %+v, want solo la subred 198.51.100.0/24", lockouts)
+	}
+
+	// Un publish válido limpia la IP pero no la subred
+	g.RecordSuccess("198.51.100.1")
+	if _, ok := g.ips["198.51.100.1"]; ok {
+		t.Error("RecordSuccess no limpió la IP")
+	}
+	if locked, _ := g.IsLocked("198.51.100.1"); !locked {
+		t.Error("RecordSuccess levantó el bloqueo de la subred")
+	}
+}
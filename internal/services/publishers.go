@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"srs-backend/internal/storage"
+)
+
+// Políticas ante un segundo publicador con la misma clave
+const (
+	DuplicatePolicyReject   = "reject"
+	DuplicatePolicyTakeover = "takeover"
+	DuplicatePolicyFailover = "failover"
+)
+
+// Un publicador recién admitido aún no aparece en SRS: el hook se responde
+// antes de que empiece a publicar
+const publisherSettleTime = 10 * time.Second
+
+// Publisher es el encoder que publica actualmente un stream.
+type Publisher struct {
+	StreamKey string    `json:"-"`
+	App       string    `json:"app"`
+	ChannelID string    `json:"channel_id"`
+	ClientID  string    `json:"client_id"`
+	IP        string    `json:"ip"`
+	Country   string    `json:"country,omitempty"`
+	Since     time.Time `json:"since"`
+}
+
+// PublisherTracker registra el publicador vigente de cada clave y decide qué
+// hacer cuando llega otro encoder con la misma clave.
+type PublisherTracker struct {
+	store     *storage.Store
+	srsClient *SRSClient
+	geoIP     *GeoIPService
+	serverID  string
+	serverIP  string
+	policy    string
+	mu        sync.Mutex
+	active    map[string]*Publisher
+	// IP del primer encoder de la emisión, usada por la política failover
+	primaries map[string]string
+}
+
+func NewPublisherTracker(store *storage.Store, geoIP *GeoIPService, serverID, serverIP, policy string) *PublisherTracker {
+	switch policy {
+	case DuplicatePolicyReject, DuplicatePolicyTakeover, DuplicatePolicyFailover:
+	default:
+		slog.Warn("⚠️ DUPLICATE_PUBLISH_POLICY desconocida", "policy", policy, "fallback", DuplicatePolicyReject)
+		policy = DuplicatePolicyReject
+	}
+
+	return &PublisherTracker{
+		store:     store,
+		srsClient: NewSRSClient(),
+		geoIP:     geoIP,
+		serverID:  serverID,
+		serverIP:  serverIP,
+		policy:    policy,
+		active:    make(map[string]*Publisher),
+		primaries: make(map[string]string),
+	}
+}
+
+// Admit decide si el publicador puede tomar la clave. Con takeover, o cuando
+// el primario recupera su stream en failover, expulsa al publicador anterior.
+func (t *PublisherTracker) Admit(ctx context.Context, p Publisher) bool {
+	if t.geoIP != nil && p.Country == "" {
+		p.Country = t.geoIP.Lookup(p.IP).CountryCode
+	}
+
+	// La consulta a SRS se hace sin el lock: si otro on_publish de la misma
+	// clave cambia el publicador entretanto, se decide de nuevo
+	for {
+		t.mu.Lock()
+		current, ok := t.active[p.StreamKey]
+		if !ok || current.ClientID == p.ClientID {
+			t.registerLocked(p)
+			t.mu.Unlock()
+			return true
+		}
+		existing := *current
+		primaryIP := t.primaries[p.StreamKey]
+		t.mu.Unlock()
+
+		// El registro puede quedar obsoleto si se perdió un on_unpublish
+		if time.Since(existing.Since) >= publisherSettleTime {
+			if cids, err := t.srsClient.FindPublishers(existing.App, p.StreamKey); err == nil && !slices.Contains(cids, existing.ClientID) {
+				if !t.replace(existing, p) {
+					continue
+				}
+				slog.InfoContext(ctx, "♻️ Publicador anterior ya no está en SRS, se reemplaza", "previous_client_id", existing.ClientID)
+				return true
+			}
+		}
+
+		accept := false
+		kick := false
+		switch t.policy {
+		case DuplicatePolicyTakeover:
+			accept, kick = true, true
+		case DuplicatePolicyFailover:
+			// El primario recupera el stream; un respaldo espera a que el
+			// primario caiga (el encoder reintenta solo).
+			if primaryIP != "" && p.IP == primaryIP && existing.IP != primaryIP {
+				accept, kick = true, true
+			}
+		}
+		if accept && !t.replace(existing, p) {
+			continue
+		}
+
+		action := "rejected"
+		if accept {
+			action = "takeover"
+		}
+		slog.WarnContext(ctx, "⚠️ Publicador duplicado", "channel_id", existing.ChannelID,
+			"previous_client_id", existing.ClientID, "previous_ip", existing.IP, "ip", p.IP, "policy", t.policy, "action", action)
+
+		if existing.IP != p.IP || existing.Country != p.Country {
+			go t.recordDuplicate(context.WithoutCancel(ctx), existing, p, action)
+		}
+
+		// Se expulsa después de registrar al nuevo: el on_unpublish del
+		// anterior ya no deja el canal offline
+		if kick {
+			if err := t.srsClient.KickClient(existing.ClientID); err != nil {
+				slog.ErrorContext(ctx, "❌ Error expulsando publicador", "previous_client_id", existing.ClientID, "error", err)
+			}
+		}
+		return accept
+	}
+}
+
+// replace registra p si la clave sigue siendo de previous o ya quedó libre;
+// false si otro publicador la tomó mientras tanto
+func (t *PublisherTracker) replace(previous, p Publisher) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if current, ok := t.active[p.StreamKey]; ok && current.ClientID != previous.ClientID {
+		return false
+	}
+	t.registerLocked(p)
+	return true
+}
+
+// Release elimina al publicador al recibir on_unpublish. Devuelve false si la
+// clave ya pertenece a otro publicador (p. ej. tras un takeover), en cuyo caso
+// el canal no debe marcarse offline.
+func (t *PublisherTracker) Release(streamKey, clientID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	current, ok := t.active[streamKey]
+	if !ok {
+		return true
+	}
+	if clientID != "" && current.ClientID != clientID {
+		return false
+	}
+
+	delete(t.active, streamKey)
+	// Si cae un respaldo sin primario activo se olvida la emisión
+	if current.IP != t.primaries[streamKey] {
+		delete(t.primaries, streamKey)
+	}
+	return true
+}
+
+// Get devuelve el publicador actual de una clave
+func (t *PublisherTracker) Get(streamKey string) (Publisher, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.active[streamKey]
+	if !ok {
+		return Publisher{}, false
+	}
+	return *p, true
+}
+
+// List devuelve los publicadores activos ordenados por antigüedad
+func (t *PublisherTracker) List() []Publisher {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	publishers := make([]Publisher, 0, len(t.active))
+	for _, p := range t.active {
+		publishers = append(publishers, *p)
+	}
+	sort.Slice(publishers, func(i, j int) bool {
+		return publishers[i].Since.Before(publishers[j].Since)
+	})
+	return publishers
+}
+
+func (t *PublisherTracker) registerLocked(p Publisher) {
+	if p.Since.IsZero() {
+		p.Since = time.Now().UTC()
+	}
+	t.active[p.StreamKey] = &p
+	if _, ok := t.primaries[p.StreamKey]; !ok {
+		t.primaries[p.StreamKey] = p.IP
+	}
+}
+
+func (t *PublisherTracker) recordDuplicate(ctx context.Context, existing, incoming Publisher, action string) {
+	t.store.Events.Record(ctx, t.serverID, t.serverIP, "publish_duplicate_source", "warning",
+		fmt.Sprintf("Segundo encoder desde %s para canal %s (actual desde %s)", t.sourceLabel(incoming), existing.ChannelID, t.sourceLabel(existing)),
+		map[string]interface{}{
+			"server_id":          t.serverID,
+			"channel_id":         existing.ChannelID,
+			"app":                existing.App,
+			"policy":             t.policy,
+			"action":             action,
+			"current_client_id":  existing.ClientID,
+			"current_ip":         existing.IP,
+			"current_country":    existing.Country,
+			"incoming_client_id": incoming.ClientID,
+			"incoming_ip":        incoming.IP,
+			"incoming_country":   incoming.Country,
+		})
+}
+
+// sourceLabel describe el origen de un encoder: IP (anonimizada con
+// PRIVACY_IP_MODE) y país si se conoce
+func (t *PublisherTracker) sourceLabel(p Publisher) string {
+	ip := t.store.ClientIP(p.IP)
+	if p.Country == "" {
+		return ip
+	}
+	return fmt.Sprintf("%s (%s)", ip, p.Country)
+}
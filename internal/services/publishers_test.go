@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"srs-backend/internal/storage"
+)
+
+// newTestTracker usa un SRS falso sin publicadores activos
+func newTestTracker(t *testing.T, backend *storage.MemoryBackend, policy string, srsDelay time.Duration) *PublisherTracker {
+	srs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(srsDelay)
+		w.Write([]byte(`{"code":0,"streams":[]}`))
+	}))
+	t.Cleanup(srs.Close)
+
+	tracker := NewPublisherTracker(storage.NewStore(backend), nil, "srv-test", "10.0.0.1", policy)
+	tracker.srsClient = &SRSClient{baseURL: srs.URL}
+	return tracker
+}
+
+func TestPublisherTrackerConcurrentAdmit(t *testing.T) {
+	ctx := context.Background()
+	tracker := newTestTracker(t, storage.NewMemoryBackend(), DuplicatePolicyReject, 50*time.Millisecond)
+
+	// Un publicador que SRS ya no tiene (se perdió el on_unpublish)
+	tracker.Admit(ctx, Publisher{StreamKey: "key1", App: "live", ClientID: "stale", IP: "203.0.113.1",
+		Since: time.Now().Add(-time.Minute)})
+
+	var admitted atomic.Int32
+	var wg sync.WaitGroup
+	for _, clientID := range []string{"a", "b", "c", "d"} {
+		wg.Add(1)
+		go func(clientID string) {
+			defer wg.Done()
+			if tracker.Admit(ctx, Publisher{StreamKey: "key1", App: "live", ClientID: clientID, IP: "198.51.100.7"}) {
+				admitted.Add(1)
+			}
+		}(clientID)
+	}
+	wg.Wait()
+
+	if n := admitted.Load(); n != 1 {
+		t.Fatalf("admitidos %d publicadores concurrentes con reject, want 1", n)
+	}
+	if p, _ := tracker.Get("key1"); p.ClientID == "stale" {
+		t.Fatal("el publicador obsoleto sigue registrado")
+	}
+}
+
+func TestPublisherTrackerSettleTime(t *testing.T) {
+	ctx := context.Background()
+	tracker := newTestTracker(t, storage.NewMemoryBackend(), DuplicatePolicyReject, 0)
+
+	// Recién admitido todavía no está en SRS: no cuenta como obsoleto
+	if !tracker.Admit(ctx, Publisher{StreamKey: "key1", App: "live", ClientID: "a", IP: "198.51.100.7"}) {
+		t.Fatal("primer publicador rechazado")
+	}
+	if tracker.Admit(ctx, Publisher{StreamKey: "key1", App: "live", ClientID: "b", IP: "198.51.100.8"}) {
+		t.Fatal("segundo publicador admitido con reject")
+	}
+	if p, _ := tracker.Get("key1"); p.ClientID != "a" {
+		t.Fatalf("publicador %q, want a", p.ClientID)
+	}
+}
+
+func TestPublisherTrackerDuplicateEvent(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  Publisher
+		incoming  Publisher
+		wantEvent bool
+	}{
+		{
+			name:      "misma IP y país",
+			existing:  Publisher{IP: "198.51.100.7", Country: "ES"},
+			incoming:  Publisher{IP: "198.51.100.7", Country: "ES"},
+			wantEvent: false,
+		},
+		{
+			name:      "otra IP",
+			existing:  Publisher{IP: "198.51.100.7", Country: "ES"},
+			incoming:  Publisher{IP: "198.51.100.8", Country: "ES"},
+			wantEvent: true,
+		},
+		{
+			name:      "otro país",
+			existing:  Publisher{IP: "198.51.100.7", Country: "ES"},
+			incoming:  Publisher{IP: "198.51.100.7", Country: "FR"},
+			wantEvent: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			backend := storage.NewMemoryBackend()
+			tracker := newTestTracker(t, backend, DuplicatePolicyReject, 0)
+
+			existing, incoming := tt.existing, tt.incoming
+			existing.StreamKey, existing.ClientID = "key1", "a"
+			incoming.StreamKey, incoming.ClientID = "key1", "b"
+			tracker.Admit(ctx, existing)
+			tracker.Admit(ctx, incoming)
+
+			// El evento se guarda en segundo plano
+			var events []map[string]interface{}
+			for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+				events = nil
+				backend.Select(ctx, storage.Query{Table: "server_ingest_system_events"}, &events)
+				if len(events) > 0 || !tt.wantEvent {
+					break
+				}
+			}
+			if got := len(events) > 0; got != tt.wantEvent {
+				t.Fatalf("evento publish_duplicate_source = %v, want %v", got, tt.wantEvent)
+			}
+		})
+	}
+}
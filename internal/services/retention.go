@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"srs-backend/internal/storage"
+)
+
+// Cada borrado abarca como máximo una hora de filas, para no superar el
+// tiempo máximo de una escritura en la primera pasada sobre tablas grandes
+const retentionChunk = time.Hour
+
+// RetentionPolicy es la antigüedad máxima de las filas de cada tabla; 0 las
+// conserva
+type RetentionPolicy struct {
+	Sessions      time.Duration
+	Events        time.Duration
+	StreamMetrics time.Duration
+	ServerMetrics time.Duration
+	SystemMetrics time.Duration
+}
+
+func (p RetentionPolicy) tables() map[string]time.Duration {
+	return map[string]time.Duration{
+		"server_ingest_client_connections": p.Sessions,
+		"server_ingest_system_events":      p.Events,
+		"server_ingest_stream_metrics":     p.StreamMetrics,
+		"server_ingest_server_metrics":     p.ServerMetrics,
+		"server_ingest_system_metrics":     p.SystemMetrics,
+	}
+}
+
+// RetentionService borra las filas más antiguas que la retención de cada
+// tabla según su columna timestamp. Si corre en varios servidores los
+// borrados se repiten sin efecto.
+type RetentionService struct {
+	store    *storage.Store
+	policy   RetentionPolicy
+	interval time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewRetentionService(store *storage.Store, policy RetentionPolicy, interval time.Duration) *RetentionService {
+	return &RetentionService{
+		store:    store,
+		policy:   policy,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start aplica la retención al arrancar y luego cada interval. Bloquea;
+// usar con go.
+func (r *RetentionService) Start() {
+	defer close(r.done)
+	slog.Info("🗑️ Retención de datos iniciada", "interval", r.interval.String())
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		r.sweep()
+		select {
+		case <-ticker.C:
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Stop espera a que termine la pasada en curso
+func (r *RetentionService) Stop(ctx context.Context) {
+	close(r.stop)
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+	}
+}
+
+func (r *RetentionService) sweep() {
+	ctx, span := tracer.Start(context.Background(), "retention.sweep")
+	defer span.End()
+
+	now := time.Now().UTC()
+	for table, maxAge := range r.policy.tables() {
+		if maxAge <= 0 {
+			continue
+		}
+		if err := r.purge(ctx, table, now.Add(-maxAge)); err != nil {
+			slog.ErrorContext(ctx, "❌ Error aplicando retención", "table", table, "error", err)
+		}
+	}
+}
+
+// purge borra por tramos desde la fila más antigua hasta cutoff. Cada tramo
+// empieza en la fila más antigua que quede, así que los huecos no cuestan
+// consultas. Se interrumpe si el servicio se detiene.
+func (r *RetentionService) purge(ctx context.Context, table string, cutoff time.Time) error {
+	chunks := 0
+	var previous time.Time
+	for {
+		oldest, ok, err := r.store.Privacy.Oldest(ctx, table)
+		if err != nil {
+			return err
+		}
+		if !ok || !oldest.Before(cutoff) {
+			break
+		}
+		if chunks > 0 && !oldest.After(previous) {
+			return fmt.Errorf("el borrado anterior a %s no avanzó", previous.Format(time.RFC3339))
+		}
+		previous = oldest
+		if err := r.store.Privacy.DeleteBefore(ctx, table, minTime(oldest.Add(retentionChunk), cutoff)); err != nil {
+			return err
+		}
+		chunks++
+
+		select {
+		case <-r.stop:
+			return nil
+		default:
+		}
+	}
+	if chunks > 0 {
+		slog.InfoContext(ctx, "🗑️ Retención aplicada", "table", table, "before", cutoff.Format(time.RFC3339), "chunks", chunks)
+	}
+	return nil
+}
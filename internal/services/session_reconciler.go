@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"srs-backend/internal/logging"
+	"srs-backend/internal/models"
+	"srs-backend/internal/storage"
+)
+
+// Antigüedad mínima de una sesión o cliente para reconciliarlo: evita cerrar
+// una sesión cuyo cliente aún no aparece en SRS o adoptar un cliente cuyo
+// on_play se está procesando.
+const reconcileGrace = 30 * time.Second
+
+// Espera máxima a que se entreguen las escrituras pendientes antes de
+// reconciliar; si no se vacían se omite la ronda.
+const reconcileDrainTimeout = 5 * time.Second
+
+// SessionReconciler compara las sesiones abiertas (en memoria y en la base de
+// datos) con los clientes de SRS. Cierra las que perdieron su on_stop y
+// adopta los clientes sin sesión, por ejemplo tras reiniciar el backend. En
+// cada ronda toma también los contadores de bytes de cada cliente.
+type SessionReconciler struct {
+	store     *storage.Store
+	srsClient *SRSClient
+	tracker   *SessionTracker
+	tenants   *TenantService
+	serverID  string
+	interval  time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewSessionReconciler(store *storage.Store, tracker *SessionTracker, tenants *TenantService, serverID string, interval time.Duration) *SessionReconciler {
+	return &SessionReconciler{
+		store:     store,
+		srsClient: NewSRSClient(),
+		tracker:   tracker,
+		tenants:   tenants,
+		serverID:  serverID,
+		interval:  interval,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start reconcilia al arrancar y luego cada interval. Bloquea; usar con go.
+func (r *SessionReconciler) Start() {
+	defer close(r.done)
+	slog.Info("🧹 Reconciliador de sesiones iniciado", "interval", r.interval.String())
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		r.reconcile()
+		select {
+		case <-ticker.C:
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Stop espera a que termine la ronda en curso
+func (r *SessionReconciler) Stop(ctx context.Context) {
+	close(r.stop)
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+	}
+}
+
+func (r *SessionReconciler) reconcile() {
+	ctx, span := tracer.Start(context.Background(), "sessions.reconcile")
+	defer span.End()
+
+	// Con escrituras de sesiones sin entregar la base de datos no refleja los
+	// on_play y on_stop ya procesados: una sesión cerrada en memoria seguiría
+	// abierta en la consulta y se cerraría como huérfana.
+	drainCtx, cancel := context.WithTimeout(ctx, reconcileDrainTimeout)
+	err := r.store.Drain(drainCtx)
+	cancel()
+	if err != nil {
+		slog.WarnContext(ctx, "⚠️ Escrituras pendientes, se omite la reconciliación", "error", err)
+		return
+	}
+
+	// La copia se toma antes de consultar SRS: una sesión abierta después
+	// no se compara con una lista de clientes anterior a ella.
+	tracked := r.tracker.Active()
+	clients, err := r.srsClient.ListClients()
+	if err != nil {
+		slog.WarnContext(ctx, "⚠️ Error obteniendo clientes de SRS, se omite la reconciliación", "error", err)
+		return
+	}
+	open, err := r.store.Sessions.ListOpen(ctx, r.serverID)
+	if err != nil {
+		slog.WarnContext(ctx, "⚠️ Error obteniendo sesiones abiertas, se omite la reconciliación", "error", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	live := make(map[string]SRSClientInfo, len(clients))
+	for _, c := range clients {
+		live[c.ID] = c
+		r.tracker.sample(c, now)
+	}
+
+	var closed, adopted, reopened int
+	inMemory := make(map[string]bool, len(tracked))
+	for _, s := range tracked {
+		inMemory[s.ClientID] = true
+		if _, ok := live[s.ClientID]; ok || now.Sub(s.ConnectedAt) < reconcileGrace {
+			continue
+		}
+		ok, err := r.tracker.closeOrphan(ctx, s.ClientID)
+		if err != nil {
+			slog.ErrorContext(ctx, "❌ Error cerrando sesión huérfana", logging.KeyClientID, s.ClientID, "error", err)
+		} else if ok {
+			closed++
+		}
+	}
+
+	inDatabase := make(map[string]bool, len(open))
+	for _, row := range open {
+		if row.ClientID == "" || inMemory[row.ClientID] {
+			continue
+		}
+		inDatabase[row.ClientID] = true
+		if c, ok := live[row.ClientID]; ok {
+			// Sesión de una ejecución anterior con el cliente aún conectado
+			s := sessionFromRow(row, now)
+			s.observe(c)
+			if r.tracker.adopt(s) {
+				adopted++
+			}
+			continue
+		}
+		if now.Sub(row.ConnectedAt) < reconcileGrace {
+			continue
+		}
+		if err := r.closeRow(ctx, row, now); err != nil {
+			slog.ErrorContext(ctx, "❌ Error cerrando sesión huérfana", logging.KeyClientID, row.ClientID, "error", err)
+		} else {
+			closed++
+		}
+	}
+
+	for id, c := range live {
+		if inMemory[id] || inDatabase[id] || c.Alive < reconcileGrace.Seconds() {
+			continue
+		}
+		if err := r.reopen(ctx, c, now); err != nil {
+			slog.ErrorContext(ctx, "❌ Error guardando sesión reconciliada", logging.KeyClientID, id, "error", err)
+		} else {
+			reopened++
+		}
+	}
+
+	if closed+adopted+reopened > 0 {
+		slog.InfoContext(ctx, "🧹 Sesiones reconciliadas", "closed", closed, "adopted", adopted, "reopened", reopened)
+	}
+}
+
+// closeRow cierra una sesión que no está en memoria: la duración llega hasta
+// ahora porque no se sabe cuándo se fue el cliente. Si otra escritura la
+// cerró mientras tanto no se toca.
+func (r *SessionReconciler) closeRow(ctx context.Context, row models.OpenSession, now time.Time) error {
+	return r.store.Sessions.CloseOrphan(ctx, map[string]interface{}{
+		"disconnected_at":   now,
+		"duration_seconds":  int(now.Sub(row.ConnectedAt).Seconds()),
+		"disconnect_reason": DisconnectReasonOrphaned,
+		"reconciled":        true,
+	}, map[string]string{"id": string(row.ID)})
+}
+
+// reopen guarda la sesión de un cliente de SRS sin sesión abierta
+func (r *SessionReconciler) reopen(ctx context.Context, c SRSClientInfo, now time.Time) error {
+	app, stream := c.AppStream()
+	s := Session{
+		ClientID:    c.ID,
+		ClientIP:    c.IP,
+		ClientType:  ClientTypePlay,
+		StreamName:  stream,
+		App:         app,
+		ConnectedAt: now.Add(-time.Duration(c.Alive * float64(time.Second))),
+		LastSeen:    now,
+	}
+	if c.Publish {
+		s.ClientType = ClientTypePublish
+	}
+	s.observe(c)
+	if tenant, err := r.tenants.ForStream(ctx, stream); err == nil {
+		s.ChannelID = tenant.ChannelID
+		s.OrganizationID = tenant.OrganizationID
+	}
+	return r.tracker.open(ctx, s, true)
+}
+
+func sessionFromRow(row models.OpenSession, now time.Time) Session {
+	return Session{
+		ClientID:    row.ClientID,
+		ClientIP:    row.ClientIP,
+		ClientType:  row.ClientType,
+		StreamID:    row.StreamID,
+		StreamName:  row.StreamName,
+		App:         row.App,
+		ConnectedAt: row.ConnectedAt,
+		LastSeen:    now,
+	}
+}
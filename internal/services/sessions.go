@@ -0,0 +1,278 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"srs-backend/internal/logging"
+	"srs-backend/internal/storage"
+)
+
+// Motivos de cierre guardados en disconnect_reason
+const (
+	DisconnectReasonStop     = "stop"
+	DisconnectReasonShutdown = "shutdown"
+	// El cliente ya no estaba en SRS y no llegó su on_stop
+	DisconnectReasonOrphaned = "orphaned"
+)
+
+// Tipos de sesión guardados en client_type
+const (
+	ClientTypePlay    = "play"
+	ClientTypePublish = "publish"
+)
+
+// Session es una conexión de cliente a este servidor
+type Session struct {
+	ClientID       string
+	ClientIP       string
+	ClientType     string
+	StreamID       string
+	StreamName     string
+	App            string
+	ChannelID      string
+	OrganizationID string
+	// ReferrerDomain es el dominio de la página que embebe el player
+	ReferrerDomain string
+	ConnectedAt    time.Time
+	// LastSeen es la última vez que el reconciliador vio el cliente en SRS
+	LastSeen time.Time
+	// Contadores de SRS desde la conexión, según la última muestra
+	SendBytes int64
+	RecvBytes int64
+}
+
+// observe toma los contadores de una muestra de SRS. Son acumulados: solo
+// crecen mientras el cliente sigue conectado.
+func (s *Session) observe(c SRSClientInfo) {
+	s.SendBytes = max(s.SendBytes, c.SendBytes)
+	s.RecvBytes = max(s.RecvBytes, c.RecvBytes)
+}
+
+// closeValues calcula la duración y, si hubo alguna muestra, el tráfico
+// total y el bitrate medio de la sesión.
+func (s *Session) closeValues(disconnectedAt time.Time, reason string) map[string]interface{} {
+	duration := disconnectedAt.Sub(s.ConnectedAt)
+	values := map[string]interface{}{
+		"disconnected_at":   disconnectedAt,
+		"duration_seconds":  int(duration.Seconds()),
+		"disconnect_reason": reason,
+	}
+	if s.SendBytes == 0 && s.RecvBytes == 0 {
+		return values
+	}
+
+	values["total_send_mb"] = bytesToMB(s.SendBytes)
+	values["total_recv_mb"] = bytesToMB(s.RecvBytes)
+	if seconds := duration.Seconds(); seconds >= 1 {
+		values["avg_send_kbps"] = int(float64(s.SendBytes) * 8 / 1000 / seconds)
+		values["avg_recv_kbps"] = int(float64(s.RecvBytes) * 8 / 1000 / seconds)
+	}
+	return values
+}
+
+func bytesToMB(b int64) float64 {
+	return math.Round(float64(b)/(1024*1024)*100) / 100
+}
+
+// SessionTracker guarda las sesiones en server_ingest_client_connections y
+// recuerda las abiertas por client_id para calcular duración y tráfico al
+// cerrarlas.
+type SessionTracker struct {
+	store     *storage.Store
+	srsClient *SRSClient
+	geoip     *GeoIPService
+	serverID  string
+	serverIP  string
+
+	mu     sync.Mutex
+	active map[string]*Session
+}
+
+func NewSessionTracker(store *storage.Store, geoip *GeoIPService, serverID, serverIP string) *SessionTracker {
+	return &SessionTracker{
+		store:     store,
+		srsClient: NewSRSClient(),
+		geoip:     geoip,
+		serverID:  serverID,
+		serverIP:  serverIP,
+		active:    make(map[string]*Session),
+	}
+}
+
+// Open inserta la sesión y la recuerda si tiene client_id
+func (t *SessionTracker) Open(ctx context.Context, s Session) error {
+	return t.open(ctx, s, false)
+}
+
+func (t *SessionTracker) open(ctx context.Context, s Session, reconciled bool) error {
+	insertData := map[string]interface{}{
+		"server_id":    t.serverID,
+		"server_ip":    t.serverIP,
+		"client_id":    s.ClientID,
+		"client_ip":    s.ClientIP,
+		"client_type":  s.ClientType,
+		"stream_id":    s.StreamID,
+		"stream_name":  s.StreamName,
+		"app":          s.App,
+		"connected_at": s.ConnectedAt,
+		"reconciled":   reconciled,
+	}
+	// Organización y canal para las cuotas de viewers concurrentes
+	if s.ChannelID != "" {
+		insertData["channel_id"] = s.ChannelID
+	}
+	if s.OrganizationID != "" {
+		insertData["organization_id"] = s.OrganizationID
+	}
+	if s.ReferrerDomain != "" {
+		insertData["referrer_domain"] = s.ReferrerDomain
+	}
+	// País, región, ciudad y red del cliente según las bases GeoIP locales
+	t.geoip.Lookup(s.ClientIP).addColumns(insertData)
+
+	if err := t.store.Sessions.Open(ctx, insertData); err != nil {
+		return err
+	}
+	if s.ClientID != "" {
+		t.mu.Lock()
+		t.active[s.ClientID] = &s
+		t.mu.Unlock()
+	}
+	return nil
+}
+
+// Close cierra la sesión del cliente. Sin client_id se busca por app, stream
+// e IP. Los contadores finales se piden a SRS si aún tiene el cliente; si no,
+// quedan los de la última muestra.
+func (t *SessionTracker) Close(ctx context.Context, s Session, reason string) error {
+	var final *SRSClientInfo
+	if s.ClientID != "" {
+		var err error
+		if final, err = t.srsClient.GetClient(s.ClientID); err != nil {
+			slog.WarnContext(ctx, "⚠️ Error obteniendo contadores finales del cliente", "error", err)
+		}
+	}
+
+	disconnectedAt := time.Now().UTC()
+
+	var tracked *Session
+	if s.ClientID != "" {
+		t.mu.Lock()
+		tracked = t.active[s.ClientID]
+		delete(t.active, s.ClientID)
+		t.mu.Unlock()
+	}
+
+	var updateData map[string]interface{}
+	if tracked != nil {
+		if final != nil {
+			tracked.observe(*final)
+		}
+		updateData = tracked.closeValues(disconnectedAt, reason)
+	} else {
+		updateData = map[string]interface{}{
+			"disconnected_at":   disconnectedAt,
+			"duration_seconds":  0,
+			"disconnect_reason": reason,
+		}
+	}
+
+	filters := map[string]string{
+		"server_id":   t.serverID,
+		"app":         s.App,
+		"stream_name": s.StreamName,
+		"client_ip":   s.ClientIP,
+		"client_type": s.ClientType,
+	}
+	if s.ClientID != "" {
+		filters["client_id"] = s.ClientID
+	} else if tracked != nil {
+		// Supabase Eq requiere string para timestamp
+		filters["connected_at"] = tracked.ConnectedAt.Format(time.RFC3339)
+	}
+
+	return t.store.Sessions.Close(ctx, updateData, filters)
+}
+
+// CloseAll cierra las sesiones abiertas en memoria con el motivo indicado.
+// Al apagar el backend SRS no enviará sus on_stop.
+func (t *SessionTracker) CloseAll(ctx context.Context, reason string) int {
+	t.mu.Lock()
+	sessions := t.active
+	t.active = make(map[string]*Session)
+	t.mu.Unlock()
+
+	disconnectedAt := time.Now().UTC()
+	for clientID, s := range sessions {
+		if err := t.store.Sessions.Close(ctx, s.closeValues(disconnectedAt, reason), t.clientFilters(s)); err != nil {
+			slog.ErrorContext(ctx, "❌ Error cerrando sesion server_ingest_client_connections", logging.KeyClientID, clientID, "error", err)
+		}
+	}
+	return len(sessions)
+}
+
+// Active devuelve una copia de las sesiones abiertas en memoria
+func (t *SessionTracker) Active() []Session {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sessions := make([]Session, 0, len(t.active))
+	for _, s := range t.active {
+		sessions = append(sessions, *s)
+	}
+	return sessions
+}
+
+// adopt recuerda una sesión ya guardada en la base de datos
+func (t *SessionTracker) adopt(s Session) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.active[s.ClientID]; ok {
+		return false
+	}
+	t.active[s.ClientID] = &s
+	return true
+}
+
+// sample registra que el cliente sigue en SRS y sus contadores
+func (t *SessionTracker) sample(c SRSClientInfo, seen time.Time) {
+	t.mu.Lock()
+	if s, ok := t.active[c.ID]; ok {
+		s.LastSeen = seen
+		s.observe(c)
+	}
+	t.mu.Unlock()
+}
+
+// closeOrphan cierra una sesión cuyo cliente ya no está en SRS. El cierre se
+// fecha en la última vez que se vio el cliente. Devuelve false si un on_stop
+// la cerró antes.
+func (t *SessionTracker) closeOrphan(ctx context.Context, clientID string) (bool, error) {
+	t.mu.Lock()
+	s, ok := t.active[clientID]
+	delete(t.active, clientID)
+	t.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+
+	disconnectedAt := s.LastSeen
+	if disconnectedAt.IsZero() {
+		disconnectedAt = time.Now().UTC()
+	}
+	updateData := s.closeValues(disconnectedAt, DisconnectReasonOrphaned)
+	updateData["reconciled"] = true
+	return true, t.store.Sessions.CloseOrphan(ctx, updateData, t.clientFilters(s))
+}
+
+func (t *SessionTracker) clientFilters(s *Session) map[string]string {
+	return map[string]string{
+		"server_id":   t.serverID,
+		"client_id":   s.ClientID,
+		"client_type": s.ClientType,
+	}
+}
@@ -2,7 +2,9 @@ package services
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
 )
 
 type SRSClient struct {
@@ -16,7 +18,7 @@ func NewSRSClient() *SRSClient {
 }
 
 func (c *SRSClient) GetStreams() (map[string]interface{}, error) {
-	resp, err := http.Get(c.baseURL + "/streams/")
+	resp, err := http.Get(c.listURL("streams"))
 	if err != nil {
 		return nil, err
 	}
@@ -31,7 +33,7 @@ func (c *SRSClient) GetStreams() (map[string]interface{}, error) {
 }
 
 func (c *SRSClient) GetClients() (map[string]interface{}, error) {
-	resp, err := http.Get(c.baseURL + "/clients/")
+	resp, err := http.Get(c.listURL("clients"))
 	if err != nil {
 		return nil, err
 	}
@@ -58,4 +60,144 @@ func (c *SRSClient) GetRusages() (map[string]interface{}, error) {
 	}
 
 	return result, nil
-}
\ No newline at end of file
+}
+
+// Máximo de clientes o streams por consulta; SRS devuelve 10 si no se
+// indica count
+const srsClientsPageSize = 10000
+
+// listURL es la URL de /streams/ o /clients/ con todos los elementos
+func (c *SRSClient) listURL(resource string) string {
+	return fmt.Sprintf("%s/%s/?start=0&count=%d", c.baseURL, resource, srsClientsPageSize)
+}
+
+// SRSClientInfo es un cliente conectado a SRS
+type SRSClientInfo struct {
+	ID      string `json:"id"`
+	IP      string `json:"ip"`
+	Type    string `json:"type"`
+	Publish bool   `json:"publish"`
+	// URL es /app/stream, o vhost/app/stream fuera del vhost por defecto
+	URL string `json:"url"`
+	// Alive son los segundos desde la conexión
+	Alive     float64 `json:"alive"`
+	SendBytes int64   `json:"send_bytes"`
+	RecvBytes int64   `json:"recv_bytes"`
+}
+
+// AppStream separa app y stream de la URL del cliente
+func (c SRSClientInfo) AppStream() (string, string) {
+	path := c.URL
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 2 {
+		return "", ""
+	}
+	return parts[len(parts)-2], parts[len(parts)-1]
+}
+
+// ListClients devuelve todos los clientes conectados
+func (c *SRSClient) ListClients() ([]SRSClientInfo, error) {
+	resp, err := http.Get(c.listURL("clients"))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Code    int             `json:"code"`
+		Clients []SRSClientInfo `json:"clients"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if result.Code != 0 {
+		return nil, fmt.Errorf("SRS respondió code=%d al listar clientes", result.Code)
+	}
+	return result.Clients, nil
+}
+
+// GetClient devuelve el cliente, o nil si SRS ya no lo tiene
+func (c *SRSClient) GetClient(clientID string) (*SRSClientInfo, error) {
+	resp, err := http.Get(c.baseURL + "/clients/" + clientID)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Code   int            `json:"code"`
+		Client *SRSClientInfo `json:"client"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if result.Code != 0 {
+		return nil, nil
+	}
+	return result.Client, nil
+}
+
+// FindPublishers devuelve los client_id que publican streamName en app, o
+// en cualquier app si app es "". SRS identifica el vhost por id y no por
+// nombre, así que no se filtra por vhost.
+func (c *SRSClient) FindPublishers(app, streamName string) ([]string, error) {
+	resp, err := http.Get(c.listURL("streams"))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Code    int `json:"code"`
+		Streams []struct {
+			Name    string `json:"name"`
+			App     string `json:"app"`
+			Publish struct {
+				Active bool   `json:"active"`
+				CID    string `json:"cid"`
+			} `json:"publish"`
+		} `json:"streams"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if result.Code != 0 {
+		return nil, fmt.Errorf("SRS respondió code=%d al listar streams", result.Code)
+	}
+
+	var clientIDs []string
+	for _, s := range result.Streams {
+		if s.Name == streamName && (app == "" || s.App == app) && s.Publish.Active {
+			clientIDs = append(clientIDs, s.Publish.CID)
+		}
+	}
+	return clientIDs, nil
+}
+
+// KickClient desconecta un cliente de SRS (DELETE /api/v1/clients/{id})
+func (c *SRSClient) KickClient(clientID string) error {
+	req, err := http.NewRequest(http.MethodDelete, c.baseURL+"/clients/"+clientID, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Code int `json:"code"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if result.Code != 0 {
+		return fmt.Errorf("SRS respondió code=%d al expulsar %s", result.Code, clientID)
+	}
+	return nil
+}
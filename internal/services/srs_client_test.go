@@ -0,0 +1,61 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestFindPublishers(t *testing.T) {
+	srs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Sin count SRS devuelve solo 10 streams
+		if r.URL.Query().Get("count") == "" {
+			t.Errorf("consulta sin count: %s", r.URL)
+		}
+		w.Write([]byte(`{"code":0,"streams":[
+			{"name":"key1","app":"live","publish":{"active":true,"cid":"c1"}},
+			{"name":"key1","app":"backup","publish":{"active":true,"cid":"c2"}},
+			{"name":"key1","app":"vod","publish":{"active":false,"cid":""}},
+			{"name":"key2","app":"live","publish":{"active":true,"cid":"c3"}}
+		]}`))
+	}))
+	defer srs.Close()
+	client := &SRSClient{baseURL: srs.URL}
+
+	tests := []struct {
+		app, stream string
+		want        []string
+	}{
+		{"live", "key1", []string{"c1"}},
+		{"backup", "key1", []string{"c2"}},
+		{"", "key1", []string{"c1", "c2"}},
+		{"vod", "key1", nil},
+		{"live", "key3", nil},
+	}
+	for _, tt := range tests {
+		got, err := client.FindPublishers(tt.app, tt.stream)
+		if err != nil {
+			t.Fatalf("FindPublishers(%q, %q): %v", tt.app, tt.stream, err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("FindPublishers(%q, %q) = %v, want %v", tt.app, tt.stream, got, tt.want)
+		}
+	}
+}
+
+func TestSRSListURL(t *testing.T) {
+	client := &SRSClient{baseURL: "http://srs:1985/api/v1"}
+	tests := []struct {
+		resource string
+		want     string
+	}{
+		{"streams", "http://srs:1985/api/v1/streams/?start=0&count=10000"},
+		{"clients", "http://srs:1985/api/v1/clients/?start=0&count=10000"},
+	}
+	for _, tt := range tests {
+		if got := client.listURL(tt.resource); got != tt.want {
+			t.Errorf("listURL(%q) = %q, want %q", tt.resource, got, tt.want)
+		}
+	}
+}
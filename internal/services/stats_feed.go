@@ -0,0 +1,167 @@
+package services
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"srs-backend/internal/models"
+)
+
+// Mensajes del feed de estadísticas
+const (
+	StatsMessageSnapshot = "snapshot"
+	StatsMessageDiff     = "diff"
+)
+
+// StatsFilter limita los streams que recibe una conexión; vacío recibe todos.
+// Streams acepta el id de SRS o el nombre del stream.
+type StatsFilter struct {
+	Streams  []string `json:"streams"`
+	Channels []string `json:"channels"`
+}
+
+func (f StatsFilter) Match(s models.LiveStream) bool {
+	if len(f.Streams) == 0 && len(f.Channels) == 0 {
+		return true
+	}
+	return slices.Contains(f.Streams, s.ID) || slices.Contains(f.Streams, s.Name) ||
+		(s.ChannelID != "" && slices.Contains(f.Channels, s.ChannelID))
+}
+
+// Apply devuelve el snapshot solo con los streams del filtro. Los totales del
+// servidor no se filtran.
+func (f StatsFilter) Apply(snapshot models.LiveSnapshot) models.LiveSnapshot {
+	filtered := models.LiveSnapshot{Time: snapshot.Time, Server: snapshot.Server, Streams: []models.LiveStream{}}
+	for _, s := range snapshot.Streams {
+		if f.Match(s) {
+			filtered.Streams = append(filtered.Streams, s)
+		}
+	}
+	return filtered
+}
+
+// StatsMessage es lo que recibe el cliente. Un snapshot trae el estado
+// completo; un diff solo el servidor si cambió, los streams nuevos o con
+// cambios y los ids de los que ya no están.
+type StatsMessage struct {
+	Type    string              `json:"type"`
+	Time    time.Time           `json:"time"`
+	Server  *models.LiveServer  `json:"server,omitempty"`
+	Streams []models.LiveStream `json:"streams,omitempty"`
+	Removed []string            `json:"removed,omitempty"`
+}
+
+func SnapshotMessage(snapshot models.LiveSnapshot) StatsMessage {
+	return StatsMessage{
+		Type:    StatsMessageSnapshot,
+		Time:    snapshot.Time,
+		Server:  &snapshot.Server,
+		Streams: snapshot.Streams,
+	}
+}
+
+// DiffStats compara dos snapshots ya filtrados; ok es false si no hay cambios
+func DiffStats(prev, next models.LiveSnapshot) (msg StatsMessage, ok bool) {
+	msg = StatsMessage{Type: StatsMessageDiff, Time: next.Time}
+	if next.Server != prev.Server {
+		msg.Server = &next.Server
+	}
+	previous := make(map[string]models.LiveStream, len(prev.Streams))
+	for _, s := range prev.Streams {
+		previous[s.ID] = s
+	}
+	for _, s := range next.Streams {
+		if old, found := previous[s.ID]; !found || old != s {
+			msg.Streams = append(msg.Streams, s)
+		}
+		delete(previous, s.ID)
+	}
+	for id := range previous {
+		msg.Removed = append(msg.Removed, id)
+	}
+	slices.Sort(msg.Removed)
+	return msg, msg.Server != nil || len(msg.Streams) > 0 || len(msg.Removed) > 0
+}
+
+// StatsFeed guarda el último snapshot del recolector y avisa a las conexiones
+// abiertas. Cada conexión lee el último al recibir el aviso: si no da abasto
+// se salta snapshots intermedios y el diff cubre el salto.
+type StatsFeed struct {
+	mu          sync.Mutex
+	latest      *models.LiveSnapshot
+	subscribers map[chan struct{}]struct{}
+	closed      bool
+}
+
+func NewStatsFeed() *StatsFeed {
+	return &StatsFeed{subscribers: make(map[chan struct{}]struct{})}
+}
+
+// Publish guarda el snapshot del ciclo; un feed nil no hace nada
+func (f *StatsFeed) Publish(snapshot models.LiveSnapshot) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.latest = &snapshot
+	for ch := range f.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Latest devuelve el último snapshot; ok es false antes del primer ciclo
+func (f *StatsFeed) Latest() (snapshot models.LiveSnapshot, ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.latest == nil {
+		return snapshot, false
+	}
+	return *f.latest, true
+}
+
+// Subscribe devuelve un canal que recibe un aviso por snapshot nuevo y se
+// cierra con Close
+func (f *StatsFeed) Subscribe() chan struct{} {
+	ch := make(chan struct{}, 1)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		close(ch)
+		return ch
+	}
+	f.subscribers[ch] = struct{}{}
+	return ch
+}
+
+func (f *StatsFeed) Unsubscribe(ch chan struct{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.subscribers[ch]; ok {
+		delete(f.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Clients devuelve cuántas conexiones están abiertas
+func (f *StatsFeed) Clients() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.subscribers)
+}
+
+// Close avisa a las conexiones para que se cierren al apagar: server.Shutdown
+// no espera a las conexiones WebSocket
+func (f *StatsFeed) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	for ch := range f.subscribers {
+		delete(f.subscribers, ch)
+		close(ch)
+	}
+}
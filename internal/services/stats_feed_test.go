@@ -0,0 +1,93 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"srs-backend/internal/models"
+)
+
+func TestDiffStats(t *testing.T) {
+	server := models.LiveServer{ServerID: "srv-test", CPU: 12.5, Connections: 10}
+	a := models.LiveStream{ID: "1", Name: "key-a", Clients: 5}
+	b := models.LiveStream{ID: "2", Name: "key-b", Clients: 3}
+	c := models.LiveStream{ID: "3", Name: "key-c", Clients: 1}
+	busier := a
+	busier.Clients = 6
+	hotter := server
+	hotter.CPU = 40
+
+	tests := []struct {
+		name        string
+		prev, next  []models.LiveStream
+		nextServer  models.LiveServer
+		wantOK      bool
+		wantServer  bool
+		wantStreams string
+		wantRemoved string
+	}{
+		{"sin cambios", []models.LiveStream{a, b}, []models.LiveStream{a, b}, server, false, false, "[]", "[]"},
+		{"orden distinto", []models.LiveStream{a, b}, []models.LiveStream{b, a}, server, false, false, "[]", "[]"},
+		{"stream nuevo", []models.LiveStream{a}, []models.LiveStream{a, c}, server, true, false, "[3]", "[]"},
+		{"stream cambiado", []models.LiveStream{a, b}, []models.LiveStream{busier, b}, server, true, false, "[1]", "[]"},
+		{"streams quitados", []models.LiveStream{c, a, b}, []models.LiveStream{a}, server, true, false, "[]", "[2 3]"},
+		{"solo el servidor", []models.LiveStream{a}, []models.LiveStream{a}, hotter, true, true, "[]", "[]"},
+		{"desde vacío", nil, []models.LiveStream{a}, server, true, false, "[1]", "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev := models.LiveSnapshot{Server: server, Streams: tt.prev}
+			next := models.LiveSnapshot{Server: tt.nextServer, Streams: tt.next}
+			msg, ok := DiffStats(prev, next)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if msg.Type != StatsMessageDiff {
+				t.Errorf("type %q, want %q", msg.Type, StatsMessageDiff)
+			}
+			if (msg.Server != nil) != tt.wantServer {
+				t.Errorf("server incluido = %v, want %v", msg.Server != nil, tt.wantServer)
+			}
+			ids := []string{}
+			for _, s := range msg.Streams {
+				ids = append(ids, s.ID)
+			}
+			if got := fmt.Sprint(ids); got != tt.wantStreams {
+				t.Errorf("streams %s, want %s", got, tt.wantStreams)
+			}
+			if got := fmt.Sprint(append([]string{}, msg.Removed...)); got != tt.wantRemoved {
+				t.Errorf("removed %s, want %s", got, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+func TestStatsFilter(t *testing.T) {
+	snapshot := models.LiveSnapshot{Streams: []models.LiveStream{
+		{ID: "1", Name: "key-a", ChannelID: "ch-a"},
+		{ID: "2", Name: "key-b", ChannelID: "ch-b"},
+		{ID: "3", Name: "key-c"},
+	}}
+	tests := []struct {
+		name   string
+		filter StatsFilter
+		want   string
+	}{
+		{"vacío", StatsFilter{}, "[1 2 3]"},
+		{"por id", StatsFilter{Streams: []string{"2"}}, "[2]"},
+		{"por nombre", StatsFilter{Streams: []string{"key-c"}}, "[3]"},
+		{"por canal", StatsFilter{Channels: []string{"ch-a"}}, "[1]"},
+		{"stream y canal", StatsFilter{Streams: []string{"3"}, Channels: []string{"ch-b"}}, "[2 3]"},
+		{"canal vacío no coincide", StatsFilter{Channels: []string{""}}, "[]"},
+		{"sin coincidencias", StatsFilter{Streams: []string{"9"}}, "[]"},
+	}
+	for _, tt := range tests {
+		ids := []string{}
+		for _, s := range tt.filter.Apply(snapshot).Streams {
+			ids = append(ids, s.ID)
+		}
+		if got := fmt.Sprint(ids); got != tt.want {
+			t.Errorf("%s: streams %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"srs-backend/internal/logging"
+	"srs-backend/internal/storage"
+)
+
+var (
+	ErrChannelNotFound = errors.New("canal no encontrado")
+	ErrKeyExists       = errors.New("el canal ya tiene clave, usar rotate")
+	ErrKeyNotFound     = errors.New("la clave no pertenece al canal")
+)
+
+// KeyRotation resume el resultado de generar, rotar o revocar una clave.
+type KeyRotation struct {
+	ChannelID      string     `json:"channel_id"`
+	StreamKey      string     `json:"stream_key"`
+	GraceExpiresAt *time.Time `json:"grace_expires_at,omitempty"`
+	RevokedKey     string     `json:"revoked_key,omitempty"`
+	KickedClients  []string   `json:"kicked_clients,omitempty"`
+}
+
+// KeyStatus describe las claves vigentes de un canal sin exponerlas completas.
+type KeyStatus struct {
+	ChannelID string          `json:"channel_id"`
+	HasKey    bool            `json:"has_key"`
+	StreamKey string          `json:"stream_key"`
+	GraceKeys []GraceKeyState `json:"grace_keys"`
+}
+
+type GraceKeyState struct {
+	StreamKey string    `json:"stream_key"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// StreamKeyService administra las claves de channels_channel.stream_id y las
+// claves anteriores que siguen publicando durante la ventana de gracia.
+type StreamKeyService struct {
+	store        *storage.Store
+	srsClient    *SRSClient
+	serverID     string
+	serverIP     string
+	backupSuffix string
+}
+
+func NewStreamKeyService(store *storage.Store, serverID, serverIP, backupSuffix string) *StreamKeyService {
+	return &StreamKeyService{
+		store:        store,
+		srsClient:    NewSRSClient(),
+		serverID:     serverID,
+		serverIP:     serverIP,
+		backupSuffix: backupSuffix,
+	}
+}
+
+// SplitBackupKey indica si la clave es de respaldo ("<clave>_backup") y
+// devuelve la clave primaria correspondiente.
+func (s *StreamKeyService) SplitBackupKey(streamKey string) (string, bool) {
+	if s.backupSuffix == "" || !strings.HasSuffix(streamKey, s.backupSuffix) || len(streamKey) == len(s.backupSuffix) {
+		return streamKey, false
+	}
+	return strings.TrimSuffix(streamKey, s.backupSuffix), true
+}
+
+// Resolve devuelve el canal de una clave, aceptando claves en gracia y claves
+// de respaldo.
+func (s *StreamKeyService) Resolve(ctx context.Context, streamKey string) (string, error) {
+	if primaryKey, ok := s.SplitBackupKey(streamKey); ok {
+		return s.resolvePrimary(ctx, primaryKey)
+	}
+	return s.resolvePrimary(ctx, streamKey)
+}
+
+func (s *StreamKeyService) resolvePrimary(ctx context.Context, streamKey string) (string, error) {
+	channelID, err := s.store.Channels.FindByStreamKey(ctx, streamKey)
+	if err != nil || channelID != "" {
+		return channelID, err
+	}
+
+	grace, err := s.store.Channels.FindGraceKey(ctx, streamKey)
+	if err != nil || grace == nil {
+		return "", err
+	}
+	if time.Now().After(grace.ExpiresAt) {
+		return "", nil
+	}
+
+	slog.InfoContext(ctx, "🔑 Clave en periodo de gracia usada", "channel_id", grace.ChannelID,
+		"expires_at", grace.ExpiresAt.Format(time.RFC3339))
+	return grace.ChannelID, nil
+}
+
+// Status devuelve las claves vigentes de un canal enmascaradas.
+func (s *StreamKeyService) Status(ctx context.Context, channelID string) (*KeyStatus, error) {
+	current, found, err := s.store.Channels.GetStreamKey(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrChannelNotFound
+	}
+
+	graceKeys, err := s.store.Channels.ListGraceKeys(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &KeyStatus{
+		ChannelID: channelID,
+		HasKey:    current != "",
+		StreamKey: MaskStreamKey(current),
+		GraceKeys: []GraceKeyState{},
+	}
+	now := time.Now()
+	for _, g := range graceKeys {
+		if g.ExpiresAt.After(now) {
+			status.GraceKeys = append(status.GraceKeys, GraceKeyState{
+				StreamKey: MaskStreamKey(g.StreamKey),
+				ExpiresAt: g.ExpiresAt,
+			})
+		}
+	}
+
+	return status, nil
+}
+
+// Generate asigna la primera clave a un canal que no tiene ninguna.
+func (s *StreamKeyService) Generate(ctx context.Context, channelID string) (*KeyRotation, error) {
+	current, found, err := s.store.Channels.GetStreamKey(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrChannelNotFound
+	}
+	if current != "" {
+		return nil, ErrKeyExists
+	}
+
+	key, err := GenerateStreamKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.Channels.UpdateStreamKey(ctx, channelID, key); err != nil {
+		return nil, err
+	}
+
+	s.recordEvent(ctx, "stream_key_generated", channelID, "Clave de transmisión generada", nil)
+	return &KeyRotation{ChannelID: channelID, StreamKey: key}, nil
+}
+
+// Rotate reemplaza la clave actual. La clave anterior sigue publicando hasta
+// que vence la ventana de gracia; con grace=0 deja de ser válida al instante.
+func (s *StreamKeyService) Rotate(ctx context.Context, channelID string, grace time.Duration) (*KeyRotation, error) {
+	current, found, err := s.store.Channels.GetStreamKey(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrChannelNotFound
+	}
+
+	key, err := GenerateStreamKey()
+	if err != nil {
+		return nil, err
+	}
+
+	rotation := &KeyRotation{ChannelID: channelID, StreamKey: key}
+	if current != "" && grace > 0 {
+		expiresAt := time.Now().UTC().Add(grace)
+		if err := s.store.Channels.InsertGraceKey(ctx, channelID, current, expiresAt); err != nil {
+			return nil, err
+		}
+		rotation.GraceExpiresAt = &expiresAt
+	}
+
+	if err := s.store.Channels.UpdateStreamKey(ctx, channelID, key); err != nil {
+		return nil, err
+	}
+
+	s.recordEvent(ctx, "stream_key_rotated", channelID, "Clave de transmisión rotada", map[string]interface{}{
+		"grace_seconds": int(grace.Seconds()),
+	})
+	return rotation, nil
+}
+
+// Revoke invalida una clave del canal y expulsa al publicador que la esté
+// usando. Sin key, o con la clave actual, el canal recibe una clave nueva y se
+// descartan también las claves en gracia.
+func (s *StreamKeyService) Revoke(ctx context.Context, channelID, key string) (*KeyRotation, error) {
+	current, found, err := s.store.Channels.GetStreamKey(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrChannelNotFound
+	}
+
+	graceKeys, err := s.store.Channels.ListGraceKeys(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	rotation := &KeyRotation{ChannelID: channelID}
+	var revoked []string
+
+	if key == "" || key == current {
+		newKey, err := GenerateStreamKey()
+		if err != nil {
+			return nil, err
+		}
+		if err := s.store.Channels.UpdateStreamKey(ctx, channelID, newKey); err != nil {
+			return nil, err
+		}
+		if err := s.store.Channels.DeleteGraceKeys(ctx, channelID, ""); err != nil {
+			slog.WarnContext(ctx, "⚠️ Error eliminando claves en gracia", "channel_id", channelID, "error", err)
+		}
+
+		rotation.StreamKey = newKey
+		if current != "" {
+			revoked = append(revoked, current)
+		}
+		for _, g := range graceKeys {
+			revoked = append(revoked, g.StreamKey)
+		}
+	} else {
+		isGrace := false
+		for _, g := range graceKeys {
+			if g.StreamKey == key {
+				isGrace = true
+				break
+			}
+		}
+		if !isGrace {
+			return nil, ErrKeyNotFound
+		}
+		if err := s.store.Channels.DeleteGraceKeys(ctx, channelID, key); err != nil {
+			return nil, err
+		}
+		revoked = append(revoked, key)
+	}
+
+	rotation.RevokedKey = MaskStreamKey(key)
+	if key == "" {
+		rotation.RevokedKey = MaskStreamKey(current)
+	}
+
+	for _, k := range revoked {
+		rotation.KickedClients = append(rotation.KickedClients, s.kickPublishers(k)...)
+		if s.backupSuffix != "" {
+			rotation.KickedClients = append(rotation.KickedClients, s.kickPublishers(k+s.backupSuffix)...)
+		}
+	}
+
+	s.recordEvent(ctx, "stream_key_revoked", channelID, "Clave de transmisión revocada", map[string]interface{}{
+		"revoked_keys":   len(revoked),
+		"kicked_clients": rotation.KickedClients,
+	})
+	return rotation, nil
+}
+
+// kickPublishers expulsa de SRS a quien publique con la clave revocada, en
+// cualquier app
+func (s *StreamKeyService) kickPublishers(streamKey string) []string {
+	clientIDs, err := s.srsClient.FindPublishers("", streamKey)
+	if err != nil {
+		slog.Warn("⚠️ Error buscando publicador en SRS", logging.KeyStreamHash, logging.StreamHash(streamKey), "error", err)
+		return nil
+	}
+
+	var kicked []string
+	for _, clientID := range clientIDs {
+		if err := s.srsClient.KickClient(clientID); err != nil {
+			slog.Error("❌ Error expulsando publicador", logging.KeyStreamHash, logging.StreamHash(streamKey), logging.KeyClientID, clientID, "error", err)
+			continue
+		}
+		slog.Info("👢 Publicador expulsado por clave revocada", logging.KeyStreamHash, logging.StreamHash(streamKey), logging.KeyClientID, clientID)
+		kicked = append(kicked, clientID)
+	}
+	return kicked
+}
+
+func (s *StreamKeyService) recordEvent(ctx context.Context, eventType, channelID, message string, extra map[string]interface{}) {
+	metadata := map[string]interface{}{
+		"server_id":  s.serverID,
+		"channel_id": channelID,
+	}
+	for k, v := range extra {
+		metadata[k] = v
+	}
+	s.store.Events.Record(ctx, s.serverID, s.serverIP, eventType, "info",
+		fmt.Sprintf("%s para canal %s", message, channelID), metadata)
+}
+
+// GenerateStreamKey crea una clave de 128 bits con crypto/rand
+func GenerateStreamKey() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// MaskStreamKey deja visibles solo los primeros 4 caracteres
+func MaskStreamKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	if len(key) <= 4 {
+		return "****"
+	}
+	return key[:4] + "****"
+}
@@ -0,0 +1,404 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"srs-backend/internal/models"
+	"srs-backend/internal/storage"
+)
+
+// Tenant relaciona un canal con su organización y plan.
+type Tenant struct {
+	ChannelID      string
+	OrganizationID string
+	Plan           *models.Plan
+}
+
+type cachedTenant struct {
+	tenant    Tenant
+	expiresAt time.Time
+}
+
+type monthlyUsage struct {
+	month          string
+	loaded         bool
+	dirty          bool
+	streamSeconds  int64
+	bandwidthBytes int64
+}
+
+// TenantService aplica las cuotas de cada organización: streams y viewers
+// concurrentes en los hooks, horas y ancho de banda mensual en el collector.
+// Ante errores de base de datos las cuotas no bloquean (fail-open).
+type TenantService struct {
+	store    *storage.Store
+	keys     *StreamKeyService
+	serverID string
+	serverIP string
+	cacheTTL time.Duration
+
+	mu       sync.Mutex
+	channels map[string]cachedTenant // channel_id → tenant
+	streams  map[string]cachedTenant // clave → tenant
+	usage    map[string]*monthlyUsage
+	// último aviso emitido por organización/mes/métrica, y por cuota concurrente
+	warned       map[string]int
+	lastRejectAt map[string]time.Time
+	lastPrune    time.Time
+}
+
+func NewTenantService(store *storage.Store, keys *StreamKeyService, serverID, serverIP string, cacheTTL time.Duration) *TenantService {
+	return &TenantService{
+		store:        store,
+		keys:         keys,
+		serverID:     serverID,
+		serverIP:     serverIP,
+		cacheTTL:     cacheTTL,
+		channels:     make(map[string]cachedTenant),
+		streams:      make(map[string]cachedTenant),
+		usage:        make(map[string]*monthlyUsage),
+		warned:       make(map[string]int),
+		lastRejectAt: make(map[string]time.Time),
+	}
+}
+
+// ForChannel devuelve la organización y el plan del canal (cacheado)
+func (t *TenantService) ForChannel(ctx context.Context, channelID string) (Tenant, error) {
+	now := time.Now()
+	t.mu.Lock()
+	if cached, ok := t.channels[channelID]; ok && cached.expiresAt.After(now) {
+		t.mu.Unlock()
+		return cached.tenant, nil
+	}
+	t.mu.Unlock()
+
+	tenant := Tenant{ChannelID: channelID}
+	orgID, err := t.store.Channels.GetOrganization(ctx, channelID)
+	if err != nil {
+		return tenant, err
+	}
+	tenant.OrganizationID = orgID
+	if orgID != "" {
+		plan, err := t.store.Tenants.GetPlan(ctx, orgID)
+		if err != nil {
+			return tenant, err
+		}
+		tenant.Plan = plan
+	}
+
+	t.mu.Lock()
+	t.pruneLocked(now)
+	t.channels[channelID] = cachedTenant{tenant: tenant, expiresAt: now.Add(t.cacheTTL)}
+	t.mu.Unlock()
+	return tenant, nil
+}
+
+// ForStream resuelve la clave de transmisión y devuelve su tenant (cacheado).
+// Una clave sin canal también se cachea, con ChannelID vacío: cada fallo
+// cuesta dos consultas (clave actual y claves en gracia).
+func (t *TenantService) ForStream(ctx context.Context, streamKey string) (Tenant, error) {
+	now := time.Now()
+	t.mu.Lock()
+	if cached, ok := t.streams[streamKey]; ok && cached.expiresAt.After(now) {
+		t.mu.Unlock()
+		return cached.tenant, nil
+	}
+	t.mu.Unlock()
+
+	channelID, err := t.keys.Resolve(ctx, streamKey)
+	if err != nil {
+		return Tenant{}, err
+	}
+	tenant := Tenant{}
+	if channelID != "" {
+		tenant, err = t.ForChannel(ctx, channelID)
+		if err != nil {
+			return tenant, err
+		}
+	}
+
+	t.mu.Lock()
+	t.pruneLocked(now)
+	t.streams[streamKey] = cachedTenant{tenant: tenant, expiresAt: now.Add(t.cacheTTL)}
+	t.mu.Unlock()
+	return tenant, nil
+}
+
+// AllowPublish comprueba la cuota de streams concurrentes de la organización
+func (t *TenantService) AllowPublish(ctx context.Context, channelID string) bool {
+	tenant, err := t.ForChannel(ctx, channelID)
+	if err != nil {
+		slog.WarnContext(ctx, "⚠️ Error obteniendo plan del canal, se permite publish", "channel_id", channelID, "error", err)
+		return true
+	}
+	if tenant.Plan == nil || tenant.Plan.MaxConcurrentStreams <= 0 {
+		return true
+	}
+
+	// El propio canal no cuenta: puede estar en vivo por un respaldo o takeover
+	live, err := t.store.Channels.CountLive(ctx, tenant.OrganizationID, channelID)
+	if err != nil {
+		slog.WarnContext(ctx, "⚠️ Error contando streams, se permite publish", "organization_id", tenant.OrganizationID, "error", err)
+		return true
+	}
+	if live < int64(tenant.Plan.MaxConcurrentStreams) {
+		return true
+	}
+
+	slog.WarnContext(ctx, "🚫 Publish rechazado: organización en su límite de streams", "organization_id", tenant.OrganizationID, "limit", tenant.Plan.MaxConcurrentStreams)
+	t.recordRejection(ctx, "quota_streams_exceeded", tenant, map[string]interface{}{
+		"channel_id":             channelID,
+		"live_streams":           live,
+		"max_concurrent_streams": tenant.Plan.MaxConcurrentStreams,
+	})
+	return false
+}
+
+// AllowPlay comprueba la cuota de viewers concurrentes. Devuelve también el
+// tenant para guardar la organización en la sesión.
+func (t *TenantService) AllowPlay(ctx context.Context, streamKey string) (Tenant, bool) {
+	tenant, err := t.ForStream(ctx, streamKey)
+	if err != nil {
+		slog.WarnContext(ctx, "⚠️ Error obteniendo plan del stream, se permite play", "error", err)
+		return tenant, true
+	}
+	if tenant.Plan == nil || tenant.Plan.MaxConcurrentViewers <= 0 {
+		return tenant, true
+	}
+
+	viewers, err := t.store.Sessions.CountOpenViewers(ctx, tenant.OrganizationID)
+	if err != nil {
+		slog.WarnContext(ctx, "⚠️ Error contando viewers, se permite play", "organization_id", tenant.OrganizationID, "error", err)
+		return tenant, true
+	}
+	if viewers < int64(tenant.Plan.MaxConcurrentViewers) {
+		return tenant, true
+	}
+
+	slog.WarnContext(ctx, "🚫 Play rechazado: organización en su límite de viewers", "organization_id", tenant.OrganizationID, "limit", tenant.Plan.MaxConcurrentViewers)
+	t.recordRejection(ctx, "quota_viewers_exceeded", tenant, map[string]interface{}{
+		"channel_id":             tenant.ChannelID,
+		"open_viewers":           viewers,
+		"max_concurrent_viewers": tenant.Plan.MaxConcurrentViewers,
+	})
+	return tenant, false
+}
+
+// RecordStreamSample suma al mes en curso el tiempo emitido y los bytes
+// transferidos (ingesta + salida) de un stream durante un intervalo. El
+// collector ya resolvió el tenant del stream con ForStream.
+func (t *TenantService) RecordStreamSample(tenant Tenant, interval time.Duration, bytes int64) {
+	if tenant.OrganizationID == "" {
+		return
+	}
+
+	month := currentMonth()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	u, ok := t.usage[tenant.OrganizationID]
+	if !ok || u.month != month {
+		u = &monthlyUsage{month: month}
+		t.usage[tenant.OrganizationID] = u
+	}
+	u.streamSeconds += int64(interval.Seconds())
+	u.bandwidthBytes += bytes
+	u.dirty = true
+}
+
+// FlushUsage persiste el acumulado mensual de este servidor y emite avisos al
+// 80% y 100% del plan sumando el consumo de todos los servidores.
+func (t *TenantService) FlushUsage(ctx context.Context) {
+	t.mu.Lock()
+	pending := make(map[string]monthlyUsage)
+	for orgID, u := range t.usage {
+		if u.dirty {
+			pending[orgID] = *u
+		}
+	}
+	t.mu.Unlock()
+
+	for orgID, u := range pending {
+		if !u.loaded {
+			// Tras un reinicio se parte del acumulado guardado por este servidor
+			previous, err := t.loadServerUsage(ctx, orgID, u.month)
+			if err != nil {
+				slog.WarnContext(ctx, "⚠️ Error cargando consumo", "organization_id", orgID, "error", err)
+				continue
+			}
+			t.mu.Lock()
+			current := t.usage[orgID]
+			if current.month != u.month {
+				t.mu.Unlock()
+				continue
+			}
+			current.streamSeconds += previous.StreamSeconds
+			current.bandwidthBytes += previous.BandwidthBytes
+			current.loaded = true
+			u = *current
+			t.mu.Unlock()
+		}
+
+		err := t.store.Tenants.UpsertUsage(ctx, models.TenantUsage{
+			OrganizationID: orgID,
+			Month:          u.month,
+			ServerID:       t.serverID,
+			StreamSeconds:  u.streamSeconds,
+			BandwidthBytes: u.bandwidthBytes,
+		})
+		if err != nil {
+			continue
+		}
+
+		t.mu.Lock()
+		if current, ok := t.usage[orgID]; ok && current.month == u.month &&
+			current.streamSeconds == u.streamSeconds && current.bandwidthBytes == u.bandwidthBytes {
+			current.dirty = false
+		}
+		t.mu.Unlock()
+
+		t.checkMonthlyLimits(ctx, orgID, u.month)
+	}
+}
+
+func (t *TenantService) loadServerUsage(ctx context.Context, orgID, month string) (models.TenantUsage, error) {
+	rows, err := t.store.Tenants.ListUsage(ctx, orgID, month)
+	if err != nil {
+		return models.TenantUsage{}, err
+	}
+	for _, row := range rows {
+		if row.ServerID == t.serverID {
+			return row, nil
+		}
+	}
+	return models.TenantUsage{}, nil
+}
+
+func (t *TenantService) checkMonthlyLimits(ctx context.Context, orgID, month string) {
+	plan, err := t.store.Tenants.GetPlan(ctx, orgID)
+	if err != nil || plan == nil {
+		return
+	}
+	rows, err := t.store.Tenants.ListUsage(ctx, orgID, month)
+	if err != nil {
+		slog.WarnContext(ctx, "⚠️ Error sumando consumo", "organization_id", orgID, "error", err)
+		return
+	}
+
+	var seconds, bytes int64
+	for _, row := range rows {
+		seconds += row.StreamSeconds
+		bytes += row.BandwidthBytes
+	}
+
+	hours := float64(seconds) / 3600
+	gb := float64(bytes) / 1e9
+	t.checkThreshold(ctx, orgID, month, "stream_hours", hours, plan.MonthlyStreamHours)
+	t.checkThreshold(ctx, orgID, month, "bandwidth_gb", gb, plan.MonthlyBandwidthGB)
+}
+
+func (t *TenantService) checkThreshold(ctx context.Context, orgID, month, metric string, used, limit float64) {
+	if limit <= 0 {
+		return
+	}
+
+	percent := used / limit * 100
+	level := 0
+	switch {
+	case percent >= 100:
+		level = 100
+	case percent >= 80:
+		level = 80
+	default:
+		return
+	}
+
+	key := orgID + "|" + month + "|" + metric
+	t.mu.Lock()
+	t.pruneLocked(time.Now())
+	if t.warned[key] >= level {
+		t.mu.Unlock()
+		return
+	}
+	t.warned[key] = level
+	t.mu.Unlock()
+
+	eventType := "quota_usage_warning"
+	severity := "warning"
+	if level == 100 {
+		eventType = "quota_usage_exceeded"
+		severity = "error"
+	}
+
+	slog.WarnContext(ctx, "📈 Organización cerca de su cuota", "organization_id", orgID, "metric", metric, "percent", percent, "used", used, "limit", limit)
+	t.store.Events.Record(ctx, t.serverID, t.serverIP, eventType, severity,
+		fmt.Sprintf("Organización %s al %.0f%% de %s mensual", orgID, percent, metric),
+		map[string]interface{}{
+			"organization_id": orgID,
+			"month":           month,
+			"metric":          metric,
+			"used":            used,
+			"limit":           limit,
+			"percent":         percent,
+			"threshold":       level,
+		})
+}
+
+// recordRejection registra como máximo un evento por minuto y organización
+func (t *TenantService) recordRejection(ctx context.Context, eventType string, tenant Tenant, metadata map[string]interface{}) {
+	key := eventType + "|" + tenant.OrganizationID
+	now := time.Now()
+	t.mu.Lock()
+	t.pruneLocked(now)
+	if last, ok := t.lastRejectAt[key]; ok && now.Sub(last) < time.Minute {
+		t.mu.Unlock()
+		return
+	}
+	t.lastRejectAt[key] = now
+	t.mu.Unlock()
+
+	metadata["server_id"] = t.serverID
+	metadata["organization_id"] = tenant.OrganizationID
+	metadata["plan_id"] = tenant.Plan.ID
+	go t.store.Events.Record(context.WithoutCancel(ctx), t.serverID, t.serverIP, eventType, "warning",
+		fmt.Sprintf("Cuota alcanzada para organización %s", tenant.OrganizationID), metadata)
+}
+
+// pruneLocked descarta, como mucho una vez por minuto, las entradas de caché
+// vencidas, los avisos de meses anteriores y los rechazos de hace más de un
+// minuto, que ya no limitan eventos.
+func (t *TenantService) pruneLocked(now time.Time) {
+	if now.Sub(t.lastPrune) < time.Minute {
+		return
+	}
+	t.lastPrune = now
+
+	for _, cache := range []map[string]cachedTenant{t.channels, t.streams} {
+		for key, cached := range cache {
+			if !cached.expiresAt.After(now) {
+				delete(cache, key)
+			}
+		}
+	}
+	month := currentMonth()
+	for key := range t.warned {
+		if parts := strings.Split(key, "|"); len(parts) != 3 || parts[1] != month {
+			delete(t.warned, key)
+		}
+	}
+	for key, last := range t.lastRejectAt {
+		if now.Sub(last) >= time.Minute {
+			delete(t.lastRejectAt, key)
+		}
+	}
+}
+
+func currentMonth() string {
+	now := time.Now().UTC()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"srs-backend/internal/storage"
+)
+
+func TestTenantServicePrune(t *testing.T) {
+	now := time.Now()
+	month := currentMonth()
+	utc := now.UTC()
+	previousMonth := time.Date(utc.Year(), utc.Month()-1, 1, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
+
+	store := storage.NewStore(storage.NewMemoryBackend())
+	tenants := NewTenantService(store, nil, "srv-test", "", time.Minute)
+	tenants.channels["ch-expired"] = cachedTenant{expiresAt: now.Add(-time.Second)}
+	tenants.channels["ch-fresh"] = cachedTenant{expiresAt: now.Add(time.Minute)}
+	tenants.streams["key-expired"] = cachedTenant{expiresAt: now}
+	tenants.streams["key-fresh"] = cachedTenant{expiresAt: now.Add(time.Second)}
+	tenants.warned["org1|"+previousMonth+"|stream_hours"] = 100
+	tenants.warned["org1|"+month+"|stream_hours"] = 80
+	tenants.lastRejectAt["quota_streams_exceeded|org1"] = now.Add(-time.Minute)
+	tenants.lastRejectAt["quota_viewers_exceeded|org1"] = now.Add(-30 * time.Second)
+
+	tenants.pruneLocked(now)
+
+	tests := []struct {
+		name string
+		want map[string]bool
+	}{
+		{"channels", map[string]bool{"ch-fresh": true}},
+		{"streams", map[string]bool{"key-fresh": true}},
+		{"warned", map[string]bool{"org1|" + month + "|stream_hours": true}},
+		{"lastRejectAt", map[string]bool{"quota_viewers_exceeded|org1": true}},
+	}
+	current := map[string]map[string]bool{
+		"channels":     keysOf(tenants.channels),
+		"streams":      keysOf(tenants.streams),
+		"warned":       keysOf(tenants.warned),
+		"lastRejectAt": keysOf(tenants.lastRejectAt),
+	}
+	for _, tt := range tests {
+		got := current[tt.name]
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: quedan %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for key := range tt.want {
+			if !got[key] {
+				t.Errorf("%s: falta %q, want %v", tt.name, key, tt.want)
+			}
+		}
+	}
+
+	// Una poda por minuto: lo que vence antes no se descarta hasta la siguiente
+	tenants.channels["ch-expired"] = cachedTenant{expiresAt: now}
+	tenants.pruneLocked(now.Add(30 * time.Second))
+	if _, ok := tenants.channels["ch-expired"]; !ok {
+		t.Error("poda repetida antes de un minuto")
+	}
+	tenants.pruneLocked(now.Add(time.Minute))
+	if _, ok := tenants.channels["ch-expired"]; ok {
+		t.Error("entrada vencida sin podar tras un minuto")
+	}
+}
+
+func keysOf[V any](m map[string]V) map[string]bool {
+	keys := make(map[string]bool, len(m))
+	for key := range m {
+		keys[key] = true
+	}
+	return keys
+}
@@ -1,44 +1,95 @@
 package services
 
 import (
-	"log"
+	"context"
+	"log/slog"
 	"os"
 	"os/exec"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"srs-backend/internal/logging"
 )
 
+var tracer = otel.Tracer("srs-backend/services")
+
+type captureJob struct {
+	ticker *time.Ticker
+	done   chan struct{}
+}
+
 type ThumbnailService struct {
-	activeProcesses map[string]*time.Ticker
+	activeProcesses map[string]*captureJob
 	mu              sync.Mutex
+	stopped         bool
+
+	// Cancelarlo mata los procesos ffmpeg en curso
+	procCtx    context.Context
+	cancelProc context.CancelFunc
+
+	// Cada thumbnail generado se anuncia en /events/stream
+	events *EventBus
 }
 
-func NewThumbnailService() *ThumbnailService {
+func NewThumbnailService(events *EventBus) *ThumbnailService {
+	procCtx, cancel := context.WithCancel(context.Background())
 	return &ThumbnailService{
-		activeProcesses: make(map[string]*time.Ticker),
+		activeProcesses: make(map[string]*captureJob),
+		procCtx:         procCtx,
+		cancelProc:      cancel,
+		events:          events,
 	}
 }
 
-func (s *ThumbnailService) StartCapture(streamID, appName, fileName, rtmpURL, outputPath string) {
+func (s *ThumbnailService) StartCapture(ctx context.Context, streamID, appName, channelID, fileName, rtmpURL, outputPath string) {
 	// Captura inicial
 	time.Sleep(5 * time.Second)
-	log.Printf("📸 Capturando thumbnail inicial...")
-	s.captureThumbnail(rtmpURL, outputPath, fileName)
+	if s.procCtx.Err() != nil {
+		return
+	}
+	slog.InfoContext(ctx, "📸 Capturando thumbnail inicial...")
+	s.captureThumbnail(ctx, appName, channelID, rtmpURL, outputPath, fileName)
 
 	// Ticker: Captura cada 2 minutos
-	ticker := time.NewTicker(2 * time.Minute)
+	job := &captureJob{
+		ticker: time.NewTicker(2 * time.Minute),
+		done:   make(chan struct{}),
+	}
 
+	// Un publish repetido para el mismo stream reemplaza el loop anterior
 	s.mu.Lock()
-	s.activeProcesses[streamID] = ticker
+	if s.stopped {
+		s.mu.Unlock()
+		job.ticker.Stop()
+		return
+	}
+	if previous, ok := s.activeProcesses[streamID]; ok {
+		previous.stop()
+		slog.InfoContext(ctx, "♻️ Loop de thumbnail anterior reemplazado")
+	}
+	s.activeProcesses[streamID] = job
 	s.mu.Unlock()
 
-	log.Printf("⏰ Thumbnail se actualizará cada 2 minutos para %s", streamID)
+	slog.InfoContext(ctx, "⏰ Thumbnail se actualizará cada 2 minutos")
 
 	// Loop que captura periódicamente
+	refreshCtx := logging.WithAttrs(context.Background(), logging.Stream(appName, streamID)...)
 	go func() {
-		for range ticker.C {
-			log.Printf("🔄 Actualizando thumbnail para %s", streamID)
-			s.captureThumbnail(rtmpURL, outputPath, fileName)
+		for {
+			select {
+			case <-job.ticker.C:
+				slog.InfoContext(refreshCtx, "🔄 Actualizando thumbnail")
+				// Cada refresco es una traza propia enlazada a la del publish
+				s.captureThumbnail(refreshCtx, appName, channelID, rtmpURL, outputPath, fileName,
+					trace.WithLinks(trace.LinkFromContext(ctx)))
+			case <-job.done:
+				return
+			}
 		}
 	}()
 }
@@ -47,15 +98,46 @@ func (s *ThumbnailService) StopCapture(streamID string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if ticker, ok := s.activeProcesses[streamID]; ok {
-		ticker.Stop()
+	if job, ok := s.activeProcesses[streamID]; ok {
+		job.stop()
 		delete(s.activeProcesses, streamID)
-		log.Printf("🛑 Ticker detenido para %s", streamID)
+		slog.Info("🛑 Ticker detenido", logging.KeyStreamHash, logging.StreamHash(streamID))
 	}
 }
 
-func (s *ThumbnailService) captureThumbnail(rtmpURL, outputPath, fileName string) {
-	cmd := exec.Command("ffmpeg",
+// StopAll detiene todos los loops de captura y mata los ffmpeg en curso. Tras
+// llamarlo no se inician capturas nuevas.
+func (s *ThumbnailService) StopAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopped = true
+	s.cancelProc()
+	for streamID, job := range s.activeProcesses {
+		job.stop()
+		delete(s.activeProcesses, streamID)
+	}
+	slog.Info("🛑 Capturas de thumbnails detenidas")
+}
+
+// ActiveCaptures devuelve cuántos loops de captura están activos
+func (s *ThumbnailService) ActiveCaptures() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.activeProcesses)
+}
+
+func (j *captureJob) stop() {
+	j.ticker.Stop()
+	close(j.done)
+}
+
+func (s *ThumbnailService) captureThumbnail(ctx context.Context, appName, channelID, rtmpURL, outputPath, fileName string, opts ...trace.SpanStartOption) {
+	opts = append(opts, trace.WithAttributes(attribute.String("thumbnail.file", fileName)))
+	ctx, span := tracer.Start(ctx, "ffmpeg.thumbnail", opts...)
+	defer span.End()
+
+	cmd := exec.CommandContext(s.procCtx, "ffmpeg",
 		"-y",
 		"-i", rtmpURL,
 		// Cambio: tamaño 245x142 con menor costo CPU (Firma: Cursor)
@@ -69,12 +151,16 @@ func (s *ThumbnailService) captureThumbnail(rtmpURL, outputPath, fileName string
 		outputPath)
 
 	if err := cmd.Run(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		if _, statErr := os.Stat(outputPath); statErr == nil {
-			log.Printf("✅ Thumbnail actualizado: %s", fileName)
+			slog.InfoContext(ctx, "✅ Thumbnail actualizado", "thumbnail", fileName)
+			s.events.Publish(EventTypeThumbnail, appName, channelID, map[string]interface{}{"thumbnail": fileName})
 		} else {
-			log.Printf("❌ Error FFmpeg: %v", err)
+			slog.ErrorContext(ctx, "❌ Error FFmpeg", "error", err)
 		}
 	} else {
-		log.Printf("✅ Thumbnail generado: %s", fileName)
+		slog.InfoContext(ctx, "✅ Thumbnail generado", "thumbnail", fileName)
+		s.events.Publish(EventTypeThumbnail, appName, channelID, map[string]interface{}{"thumbnail": fileName})
 	}
 }
\ No newline at end of file
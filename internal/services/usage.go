@@ -0,0 +1,476 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strconv"
+	"time"
+
+	"srs-backend/internal/models"
+	"srs-backend/internal/storage"
+)
+
+// Límites del cálculo y de la exportación de consumo
+const (
+	MaxUsageDays = 366
+	// Filas por consulta; un tramo que llega al límite se lee en dos mitades
+	usageRowLimit = 50000
+	// Tramo de lectura de muestras y sesiones
+	usageChunk = time.Hour
+)
+
+// Formatos de exportación de consumo
+const (
+	UsageFormatCSV    = "csv"
+	UsageFormatNDJSON = "ndjson"
+)
+
+const usageDayLayout = "2006-01-02"
+
+var ErrInvalidUsageQuery = errors.New("consulta de consumo inválida")
+
+var usageCSVHeader = []string{"day", "scope", "organization_id", "channel_id", "ingest_hours", "viewer_minutes", "egress_gb", "peak_concurrent", "views"}
+
+// UsageQuery selecciona el consumo a exportar. From y To son días UTC y
+// ambos se incluyen.
+type UsageQuery struct {
+	From           time.Time
+	To             time.Time
+	Scope          string
+	OrganizationID string
+	ChannelID      string
+	Format         string
+}
+
+// ParseUsageDays lee from y to como YYYY-MM-DD
+func ParseUsageDays(from, to string) (time.Time, time.Time, error) {
+	fromDay, err := time.Parse(usageDayLayout, from)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: from debe ser YYYY-MM-DD", ErrInvalidUsageQuery)
+	}
+	toDay, err := time.Parse(usageDayLayout, to)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: to debe ser YYYY-MM-DD", ErrInvalidUsageQuery)
+	}
+	return fromDay, toDay, nil
+}
+
+// Validate completa el alcance (channel) y el formato (ndjson) por defecto
+func (q *UsageQuery) Validate() error {
+	if q.Scope == "" {
+		q.Scope = models.UsageScopeChannel
+	}
+	if q.Format == "" {
+		q.Format = UsageFormatNDJSON
+	}
+	switch {
+	case q.To.Before(q.From):
+		return fmt.Errorf("%w: from debe ser anterior o igual a to", ErrInvalidUsageQuery)
+	case int(q.To.Sub(q.From).Hours()/24)+1 > MaxUsageDays:
+		return fmt.Errorf("%w: el rango máximo es de %d días", ErrInvalidUsageQuery, MaxUsageDays)
+	case q.Scope != models.UsageScopeChannel && q.Scope != models.UsageScopeOrganization:
+		return fmt.Errorf("%w: scope debe ser channel u organization", ErrInvalidUsageQuery)
+	case q.Scope == models.UsageScopeOrganization && q.ChannelID != "":
+		return fmt.Errorf("%w: channel_id solo se admite con scope=channel", ErrInvalidUsageQuery)
+	case q.Format != UsageFormatCSV && q.Format != UsageFormatNDJSON:
+		return fmt.Errorf("%w: format debe ser csv o ndjson", ErrInvalidUsageQuery)
+	}
+	return nil
+}
+
+// UsageRecord es una fila exportada en las unidades de facturación
+type UsageRecord struct {
+	Day            string  `json:"day"`
+	Scope          string  `json:"scope"`
+	OrganizationID string  `json:"organization_id"`
+	ChannelID      string  `json:"channel_id,omitempty"`
+	IngestHours    float64 `json:"ingest_hours"`
+	ViewerMinutes  float64 `json:"viewer_minutes"`
+	EgressGB       float64 `json:"egress_gb"`
+	PeakConcurrent int     `json:"peak_concurrent"`
+	Views          int     `json:"views"`
+}
+
+func newUsageRecord(row models.DailyUsage) UsageRecord {
+	return UsageRecord{
+		Day:            row.Day,
+		Scope:          row.Scope,
+		OrganizationID: row.OrganizationID,
+		ChannelID:      row.ChannelID,
+		IngestHours:    roundTo(float64(row.IngestSeconds)/3600, 4),
+		ViewerMinutes:  roundTo(float64(row.ViewerSeconds)/60, 2),
+		EgressGB:       roundTo(float64(row.EgressBytes)/1e9, 4),
+		PeakConcurrent: row.PeakConcurrent,
+		Views:          row.Views,
+	}
+}
+
+func (r UsageRecord) csv() []string {
+	return []string{
+		r.Day, r.Scope, r.OrganizationID, r.ChannelID,
+		strconv.FormatFloat(r.IngestHours, 'f', -1, 64),
+		strconv.FormatFloat(r.ViewerMinutes, 'f', -1, 64),
+		strconv.FormatFloat(r.EgressGB, 'f', -1, 64),
+		strconv.Itoa(r.PeakConcurrent),
+		strconv.Itoa(r.Views),
+	}
+}
+
+// channelUsage acumula el consumo de un canal durante el cálculo de un día
+type channelUsage struct {
+	organizationID string
+	ingestBuckets  map[time.Time]bool
+	egressBytes    int64
+	viewerSeconds  float64
+	views          int
+	sessions       [][2]time.Time
+}
+
+// UsageService calcula el consumo diario por canal y organización: horas de
+// ingesta y salida (GB) de las muestras del recolector, minutos vistos,
+// reproducciones y pico de viewers de las sesiones. Guarda el resultado en
+// server_ingest_usage_daily para exportarlo aunque la retención ya haya
+// borrado las muestras.
+type UsageService struct {
+	store    *storage.Store
+	tenants  *TenantService
+	interval time.Duration
+	lookback int
+	// Filas por consulta, usageRowLimit salvo en los tests
+	rowLimit int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewUsageService(store *storage.Store, tenants *TenantService, interval time.Duration, lookbackDays int) *UsageService {
+	return &UsageService{
+		store:    store,
+		tenants:  tenants,
+		interval: interval,
+		lookback: max(lookbackDays, 1),
+		rowLimit: usageRowLimit,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start recalcula hoy y los lookback-1 días anteriores al arrancar y luego
+// cada interval: las sesiones que cierran tarde y el outbox completan días
+// ya calculados. Bloquea; usar con go.
+func (u *UsageService) Start() {
+	defer close(u.done)
+	slog.Info("📒 Cálculo de consumo diario iniciado", "interval", u.interval.String(), "lookback_days", u.lookback)
+
+	ticker := time.NewTicker(u.interval)
+	defer ticker.Stop()
+	for {
+		u.refresh()
+		select {
+		case <-ticker.C:
+		case <-u.stop:
+			return
+		}
+	}
+}
+
+// Stop espera a que termine el cálculo en curso
+func (u *UsageService) Stop(ctx context.Context) {
+	close(u.stop)
+	select {
+	case <-u.done:
+	case <-ctx.Done():
+	}
+}
+
+func (u *UsageService) refresh() {
+	ctx, span := tracer.Start(context.Background(), "usage.refresh")
+	defer span.End()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for i := 0; i < u.lookback; i++ {
+		if _, err := u.ComputeDay(ctx, today.AddDate(0, 0, -i)); err != nil {
+			slog.ErrorContext(ctx, "❌ Error calculando consumo diario", "day", today.AddDate(0, 0, -i).Format(usageDayLayout), "error", err)
+		}
+	}
+}
+
+// ComputeDay recalcula el consumo de un día UTC y reemplaza sus filas. Un
+// día cuyas muestras o sesiones ya borró la retención queda incompleto.
+func (u *UsageService) ComputeDay(ctx context.Context, day time.Time) ([]models.DailyUsage, error) {
+	ctx, span := tracer.Start(ctx, "usage.compute_day")
+	defer span.End()
+
+	window := AnalyticsWindow{From: day.UTC().Truncate(24 * time.Hour)}
+	window.To = window.From.Add(24 * time.Hour)
+	dayValue := window.From.Format(usageDayLayout)
+	now := time.Now().UTC()
+
+	channels := make(map[string]*channelUsage)
+	channel := func(channelID, organizationID string) *channelUsage {
+		c, ok := channels[channelID]
+		if !ok {
+			c = &channelUsage{ingestBuckets: make(map[time.Time]bool)}
+			channels[channelID] = c
+		}
+		if c.organizationID == "" {
+			c.organizationID = organizationID
+		}
+		return c
+	}
+
+	// Muestras: un tramo de collectInterval con el stream publicando cuenta
+	// una vez aunque lo vean varios servidores; la salida se suma entre todos
+	unresolved := 0
+	streams := make(map[string]Tenant)
+	err := readUsageChunks(window.From, window.To, u.rowLimit, func(from, to time.Time) (bool, error) {
+		samples, err := u.store.Usage.ListSamples(ctx, from, to, u.rowLimit)
+		if err != nil || len(samples) >= u.rowLimit {
+			return false, err
+		}
+		for _, s := range samples {
+			channelID, organizationID := s.ChannelID, s.OrganizationID
+			if channelID == "" {
+				// Muestras anteriores a guardar el canal: solo se resuelven
+				// la clave actual y las claves en gracia, una vez por clave
+				tenant, ok := streams[s.StreamName]
+				if !ok {
+					tenant, _ = u.tenants.ForStream(ctx, s.StreamName)
+					streams[s.StreamName] = tenant
+				}
+				if tenant.ChannelID == "" {
+					unresolved++
+					continue
+				}
+				channelID, organizationID = tenant.ChannelID, tenant.OrganizationID
+			}
+			c := channel(channelID, organizationID)
+			c.egressBytes += int64(s.SendKbps) * 1000 / 8 * int64(collectInterval.Seconds())
+			if s.IsPublishing {
+				c.ingestBuckets[s.Timestamp.UTC().Truncate(collectInterval)] = true
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Sesiones: las abiertas al empezar el día y las iniciadas en él
+	ongoing, truncated, err := u.store.Usage.ListOngoing(ctx, window.From, u.rowLimit)
+	if err != nil {
+		return nil, err
+	}
+	if truncated {
+		return nil, fmt.Errorf("más de %d sesiones abiertas al empezar %s", u.rowLimit, dayValue)
+	}
+	addSessions := func(sessions []models.ViewerSession) {
+		for _, v := range sessions {
+			if v.ChannelID == "" {
+				continue
+			}
+			start, end, ok := window.clip(v.ConnectedAt, sessionEnd(v, now))
+			if !ok {
+				continue
+			}
+			c := channel(v.ChannelID, v.OrganizationID)
+			c.viewerSeconds += end.Sub(start).Seconds()
+			c.sessions = append(c.sessions, [2]time.Time{start, end})
+			if !v.ConnectedAt.Before(window.From) {
+				c.views++
+			}
+		}
+	}
+	addSessions(ongoing)
+	err = readUsageChunks(window.From, window.To, u.rowLimit, func(from, to time.Time) (bool, error) {
+		sessions, err := u.store.Usage.ListSessions(ctx, from, to, u.rowLimit)
+		if err != nil || len(sessions) >= u.rowLimit {
+			return false, err
+		}
+		addSessions(sessions)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := buildDailyUsage(dayValue, channels)
+	if err := u.store.Usage.Replace(ctx, dayValue, rows); err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "📒 Consumo diario calculado", "day", dayValue, "channels", len(channels),
+		"rows", len(rows), "unresolved_samples", unresolved)
+	return rows, nil
+}
+
+// buildDailyUsage devuelve una fila por canal y otra por organización. El
+// pico de la organización se calcula con todas sus sesiones, no sumando los
+// picos de sus canales.
+func buildDailyUsage(day string, channels map[string]*channelUsage) []models.DailyUsage {
+	channelIDs := make([]string, 0, len(channels))
+	for channelID := range channels {
+		channelIDs = append(channelIDs, channelID)
+	}
+	sort.Strings(channelIDs)
+
+	var rows []models.DailyUsage
+	organizations := make(map[string]*models.DailyUsage)
+	orgSessions := make(map[string][][2]time.Time)
+	var orgIDs []string
+	for _, channelID := range channelIDs {
+		c := channels[channelID]
+		row := models.DailyUsage{
+			Day:            day,
+			Scope:          models.UsageScopeChannel,
+			ChannelID:      channelID,
+			OrganizationID: c.organizationID,
+			IngestSeconds:  int64(len(c.ingestBuckets)) * int64(collectInterval.Seconds()),
+			ViewerSeconds:  int64(c.viewerSeconds + 0.5),
+			EgressBytes:    c.egressBytes,
+			PeakConcurrent: peakConcurrent(c.sessions),
+			Views:          c.views,
+		}
+		rows = append(rows, row)
+		if c.organizationID == "" {
+			continue
+		}
+
+		org, ok := organizations[c.organizationID]
+		if !ok {
+			org = &models.DailyUsage{Day: day, Scope: models.UsageScopeOrganization, OrganizationID: c.organizationID}
+			organizations[c.organizationID] = org
+			orgIDs = append(orgIDs, c.organizationID)
+		}
+		org.IngestSeconds += row.IngestSeconds
+		org.ViewerSeconds += row.ViewerSeconds
+		org.EgressBytes += row.EgressBytes
+		org.Views += row.Views
+		orgSessions[c.organizationID] = append(orgSessions[c.organizationID], c.sessions...)
+	}
+
+	sort.Strings(orgIDs)
+	for _, orgID := range orgIDs {
+		org := organizations[orgID]
+		org.PeakConcurrent = peakConcurrent(orgSessions[orgID])
+		rows = append(rows, *org)
+	}
+	return rows
+}
+
+// peakConcurrent recorre inicios y finales en orden; en el mismo instante
+// el final va antes, para que un reconectar no cuente dos viewers
+func peakConcurrent(sessions [][2]time.Time) int {
+	type edge struct {
+		at    time.Time
+		delta int
+	}
+	edges := make([]edge, 0, 2*len(sessions))
+	for _, s := range sessions {
+		edges = append(edges, edge{s[0], 1}, edge{s[1], -1})
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].at.Equal(edges[j].at) {
+			return edges[i].delta < edges[j].delta
+		}
+		return edges[i].at.Before(edges[j].at)
+	})
+
+	current, peak := 0, 0
+	for _, e := range edges {
+		current += e.delta
+		peak = max(peak, current)
+	}
+	return peak
+}
+
+// readUsageChunks llama a read por tramos de usageChunk. Si read devuelve
+// false (el tramo llegó a limit filas) lo reintenta en mitades; por debajo
+// de un segundo falla para no facturar de menos.
+func readUsageChunks(from, to time.Time, limit int, read func(from, to time.Time) (bool, error)) error {
+	step := usageChunk
+	for start := from; start.Before(to); {
+		end := minTime(start.Add(step), to)
+		complete, err := read(start, end)
+		if err != nil {
+			return err
+		}
+		if !complete {
+			if step <= time.Second {
+				return fmt.Errorf("más de %d filas en %s", limit, start.Format(time.RFC3339))
+			}
+			// Segundos enteros: los filtros de tiempo van en RFC3339
+			step = (step / 2).Truncate(time.Second)
+			continue
+		}
+		start, step = end, usageChunk
+	}
+	return nil
+}
+
+// Export escribe en w el consumo guardado de cada día del rango, en CSV con
+// cabecera o en NDJSON. Devuelve las filas escritas. Los errores de
+// validación se devuelven antes de escribir nada.
+func (u *UsageService) Export(ctx context.Context, q UsageQuery, w io.Writer) (int, error) {
+	ctx, span := tracer.Start(ctx, "usage.export")
+	defer span.End()
+
+	if err := q.Validate(); err != nil {
+		return 0, err
+	}
+
+	var csvWriter *csv.Writer
+	var encoder *json.Encoder
+	if q.Format == UsageFormatCSV {
+		csvWriter = csv.NewWriter(w)
+		if err := csvWriter.Write(usageCSVHeader); err != nil {
+			return 0, err
+		}
+	} else {
+		encoder = json.NewEncoder(w)
+	}
+
+	written := 0
+	for day := q.From; !day.After(q.To); day = day.AddDate(0, 0, 1) {
+		dayValue := day.Format(usageDayLayout)
+		rows, err := u.store.Usage.List(ctx, dayValue, q.Scope, q.OrganizationID, q.ChannelID, u.rowLimit)
+		if err != nil {
+			return written, err
+		}
+		if len(rows) >= u.rowLimit {
+			return written, fmt.Errorf("más de %d filas de consumo en %s", u.rowLimit, dayValue)
+		}
+		sort.Slice(rows, func(i, j int) bool {
+			if rows[i].OrganizationID != rows[j].OrganizationID {
+				return rows[i].OrganizationID < rows[j].OrganizationID
+			}
+			return rows[i].ChannelID < rows[j].ChannelID
+		})
+
+		for _, row := range rows {
+			record := newUsageRecord(row)
+			if csvWriter != nil {
+				err = csvWriter.Write(record.csv())
+			} else {
+				err = encoder.Encode(record)
+			}
+			if err != nil {
+				return written, err
+			}
+			written++
+		}
+		if csvWriter != nil {
+			// Cada día se envía sin esperar al final del rango
+			csvWriter.Flush()
+			if err := csvWriter.Error(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
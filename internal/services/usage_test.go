@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"srs-backend/internal/models"
+	"srs-backend/internal/storage"
+)
+
+var usageTestDay = time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+
+// newTestUsage arma el cálculo sobre el backend en memoria con el canal ch1
+// (clave key1, organización org1)
+func newTestUsage(t *testing.T, samples, sessions []map[string]interface{}) *UsageService {
+	t.Helper()
+
+	backend := storage.NewMemoryBackend()
+	seeds := map[string][]map[string]interface{}{
+		"channels_channel":                 {{"id": "ch1", "stream_id": "key1", "organization_id": "org1"}},
+		"server_ingest_stream_metrics":     samples,
+		"server_ingest_client_connections": sessions,
+	}
+	for table, rows := range seeds {
+		if len(rows) == 0 {
+			continue
+		}
+		if err := backend.Seed(table, rows...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := storage.NewStore(backend)
+	keys := NewStreamKeyService(store, "srv-test", "", "")
+	tenants := NewTenantService(store, keys, "srv-test", "", time.Minute)
+	return NewUsageService(store, tenants, time.Hour, 1)
+}
+
+func usageSample(serverID, channelID, streamName string, at time.Duration, sendKbps int, publishing bool) map[string]interface{} {
+	organizationID := ""
+	if channelID != "" {
+		organizationID = "org1"
+	}
+	return map[string]interface{}{
+		"server_id":       serverID,
+		"stream_name":     streamName,
+		"channel_id":      channelID,
+		"organization_id": organizationID,
+		"timestamp":       usageTestDay.Add(at),
+		"send_kbps":       sendKbps,
+		"is_publishing":   publishing,
+	}
+}
+
+func usageSession(from, to time.Duration) map[string]interface{} {
+	return map[string]interface{}{
+		"client_type":     "play",
+		"stream_name":     "key1",
+		"channel_id":      "ch1",
+		"organization_id": "org1",
+		"connected_at":    usageTestDay.Add(from),
+		"disconnected_at": usageTestDay.Add(to),
+	}
+}
+
+// usageRows devuelve la fila del canal ch1 y la de org1 con el mismo consumo
+func usageRows(usage models.DailyUsage) []models.DailyUsage {
+	usage.Day = usageTestDay.Format(usageDayLayout)
+	usage.OrganizationID = "org1"
+	channel, org := usage, usage
+	channel.Scope, channel.ChannelID = models.UsageScopeChannel, "ch1"
+	org.Scope = models.UsageScopeOrganization
+	return []models.DailyUsage{channel, org}
+}
+
+func TestUsageComputeDay(t *testing.T) {
+	tests := []struct {
+		name     string
+		samples  []map[string]interface{}
+		sessions []map[string]interface{}
+		want     models.DailyUsage
+	}{
+		{
+			name: "ingesta deduplicada entre servidores",
+			samples: []map[string]interface{}{
+				usageSample("srv-a", "ch1", "key1", 5*time.Second, 0, true),
+				usageSample("srv-b", "ch1", "key1", 10*time.Second, 0, true),
+				usageSample("srv-a", "ch1", "key1", 35*time.Second, 0, true),
+				usageSample("srv-a", "ch1", "key1", 65*time.Second, 0, false),
+				// Del día anterior
+				usageSample("srv-a", "ch1", "key1", -10*time.Second, 0, true),
+			},
+			want: models.DailyUsage{IngestSeconds: 60},
+		},
+		{
+			name: "salida sumada entre servidores",
+			samples: []map[string]interface{}{
+				usageSample("srv-a", "ch1", "key1", 5*time.Second, 2500, true),
+				usageSample("srv-b", "ch1", "key1", 10*time.Second, 800, false),
+			},
+			// kbps * 1000 / 8 * 30s
+			want: models.DailyUsage{IngestSeconds: 30, EgressBytes: 9375000 + 3000000},
+		},
+		{
+			name: "muestra sin canal resuelta por clave",
+			samples: []map[string]interface{}{
+				usageSample("srv-a", "", "key1", 5*time.Second, 800, true),
+				usageSample("srv-a", "", "key1", 35*time.Second, 800, true),
+				usageSample("srv-a", "", "key9", 5*time.Second, 800, true),
+			},
+			want: models.DailyUsage{IngestSeconds: 60, EgressBytes: 6000000},
+		},
+		{
+			name: "sesiones recortadas al día",
+			sessions: []map[string]interface{}{
+				// Abierta al empezar el día: cuenta 10 minutos, no es una vista
+				usageSession(-10*time.Minute, 10*time.Minute),
+				usageSession(5*time.Minute, 20*time.Minute),
+				// Termina al día siguiente: cuenta 5 minutos
+				usageSession(24*time.Hour-5*time.Minute, 24*time.Hour+5*time.Minute),
+				// Fuera del día
+				usageSession(-30*time.Minute, -time.Minute),
+				usageSession(24*time.Hour, 24*time.Hour+time.Hour),
+			},
+			want: models.DailyUsage{ViewerSeconds: 1800, PeakConcurrent: 2, Views: 2},
+		},
+		{
+			name: "reconexión en el mismo instante",
+			sessions: []map[string]interface{}{
+				usageSession(time.Hour, 2*time.Hour),
+				usageSession(2*time.Hour, 3*time.Hour),
+			},
+			want: models.DailyUsage{ViewerSeconds: 7200, PeakConcurrent: 1, Views: 2},
+		},
+	}
+	for _, tt := range tests {
+		u := newTestUsage(t, tt.samples, tt.sessions)
+		got, err := u.ComputeDay(context.Background(), usageTestDay)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if want := usageRows(tt.want); !slices.Equal(got, want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tt.name, got, want)
+		}
+	}
+}
+
+func TestUsageComputeDayRowLimit(t *testing.T) {
+	sameSecond := []map[string]interface{}{
+		usageSample("srv-a", "ch1", "key1", time.Minute, 0, true),
+		usageSample("srv-b", "ch1", "key1", time.Minute, 0, true),
+		usageSample("srv-c", "ch1", "key1", time.Minute, 0, true),
+	}
+
+	tests := []struct {
+		name     string
+		samples  []map[string]interface{}
+		sessions []map[string]interface{}
+		want     models.DailyUsage
+		wantErr  string
+	}{
+		{
+			name: "tramo partido en mitades",
+			samples: []map[string]interface{}{
+				usageSample("srv-a", "ch1", "key1", 0, 0, true),
+				usageSample("srv-a", "ch1", "key1", 5*time.Minute, 0, true),
+				usageSample("srv-a", "ch1", "key1", 10*time.Minute, 0, true),
+				usageSample("srv-a", "ch1", "key1", 20*time.Minute, 0, true),
+				usageSample("srv-a", "ch1", "key1", 40*time.Minute, 0, true),
+				usageSample("srv-a", "ch1", "key1", 45*time.Minute, 0, true),
+				usageSample("srv-a", "ch1", "key1", 50*time.Minute, 0, true),
+			},
+			sessions: []map[string]interface{}{
+				usageSession(time.Minute, 2*time.Minute),
+				usageSession(3*time.Minute, 4*time.Minute),
+				usageSession(5*time.Minute, 6*time.Minute),
+				usageSession(7*time.Minute, 8*time.Minute),
+			},
+			want: models.DailyUsage{IngestSeconds: 210, ViewerSeconds: 240, PeakConcurrent: 1, Views: 4},
+		},
+		{
+			name:    "límite alcanzado en un segundo",
+			samples: sameSecond,
+			wantErr: "más de 3 filas",
+		},
+	}
+	for _, tt := range tests {
+		u := newTestUsage(t, tt.samples, tt.sessions)
+		u.rowLimit = 3
+		got, err := u.ComputeDay(context.Background(), usageTestDay)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if want := usageRows(tt.want); !slices.Equal(got, want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tt.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,678 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"srs-backend/internal/models"
+	"srs-backend/internal/storage"
+	"srs-backend/internal/telemetry"
+)
+
+var (
+	ErrInvalidWebhook  = errors.New("webhook inválido")
+	ErrWebhookNotFound = errors.New("webhook no encontrado")
+)
+
+const (
+	// Espera antes del primer reintento; se duplica en cada fallo hasta
+	// maxBackoff
+	webhookBaseBackoff = 10 * time.Second
+	webhookWorkers     = 4
+	// Bytes de la respuesta guardados en last_error
+	webhookMaxResponse = 512
+	// Entregas pendientes que se retoman al arrancar
+	webhookResumeLimit = 1000
+	webhookMinSecret   = 16
+)
+
+// Cabeceras de cada envío. La firma es HMAC-SHA256 en hexadecimal de
+// "<timestamp>.<body>" con el secreto del webhook.
+const (
+	WebhookHeaderEvent     = "X-Webhook-Event"
+	WebhookHeaderEventID   = "X-Webhook-Id"
+	WebhookHeaderDelivery  = "X-Webhook-Delivery"
+	WebhookHeaderTimestamp = "X-Webhook-Timestamp"
+	WebhookHeaderSignature = "X-Webhook-Signature"
+)
+
+var webhookDeliveryStatuses = []string{
+	models.WebhookDeliveryPending,
+	models.WebhookDeliveryDelivered,
+	models.WebhookDeliveryFailed,
+	models.WebhookDeliveryDead,
+}
+
+// WebhookEvent es el cuerpo JSON de cada envío
+type WebhookEvent struct {
+	ID             string                 `json:"id"`
+	Type           string                 `json:"type"`
+	CreatedAt      time.Time              `json:"created_at"`
+	ServerID       string                 `json:"server_id"`
+	ChannelID      string                 `json:"channel_id,omitempty"`
+	OrganizationID string                 `json:"organization_id,omitempty"`
+	Data           map[string]interface{} `json:"data"`
+}
+
+// WebhookUpdate son los campos que cambia PUT; nil los mantiene. Secret
+// vacío genera uno nuevo.
+type WebhookUpdate struct {
+	URL        *string  `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Active     *bool    `json:"active"`
+	Secret     *string  `json:"secret"`
+}
+
+// SignWebhook calcula la firma que se envía en X-Webhook-Signature (sin el
+// prefijo "v1=")
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type cachedWebhooks struct {
+	webhooks  []models.Webhook
+	expiresAt time.Time
+}
+
+// webhookJob es un intento de entrega pendiente. webhook es nil en los
+// reintentos: se vuelve a leer por si cambió la URL o el secreto.
+type webhookJob struct {
+	delivery models.WebhookDelivery
+	webhook  *models.Webhook
+}
+
+// WebhookService envía los eventos de canales y sesiones a los webhooks de
+// cada canal y de su organización. Cada entrega se guarda en
+// server_ingest_webhook_deliveries; ante un fallo se reintenta con backoff
+// exponencial y tras maxAttempts queda como dead. Las entregas pendientes las
+// retoma al arrancar el mismo servidor que las creó.
+type WebhookService struct {
+	store       *storage.Store
+	tenants     *TenantService
+	serverID    string
+	serverIP    string
+	client      *http.Client
+	maxAttempts int
+	maxBackoff  time.Duration
+	cacheTTL    time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedWebhooks // "channel:<id>" u "organization:<id>"
+	// scheduled son las entregas programadas en este proceso: resume no
+	// debe volver a programar las que Emit guardó mientras arrancaba
+	scheduled map[string]struct{}
+
+	work chan webhookJob
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewWebhookService(store *storage.Store, tenants *TenantService, serverID, serverIP string, timeout time.Duration, maxAttempts int, maxBackoff, cacheTTL time.Duration) *WebhookService {
+	return &WebhookService{
+		store:    store,
+		tenants:  tenants,
+		serverID: serverID,
+		serverIP: serverIP,
+		client: &http.Client{
+			Timeout: timeout,
+			// Una redirección cuenta como fallo: la URL configurada es la que se firma
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		maxAttempts: max(1, maxAttempts),
+		maxBackoff:  maxBackoff,
+		cacheTTL:    cacheTTL,
+		cache:       make(map[string]cachedWebhooks),
+		scheduled:   make(map[string]struct{}),
+		work:        make(chan webhookJob),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Start retoma las entregas pendientes y atiende los envíos hasta Stop
+func (s *WebhookService) Start() {
+	defer close(s.done)
+	s.resume()
+
+	var wg sync.WaitGroup
+	for i := 0; i < webhookWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case job := <-s.work:
+					s.attempt(job)
+				case <-s.stop:
+					return
+				}
+			}
+		}()
+	}
+	slog.Info("🪝 Webhooks iniciados", "workers", webhookWorkers, "max_attempts", s.maxAttempts)
+	wg.Wait()
+}
+
+// Stop espera a los envíos en curso. Los reintentos programados quedan
+// pendientes en la base de datos y se retoman al arrancar.
+func (s *WebhookService) Stop(ctx context.Context) {
+	close(s.stop)
+	select {
+	case <-s.done:
+		slog.InfoContext(ctx, "🪝 Webhooks detenidos")
+	case <-ctx.Done():
+	}
+}
+
+// Emit envía el evento a los webhooks del canal y de su organización que lo
+// suscriben. No bloquea el hook: solo guarda y programa las entregas.
+func (s *WebhookService) Emit(ctx context.Context, eventType, channelID string, data map[string]interface{}) {
+	if channelID == "" {
+		return
+	}
+	ctx, span := tracer.Start(ctx, "webhooks.emit")
+	defer span.End()
+	span.SetAttributes(attribute.String("webhook.event", eventType))
+
+	tenant, err := s.tenants.ForChannel(ctx, channelID)
+	if err != nil {
+		slog.WarnContext(ctx, "⚠️ Error obteniendo la organización del canal para webhooks", "channel_id", channelID, "error", err)
+	}
+	webhooks, err := s.subscribed(ctx, eventType, channelID, tenant.OrganizationID)
+	if err != nil {
+		slog.ErrorContext(ctx, "❌ Error leyendo webhooks", "channel_id", channelID, "error", err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	event := WebhookEvent{
+		ID:             "evt_" + randomHex(12),
+		Type:           eventType,
+		CreatedAt:      time.Now().UTC(),
+		ServerID:       s.serverID,
+		ChannelID:      channelID,
+		OrganizationID: tenant.OrganizationID,
+		Data:           data,
+	}
+	for i := range webhooks {
+		delivery, err := s.newDelivery(event)
+		if err != nil {
+			slog.ErrorContext(ctx, "❌ Error serializando evento de webhook", "event_type", eventType, "error", err)
+			return
+		}
+		delivery.WebhookID = webhooks[i].ID
+		s.track(delivery.ID)
+		s.store.Webhooks.SaveDelivery(ctx, delivery)
+		s.schedule(webhookJob{delivery: delivery, webhook: &webhooks[i]}, 0)
+	}
+	slog.DebugContext(ctx, "🪝 Evento encolado para webhooks", "event_type", eventType, "channel_id", channelID, "webhooks", len(webhooks))
+}
+
+// SendTest envía un evento webhook.test en un único intento, sin reintentos,
+// y devuelve la entrega con el resultado
+func (s *WebhookService) SendTest(ctx context.Context, id string) (*models.WebhookDelivery, error) {
+	webhook, err := s.store.Webhooks.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if webhook == nil {
+		return nil, ErrWebhookNotFound
+	}
+
+	delivery, err := s.newDelivery(WebhookEvent{
+		ID:             "evt_" + randomHex(12),
+		Type:           models.WebhookEventTest,
+		CreatedAt:      time.Now().UTC(),
+		ServerID:       s.serverID,
+		ChannelID:      webhook.ChannelID,
+		OrganizationID: webhook.OrganizationID,
+		Data:           map[string]interface{}{"webhook_id": webhook.ID},
+	})
+	if err != nil {
+		return nil, err
+	}
+	delivery.WebhookID = webhook.ID
+
+	status, err := s.send(ctx, webhook, delivery)
+	delivery.Attempts = 1
+	s.recordResult(&delivery, status, err)
+	if err != nil {
+		delivery.Status = models.WebhookDeliveryFailed
+		telemetry.RecordWebhookDelivery(models.WebhookDeliveryFailed)
+	} else {
+		telemetry.RecordWebhookDelivery(models.WebhookDeliveryDelivered)
+	}
+	s.store.Webhooks.SaveDelivery(ctx, delivery)
+	return &delivery, nil
+}
+
+// List devuelve los webhooks sin el secreto
+func (s *WebhookService) List(ctx context.Context, organizationID, channelID string) ([]models.Webhook, error) {
+	webhooks, err := s.store.Webhooks.List(ctx, organizationID, channelID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, nil
+}
+
+// Get devuelve el webhook sin el secreto
+func (s *WebhookService) Get(ctx context.Context, id string) (*models.Webhook, error) {
+	webhook, err := s.store.Webhooks.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if webhook == nil {
+		return nil, ErrWebhookNotFound
+	}
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+// Create valida y guarda el webhook. Sin secreto se genera uno; la respuesta
+// es la única vez que se devuelve.
+func (s *WebhookService) Create(ctx context.Context, webhook models.Webhook) (*models.Webhook, error) {
+	if (webhook.ChannelID == "") == (webhook.OrganizationID == "") {
+		return nil, fmt.Errorf("%w: indicar channel_id u organization_id, no ambos", ErrInvalidWebhook)
+	}
+	if webhook.ChannelID != "" {
+		_, exists, err := s.store.Channels.GetStreamKey(ctx, webhook.ChannelID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, ErrChannelNotFound
+		}
+	}
+	if err := validateWebhook(&webhook); err != nil {
+		return nil, err
+	}
+	if webhook.Secret == "" {
+		webhook.Secret = "whsec_" + randomHex(24)
+	}
+
+	now := time.Now().UTC()
+	webhook.ID = "wh_" + randomHex(12)
+	webhook.Active = true
+	webhook.CreatedAt = now
+	webhook.UpdatedAt = now
+	if err := s.store.Webhooks.Save(ctx, webhook); err != nil {
+		return nil, err
+	}
+	s.forget()
+	return &webhook, nil
+}
+
+// Update cambia URL, eventos, estado o secreto. El secreto solo se devuelve
+// si cambió.
+func (s *WebhookService) Update(ctx context.Context, id string, update WebhookUpdate) (*models.Webhook, error) {
+	webhook, err := s.store.Webhooks.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if webhook == nil {
+		return nil, ErrWebhookNotFound
+	}
+
+	if update.URL != nil {
+		webhook.URL = *update.URL
+	}
+	if update.EventTypes != nil {
+		webhook.EventTypes = update.EventTypes
+	}
+	if update.Active != nil {
+		webhook.Active = *update.Active
+	}
+	rotated := update.Secret != nil
+	if rotated {
+		webhook.Secret = *update.Secret
+	}
+	if err := validateWebhook(webhook); err != nil {
+		return nil, err
+	}
+	if webhook.Secret == "" {
+		webhook.Secret = "whsec_" + randomHex(24)
+	}
+	webhook.UpdatedAt = time.Now().UTC()
+
+	if err := s.store.Webhooks.Save(ctx, *webhook); err != nil {
+		return nil, err
+	}
+	s.forget()
+	if !rotated {
+		webhook.Secret = ""
+	}
+	return webhook, nil
+}
+
+func (s *WebhookService) Delete(ctx context.Context, id string) error {
+	webhook, err := s.store.Webhooks.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if webhook == nil {
+		return ErrWebhookNotFound
+	}
+	if err := s.store.Webhooks.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.forget()
+	return nil
+}
+
+// Deliveries devuelve el registro de entregas del webhook; status filtra
+// (dead para las que agotaron los reintentos)
+func (s *WebhookService) Deliveries(ctx context.Context, id, status string, limit int) ([]models.WebhookDelivery, error) {
+	if status != "" && !slices.Contains(webhookDeliveryStatuses, status) {
+		return nil, fmt.Errorf("%w: status debe ser uno de %v", ErrInvalidWebhook, webhookDeliveryStatuses)
+	}
+	webhook, err := s.store.Webhooks.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if webhook == nil {
+		return nil, ErrWebhookNotFound
+	}
+	return s.store.Webhooks.ListDeliveries(ctx, id, status, limit)
+}
+
+// subscribed devuelve los webhooks activos del canal y de la organización
+// que incluyen eventType
+func (s *WebhookService) subscribed(ctx context.Context, eventType, channelID, organizationID string) ([]models.Webhook, error) {
+	webhooks, err := s.cached(ctx, "channel:"+channelID, "", channelID)
+	if err != nil {
+		return nil, err
+	}
+	if organizationID != "" {
+		byOrganization, err := s.cached(ctx, "organization:"+organizationID, organizationID, "")
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(slices.Clip(webhooks), byOrganization...)
+	}
+
+	var matched []models.Webhook
+	for _, w := range webhooks {
+		if w.Active && slices.Contains(w.EventTypes, eventType) {
+			matched = append(matched, w)
+		}
+	}
+	return matched, nil
+}
+
+// cached lee los webhooks de un canal u organización, también cuando no hay
+// ninguno: la mayoría de canales no tienen y cada play pasaría por aquí
+func (s *WebhookService) cached(ctx context.Context, key, organizationID, channelID string) ([]models.Webhook, error) {
+	now := time.Now()
+	s.mu.Lock()
+	if cached, ok := s.cache[key]; ok && cached.expiresAt.After(now) {
+		s.mu.Unlock()
+		return cached.webhooks, nil
+	}
+	s.mu.Unlock()
+
+	webhooks, err := s.store.Webhooks.List(ctx, organizationID, channelID)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.cache[key] = cachedWebhooks{webhooks: webhooks, expiresAt: now.Add(s.cacheTTL)}
+	s.mu.Unlock()
+	return webhooks, nil
+}
+
+// forget vacía la caché; otros servidores ven el cambio al expirar la suya
+func (s *WebhookService) forget() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.cache)
+}
+
+func (s *WebhookService) newDelivery(event WebhookEvent) (models.WebhookDelivery, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	return models.WebhookDelivery{
+		ID:        "whd_" + randomHex(12),
+		EventID:   event.ID,
+		EventType: event.Type,
+		Payload:   payload,
+		ServerID:  s.serverID,
+		Status:    models.WebhookDeliveryPending,
+		CreatedAt: event.CreatedAt,
+	}, nil
+}
+
+// schedule entrega el trabajo a los workers tras delay; tras Stop se
+// descarta y la entrega sigue pendiente en la base de datos
+func (s *WebhookService) schedule(job webhookJob, delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		select {
+		case s.work <- job:
+		case <-s.stop:
+		}
+	})
+}
+
+// track marca la entrega como programada; false si ya lo estaba
+func (s *WebhookService) track(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.scheduled[id]; ok {
+		return false
+	}
+	s.scheduled[id] = struct{}{}
+	return true
+}
+
+func (s *WebhookService) untrack(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.scheduled, id)
+}
+
+// resume programa las entregas que este servidor dejó pendientes
+func (s *WebhookService) resume() {
+	ctx := context.Background()
+	pending, err := s.store.Webhooks.ListPending(ctx, s.serverID, webhookResumeLimit)
+	if err != nil {
+		slog.ErrorContext(ctx, "❌ Error leyendo entregas de webhooks pendientes", "error", err)
+		return
+	}
+	now := time.Now()
+	resumed := 0
+	for _, delivery := range pending {
+		if !s.track(delivery.ID) {
+			continue
+		}
+		resumed++
+		delay := time.Duration(0)
+		if delivery.NextAttemptAt != nil && delivery.NextAttemptAt.After(now) {
+			delay = delivery.NextAttemptAt.Sub(now)
+		}
+		s.schedule(webhookJob{delivery: delivery}, delay)
+	}
+	if resumed > 0 {
+		slog.InfoContext(ctx, "🪝 Entregas de webhooks retomadas", "deliveries", resumed)
+	}
+}
+
+// attempt hace un intento de entrega y programa el siguiente si falla
+func (s *WebhookService) attempt(job webhookJob) {
+	delivery := job.delivery
+	ctx, span := tracer.Start(context.Background(), "webhooks.deliver")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("webhook.id", delivery.WebhookID),
+		attribute.String("webhook.event", delivery.EventType),
+		attribute.Int("webhook.attempt", delivery.Attempts+1),
+	)
+
+	webhook := job.webhook
+	if webhook == nil {
+		var err error
+		webhook, err = s.store.Webhooks.Get(ctx, delivery.WebhookID)
+		if err != nil {
+			// Sin base de datos no se sabe a dónde enviar: se reintenta
+			// sin consumir un intento
+			slog.WarnContext(ctx, "⚠️ Error leyendo webhook, se reintenta", "webhook_id", delivery.WebhookID, "error", err)
+			s.schedule(webhookJob{delivery: delivery}, webhookBaseBackoff)
+			return
+		}
+		if webhook == nil {
+			// Webhook borrado: sus entregas se borraron con él
+			s.untrack(delivery.ID)
+			return
+		}
+	}
+
+	delivery.Attempts++
+	var status int
+	var err error
+	if !webhook.Active {
+		err = errors.New("webhook desactivado")
+	} else {
+		status, err = s.send(ctx, webhook, delivery)
+	}
+	s.recordResult(&delivery, status, err)
+
+	switch {
+	case err == nil:
+		s.untrack(delivery.ID)
+		telemetry.RecordWebhookDelivery(models.WebhookDeliveryDelivered)
+		slog.DebugContext(ctx, "🪝 Webhook entregado", "webhook_id", webhook.ID, "event_type", delivery.EventType, "attempts", delivery.Attempts)
+
+	case delivery.Attempts >= s.maxAttempts || !webhook.Active:
+		delivery.Status = models.WebhookDeliveryDead
+		s.untrack(delivery.ID)
+		telemetry.RecordWebhookDelivery(models.WebhookDeliveryDead)
+		s.store.Events.Record(ctx, s.serverID, s.serverIP, "webhook_dead_lettered", "warning",
+			fmt.Sprintf("Webhook %s sin entregar tras %d intentos", webhook.ID, delivery.Attempts),
+			map[string]interface{}{
+				"webhook_id":  webhook.ID,
+				"delivery_id": delivery.ID,
+				"event_type":  delivery.EventType,
+				"channel_id":  webhook.ChannelID,
+				"error":       err.Error(),
+			})
+
+	default:
+		backoff := webhookBackoff(delivery.Attempts, s.maxBackoff)
+		next := time.Now().UTC().Add(backoff)
+		delivery.NextAttemptAt = &next
+		telemetry.RecordWebhookDelivery("retry")
+		slog.WarnContext(ctx, "⏳ Error entregando webhook, reintento programado", "webhook_id", webhook.ID,
+			"event_type", delivery.EventType, "attempt", delivery.Attempts, "backoff", backoff.String(), "error", err)
+		s.schedule(webhookJob{delivery: delivery}, backoff)
+	}
+	s.store.Webhooks.SaveDelivery(ctx, delivery)
+}
+
+// recordResult guarda en la entrega el resultado de un intento
+func (s *WebhookService) recordResult(delivery *models.WebhookDelivery, status int, err error) {
+	delivery.ResponseStatus = nil
+	if status > 0 {
+		delivery.ResponseStatus = &status
+	}
+	delivery.NextAttemptAt = nil
+	if err != nil {
+		message := err.Error()
+		delivery.LastError = &message
+		return
+	}
+	now := time.Now().UTC()
+	delivery.Status = models.WebhookDeliveryDelivered
+	delivery.DeliveredAt = &now
+	delivery.LastError = nil
+}
+
+// send hace el POST firmado; cualquier respuesta fuera de 2xx es un error
+func (s *WebhookService) send(ctx context.Context, webhook *models.Webhook, delivery models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "srs-backend-webhooks")
+	req.Header.Set(WebhookHeaderEvent, delivery.EventType)
+	req.Header.Set(WebhookHeaderEventID, delivery.EventID)
+	req.Header.Set(WebhookHeaderDelivery, delivery.ID)
+	req.Header.Set(WebhookHeaderTimestamp, timestamp)
+	req.Header.Set(WebhookHeaderSignature, "v1="+SignWebhook(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookMaxResponse))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp.StatusCode, nil
+}
+
+// webhookBackoff devuelve la espera tras el intento attempts (1, 2...)
+func webhookBackoff(attempts int, maxBackoff time.Duration) time.Duration {
+	backoff := webhookBaseBackoff
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
+
+func validateWebhook(webhook *models.Webhook) error {
+	parsed, err := url.Parse(webhook.URL)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return fmt.Errorf("%w: url debe ser una URL http(s) absoluta", ErrInvalidWebhook)
+	}
+	if len(webhook.EventTypes) == 0 {
+		return fmt.Errorf("%w: event_types no puede estar vacío, usar %v", ErrInvalidWebhook, models.WebhookEventTypes)
+	}
+	for _, t := range webhook.EventTypes {
+		if !slices.Contains(models.WebhookEventTypes, t) {
+			return fmt.Errorf("%w: evento %q desconocido, usar %v", ErrInvalidWebhook, t, models.WebhookEventTypes)
+		}
+	}
+	slices.Sort(webhook.EventTypes)
+	webhook.EventTypes = slices.Compact(webhook.EventTypes)
+	if webhook.Secret != "" && len(webhook.Secret) < webhookMinSecret {
+		return fmt.Errorf("%w: secret debe tener al menos %d caracteres", ErrInvalidWebhook, webhookMinSecret)
+	}
+	return nil
+}
+
+// randomHex devuelve n bytes aleatorios en hexadecimal
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"srs-backend/internal/models"
+	"srs-backend/internal/storage"
+)
+
+func TestSignWebhook(t *testing.T) {
+	const secret = "whsec_0123456789abcdef"
+	body := []byte(`{"id":"evt_1"}`)
+	// Calculado aparte: HMAC-SHA256("1767268800.{"id":"evt_1"}")
+	const want = "63b5868731b101ffbf334a0e9c231cb98121d0635c956ac942762981a06560d1"
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      []byte
+		equal     bool
+	}{
+		{"misma entrada", secret, "1767268800", body, true},
+		{"otro secreto", secret + "x", "1767268800", body, false},
+		{"otro timestamp", secret, "1767268801", body, false},
+		{"otro cuerpo", secret, "1767268800", []byte(`{"id":"evt_2"}`), false},
+	}
+	for _, tt := range tests {
+		if got := SignWebhook(tt.secret, tt.timestamp, tt.body); (got == want) != tt.equal {
+			t.Errorf("%s: SignWebhook = %s, iguales = %v, want %v", tt.name, got, got == want, tt.equal)
+		}
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempts   int
+		maxBackoff time.Duration
+		want       time.Duration
+	}{
+		{1, time.Hour, 10 * time.Second},
+		{2, time.Hour, 20 * time.Second},
+		{3, time.Hour, 40 * time.Second},
+		{8, time.Hour, 1280 * time.Second},
+		{9, time.Hour, 2560 * time.Second},
+		{10, time.Hour, time.Hour},
+		{100, time.Hour, time.Hour},
+		{3, 30 * time.Second, 30 * time.Second},
+		{1, 5 * time.Second, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := webhookBackoff(tt.attempts, tt.maxBackoff); got != tt.want {
+			t.Errorf("webhookBackoff(%d, %s) = %s, want %s", tt.attempts, tt.maxBackoff, got, tt.want)
+		}
+	}
+}
+
+func TestValidateWebhook(t *testing.T) {
+	tests := []struct {
+		name    string
+		webhook models.Webhook
+		wantErr bool
+	}{
+		{"válido", models.Webhook{URL: "https://example.com/hook", EventTypes: []string{models.WebhookEventChannelLive}}, false},
+		{"http", models.Webhook{URL: "http://10.0.0.2:8080/hook", EventTypes: []string{models.WebhookEventSessionEnded}}, false},
+		{"secreto largo", models.Webhook{URL: "https://example.com", EventTypes: []string{models.WebhookEventChannelLive}, Secret: "0123456789abcdef"}, false},
+		{"URL relativa", models.Webhook{URL: "/hook", EventTypes: []string{models.WebhookEventChannelLive}}, true},
+		{"otro esquema", models.Webhook{URL: "ftp://example.com", EventTypes: []string{models.WebhookEventChannelLive}}, true},
+		{"sin eventos", models.Webhook{URL: "https://example.com"}, true},
+		{"evento desconocido", models.Webhook{URL: "https://example.com", EventTypes: []string{"channel.deleted"}}, true},
+		{"evento de prueba", models.Webhook{URL: "https://example.com", EventTypes: []string{models.WebhookEventTest}}, true},
+		{"secreto corto", models.Webhook{URL: "https://example.com", EventTypes: []string{models.WebhookEventChannelLive}, Secret: "corto"}, true},
+	}
+	for _, tt := range tests {
+		err := validateWebhook(&tt.webhook)
+		if tt.wantErr != (err != nil) || (err != nil && !errors.Is(err, ErrInvalidWebhook)) {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	// Los eventos se ordenan y se quitan duplicados
+	webhook := models.Webhook{URL: "https://example.com", EventTypes: []string{
+		models.WebhookEventSessionEnded, models.WebhookEventChannelLive, models.WebhookEventSessionEnded}}
+	if err := validateWebhook(&webhook); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(webhook.EventTypes, ","); got != "channel.live,session.ended" {
+		t.Errorf("event_types = %s, want channel.live,session.ended", got)
+	}
+}
+
+func TestWebhookAttempt(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		wantStatus string
+	}{
+		{"entregado", http.StatusNoContent, models.WebhookDeliveryDelivered},
+		{"error del receptor", http.StatusInternalServerError, models.WebhookDeliveryDead},
+		{"redirección", http.StatusFound, models.WebhookDeliveryDead},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotSignature, gotTimestamp string
+			var gotBody []byte
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotSignature = r.Header.Get(WebhookHeaderSignature)
+				gotTimestamp = r.Header.Get(WebhookHeaderTimestamp)
+				gotBody, _ = io.ReadAll(r.Body)
+				if tt.status == http.StatusFound {
+					w.Header().Set("Location", "https://example.com")
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer receiver.Close()
+
+			ctx := context.Background()
+			store := storage.NewStore(storage.NewMemoryBackend())
+			// Un intento: un fallo deja la entrega como dead sin reintentos
+			s := NewWebhookService(store, nil, "srv-test", "10.0.0.1", time.Second, 1, time.Minute, time.Minute)
+			webhook := &models.Webhook{ID: "wh1", URL: receiver.URL, Secret: "whsec_0123456789abcdef", Active: true}
+			delivery := models.WebhookDelivery{ID: "d1", WebhookID: "wh1", EventID: "evt_1", EventType: models.WebhookEventChannelLive,
+				Payload: []byte(`{"id":"evt_1"}`), Status: models.WebhookDeliveryPending}
+			s.attempt(webhookJob{delivery: delivery, webhook: webhook})
+
+			if want := "v1=" + SignWebhook(webhook.Secret, gotTimestamp, gotBody); gotSignature != want {
+				t.Errorf("firma %q, want %q", gotSignature, want)
+			}
+			deliveries, err := store.Webhooks.ListDeliveries(ctx, "wh1", "", 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(deliveries) != 1 {
+				t.Fatalf("%d entregas guardadas, want 1", len(deliveries))
+			}
+			got := deliveries[0]
+			if got.Status != tt.wantStatus || got.Attempts != 1 {
+				t.Errorf("entrega %s tras %d intentos, want %s tras 1", got.Status, got.Attempts, tt.wantStatus)
+			}
+			if got.ResponseStatus == nil || *got.ResponseStatus != tt.status {
+				t.Errorf("response_status = %v, want %d", got.ResponseStatus, tt.status)
+			}
+		})
+	}
+}
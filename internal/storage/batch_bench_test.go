@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+const (
+	benchStreams = 800
+	benchViewers = 800
+	benchBatch   = 500
+)
+
+// newBenchStore crea un store sobre el backend en memoria. buffered acumula
+// las sesiones como SetBatching, sin el ticker: se vacían con Flush.
+func newBenchStore(batchSize int, buffered bool) *Store {
+	store := NewStore(NewMemoryBackend())
+	store.db.batchSize = batchSize
+	store.sessions.buffered = buffered
+	return store
+}
+
+func benchStreamRows() []map[string]interface{} {
+	rows := make([]map[string]interface{}, benchStreams)
+	for i := range rows {
+		rows[i] = map[string]interface{}{
+			"server_id":   "bench",
+			"stream_name": fmt.Sprintf("stream-%d", i),
+			"clients":     i % 50,
+			"recv_kbps":   2500,
+			"send_kbps":   9000,
+		}
+	}
+	return rows
+}
+
+// BenchmarkSaveStreamMetrics es un ciclo del recolector con 800 streams:
+// fila a fila (batch=1, el comportamiento anterior) y en inserts de 500 filas
+func BenchmarkSaveStreamMetrics(b *testing.B) {
+	ctx := context.Background()
+	for _, batch := range []int{1, benchBatch} {
+		b.Run(fmt.Sprintf("batch=%d", batch), func(b *testing.B) {
+			var requests int64
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				store := newBenchStore(batch, false)
+				rows := benchStreamRows()
+				b.StartTimer()
+
+				if err := store.Metrics.SaveStreamMetrics(ctx, rows); err != nil {
+					b.Fatal(err)
+				}
+				requests += store.WriteStats().Requests
+			}
+			b.ReportMetric(float64(requests)/float64(b.N), "requests/op")
+		})
+	}
+}
+
+// BenchmarkSessionOpen es una ráfaga de 800 on_play simultáneos: cada
+// apertura en su propia escritura o acumuladas y vaciadas en lote
+func BenchmarkSessionOpen(b *testing.B) {
+	ctx := context.Background()
+	for _, buffered := range []bool{false, true} {
+		b.Run(fmt.Sprintf("buffered=%v", buffered), func(b *testing.B) {
+			var requests int64
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				store := newBenchStore(benchBatch, buffered)
+				b.StartTimer()
+
+				var wg sync.WaitGroup
+				for v := 0; v < benchViewers; v++ {
+					wg.Add(1)
+					go func(v int) {
+						defer wg.Done()
+						store.Sessions.Open(ctx, map[string]interface{}{
+							"server_id":   "bench",
+							"client_id":   fmt.Sprintf("client-%d", v),
+							"client_type": "play",
+							"stream_name": "stream-0",
+						})
+					}(v)
+				}
+				wg.Wait()
+				store.Flush()
+				requests += store.WriteStats().Requests
+			}
+			b.ReportMetric(float64(requests)/float64(b.N), "requests/op")
+		})
+	}
+}
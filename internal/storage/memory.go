@@ -0,0 +1,310 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryBackend guarda las tablas en memoria. Pensado para pruebas y
+// desarrollo local: no persiste nada y no valida el esquema.
+type MemoryBackend struct {
+	mu     sync.RWMutex
+	tables map[string][]map[string]interface{}
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{tables: make(map[string][]map[string]interface{})}
+}
+
+// Seed agrega filas sin pasar por las reglas de idempotencia
+func (b *MemoryBackend) Seed(table string, rows ...map[string]interface{}) error {
+	raw, err := json.Marshal(rows)
+	if err != nil {
+		return err
+	}
+	decoded, err := decodeRows(raw)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tables[table] = append(b.tables[table], decoded...)
+	return nil
+}
+
+func (b *MemoryBackend) Select(ctx context.Context, q Query, dest interface{}) error {
+	b.mu.RLock()
+	matches := b.match(q)
+	b.mu.RUnlock()
+
+	if q.OrderBy != "" {
+		sort.SliceStable(matches, func(i, j int) bool {
+			cmp := compareValues(matches[i][q.OrderBy], matches[j][q.OrderBy])
+			if q.Descending {
+				return cmp > 0
+			}
+			return cmp < 0
+		})
+	}
+	if q.Limit > 0 && len(matches) > q.Limit {
+		matches = matches[:q.Limit]
+	}
+
+	if q.Columns != "" && q.Columns != "*" {
+		columns := strings.Split(q.Columns, ",")
+		for i, row := range matches {
+			projected := make(map[string]interface{}, len(columns))
+			for _, column := range columns {
+				column = strings.TrimSpace(column)
+				projected[column] = row[column]
+			}
+			matches[i] = projected
+		}
+	}
+
+	raw, err := json.Marshal(matches)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, dest)
+}
+
+func (b *MemoryBackend) Count(ctx context.Context, q Query) (int64, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return int64(len(b.match(q))), nil
+}
+
+// Apply es atómico: si una escritura falla no se aplica ninguna
+func (b *MemoryBackend) Apply(ctx context.Context, mutations ...Mutation) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	staged := make(map[string][]map[string]interface{})
+	for _, m := range mutations {
+		rows, ok := staged[m.Table]
+		if !ok {
+			rows = append([]map[string]interface{}(nil), b.tables[m.Table]...)
+		}
+		rows, err := applyMemoryMutation(rows, m)
+		if err != nil {
+			return err
+		}
+		staged[m.Table] = rows
+	}
+	for table, rows := range staged {
+		b.tables[table] = rows
+	}
+	return nil
+}
+
+func (b *MemoryBackend) Close() error {
+	return nil
+}
+
+func (b *MemoryBackend) match(q Query) []map[string]interface{} {
+	var result []map[string]interface{}
+	for _, row := range b.tables[q.Table] {
+		if matchesFilters(row, q.Filters) {
+			result = append(result, row)
+		}
+	}
+	return result
+}
+
+// Las filas nunca se modifican en sitio: se reemplazan por copias para que
+// Apply pueda descartar los cambios ante un error.
+func applyMemoryMutation(rows []map[string]interface{}, m Mutation) ([]map[string]interface{}, error) {
+	values, err := decodeRows(m.Values)
+	if err != nil {
+		return nil, fmt.Errorf("decodificando valores de %s: %w", m.Table, err)
+	}
+
+	switch m.Kind {
+	case MutationInsert, MutationUpsert:
+		var conflict []string
+		if m.Kind == MutationInsert {
+			conflict = []string{"idempotency_key"}
+		} else {
+			for _, column := range strings.Split(m.OnConflict, ",") {
+				conflict = append(conflict, strings.TrimSpace(column))
+			}
+		}
+
+		for _, value := range values {
+			index := findConflict(rows, value, conflict)
+			switch {
+			case index < 0:
+				if _, ok := value["id"]; !ok {
+					value["id"] = newMutationID()
+				}
+				rows = append(rows, value)
+			case m.Kind == MutationUpsert:
+				rows[index] = mergeRow(rows[index], value)
+			}
+		}
+		return rows, nil
+
+	case MutationUpdate:
+		if len(values) != 1 {
+			return nil, fmt.Errorf("update en %s requiere un único objeto", m.Table)
+		}
+		filters := append(equalityFilters(m.Filters), m.Where...)
+		for i, row := range rows {
+			if matchesFilters(row, filters) {
+				rows[i] = mergeRow(row, values[0])
+			}
+		}
+		return rows, nil
+
+	case MutationDelete:
+		filters := append(equalityFilters(m.Filters), m.Where...)
+		kept := rows[:0:0]
+		for _, row := range rows {
+			if !matchesFilters(row, filters) {
+				kept = append(kept, row)
+			}
+		}
+		return kept, nil
+	}
+
+	return nil, fmt.Errorf("tipo de escritura desconocido: %s", m.Kind)
+}
+
+func findConflict(rows []map[string]interface{}, value map[string]interface{}, columns []string) int {
+	for _, column := range columns {
+		if _, ok := value[column]; !ok {
+			return -1
+		}
+	}
+	for i, row := range rows {
+		same := true
+		for _, column := range columns {
+			if compareValues(row[column], value[column]) != 0 {
+				same = false
+				break
+			}
+		}
+		if same {
+			return i
+		}
+	}
+	return -1
+}
+
+func mergeRow(row, values map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(row)+len(values))
+	for k, v := range row {
+		merged[k] = v
+	}
+	for k, v := range values {
+		merged[k] = v
+	}
+	return merged
+}
+
+func matchesFilters(row map[string]interface{}, filters []Filter) bool {
+	for _, f := range filters {
+		value, present := memoryValue(row, f.Column)
+		if f.Op == OpIs {
+			if f.Value == "null" {
+				if present && value != nil {
+					return false
+				}
+				continue
+			}
+			if !present || value == nil || memoryString(value) != f.Value {
+				return false
+			}
+			continue
+		}
+
+		if !present || value == nil {
+			return false
+		}
+		cmp := compareValues(value, f.Value)
+		var ok bool
+		switch f.Op {
+		case OpEq:
+			ok = cmp == 0
+		case OpNeq:
+			ok = cmp != 0
+		case OpGt:
+			ok = cmp > 0
+		case OpGte:
+			ok = cmp >= 0
+		case OpLt:
+			ok = cmp < 0
+		case OpLte:
+			ok = cmp <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// memoryValue resuelve también "columna->>clave" sobre columnas JSON
+func memoryValue(row map[string]interface{}, name string) (interface{}, bool) {
+	column, key, ok := strings.Cut(name, "->>")
+	if !ok {
+		value, present := row[name]
+		return value, present
+	}
+	object, isObject := row[column].(map[string]interface{})
+	if !isObject {
+		return nil, false
+	}
+	value, present := object[key]
+	return value, present
+}
+
+// compareValues compara como número, como fecha o como texto, en ese orden
+func compareValues(a, b interface{}) int {
+	as, bs := memoryString(a), memoryString(b)
+
+	if af, err := strconv.ParseFloat(as, 64); err == nil {
+		if bf, err := strconv.ParseFloat(bs, 64); err == nil {
+			switch {
+			case af < bf:
+				return -1
+			case af > bf:
+				return 1
+			}
+			return 0
+		}
+	}
+	if at, err := time.Parse(time.RFC3339Nano, as); err == nil {
+		if bt, err := time.Parse(time.RFC3339Nano, bs); err == nil {
+			switch {
+			case at.Before(bt):
+				return -1
+			case at.After(bt):
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(as, bs)
+}
+
+func memoryString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
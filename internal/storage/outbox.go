@@ -0,0 +1,403 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OutboxStatus resume el estado de la cola para el endpoint de estado.
+type OutboxStatus struct {
+	Depth                   int        `json:"depth"`
+	OldestPendingAt         *time.Time `json:"oldest_pending_at,omitempty"`
+	OldestPendingAgeSeconds float64    `json:"oldest_pending_age_seconds"`
+	Delivered               int64      `json:"delivered"`
+	DeadLettered            int64      `json:"dead_lettered"`
+	Attempts                int        `json:"current_attempts"`
+	LastError               string     `json:"last_error,omitempty"`
+	LastErrorAt             *time.Time `json:"last_error_at,omitempty"`
+	NextRetryAt             *time.Time `json:"next_retry_at,omitempty"`
+}
+
+type outboxRef struct {
+	end       int64
+	createdAt time.Time
+}
+
+// Outbox guarda las escrituras en un log append-only en disco y las entrega
+// en orden. Ante errores de red reintenta indefinidamente con backoff; una
+// escritura que la base de datos rechaza pasa a outbox.dead tras maxAttempts
+// para no bloquear a las que vienen detrás.
+type Outbox struct {
+	dir         string
+	exec        func(Mutation) error
+	maxAttempts int
+	maxBackoff  time.Duration
+
+	mu          sync.Mutex
+	file        *os.File
+	reader      *os.File
+	writeOffset int64
+	readOffset  int64
+	queue       []outboxRef
+	status      OutboxStatus
+	notify      chan struct{}
+}
+
+func NewOutbox(dir string, exec func(Mutation) error, maxAttempts int, maxBackoff time.Duration) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, "outbox.log"), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	reader, err := os.Open(file.Name())
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	o := &Outbox{
+		dir:         dir,
+		exec:        exec,
+		maxAttempts: maxAttempts,
+		maxBackoff:  maxBackoff,
+		file:        file,
+		reader:      reader,
+		writeOffset: info.Size(),
+		notify:      make(chan struct{}, 1),
+	}
+
+	if err := o.recover(); err != nil {
+		file.Close()
+		reader.Close()
+		return nil, err
+	}
+	if len(o.queue) > 0 {
+		slog.Info("📮 Outbox con escrituras pendientes de una ejecución anterior", "pending", len(o.queue))
+	} else if o.writeOffset > 0 {
+		if err := file.Truncate(0); err == nil {
+			o.writeOffset = 0
+			o.readOffset = 0
+			o.saveOffset()
+		}
+	}
+	return o, nil
+}
+
+// Enqueue persiste la escritura antes de devolver el control
+func (o *Outbox) Enqueue(m Mutation) error {
+	if m.ID == "" {
+		m.ID = newMutationID()
+	}
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = time.Now().UTC()
+	}
+
+	line, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	o.mu.Lock()
+	if _, err := o.file.Write(line); err != nil {
+		o.mu.Unlock()
+		return fmt.Errorf("escribiendo outbox: %w", err)
+	}
+	if err := o.file.Sync(); err != nil {
+		o.mu.Unlock()
+		return fmt.Errorf("sincronizando outbox: %w", err)
+	}
+	o.writeOffset += int64(len(line))
+	o.queue = append(o.queue, outboxRef{end: o.writeOffset, createdAt: m.CreatedAt})
+	o.mu.Unlock()
+
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Start entrega las escrituras pendientes en orden. Bloquea; usar con go.
+func (o *Outbox) Start() {
+	slog.Info("📮 Outbox iniciado", "dir", o.dir)
+
+	backoff := time.Second
+	for {
+		m, ok, err := o.peek()
+		if err != nil {
+			slog.Error("❌ Error leyendo outbox", "error", err)
+			time.Sleep(backoff)
+			continue
+		}
+		if !ok {
+			<-o.notify
+			continue
+		}
+
+		err = o.exec(m)
+		if err == nil {
+			o.advance(false)
+			backoff = time.Second
+			continue
+		}
+
+		o.mu.Lock()
+		now := time.Now().UTC()
+		o.status.Attempts++
+		o.status.LastError = err.Error()
+		o.status.LastErrorAt = &now
+		attempts := o.status.Attempts
+		o.mu.Unlock()
+
+		if isPermanentWriteError(err) && attempts >= o.maxAttempts {
+			slog.Error("☠️ Escritura descartada", "id", m.ID, "table", m.Table, "attempts", attempts, "error", err)
+			o.deadLetter(m, err)
+			o.advance(true)
+			backoff = time.Second
+			continue
+		}
+
+		retryAt := now.Add(backoff)
+		o.mu.Lock()
+		o.status.NextRetryAt = &retryAt
+		o.mu.Unlock()
+		slog.Warn("⏳ Error entregando escritura, reintento programado", "kind", m.Kind, "table", m.Table, "attempt", attempts, "backoff", backoff.String(), "error", err)
+
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > o.maxBackoff {
+			backoff = o.maxBackoff
+		}
+	}
+}
+
+// Status devuelve profundidad y antigüedad de la cola
+func (o *Outbox) Status() OutboxStatus {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	status := o.status
+	status.Depth = len(o.queue)
+	if len(o.queue) > 0 {
+		oldest := o.queue[0].createdAt
+		status.OldestPendingAt = &oldest
+		status.OldestPendingAgeSeconds = time.Since(oldest).Seconds()
+	}
+	return status
+}
+
+// Drain espera a que se entreguen las escrituras pendientes o a que venza
+// ctx. Lo que quede sigue en disco para la próxima ejecución.
+func (o *Outbox) Drain(ctx context.Context) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		o.mu.Lock()
+		depth := len(o.queue)
+		o.mu.Unlock()
+		if depth == 0 {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("%d escrituras pendientes: %w", depth, ctx.Err())
+		}
+	}
+}
+
+// peek lee la próxima escritura pendiente sin consumirla
+func (o *Outbox) peek() (Mutation, bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for len(o.queue) > 0 {
+		if _, err := o.reader.Seek(o.readOffset, io.SeekStart); err != nil {
+			return Mutation{}, false, err
+		}
+		line, err := bufio.NewReader(io.LimitReader(o.reader, o.queue[0].end-o.readOffset)).ReadBytes('\n')
+		if err != nil {
+			return Mutation{}, false, err
+		}
+
+		var m Mutation
+		if err := json.Unmarshal(line, &m); err != nil {
+			slog.Warn("⚠️ Entrada de outbox ilegible descartada", "error", err)
+			o.advanceLocked()
+			continue
+		}
+		return m, true, nil
+	}
+	return Mutation{}, false, nil
+}
+
+// advance marca como entregada la primera escritura de la cola
+func (o *Outbox) advance(dead bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if dead {
+		o.status.DeadLettered++
+	} else {
+		o.status.Delivered++
+	}
+	o.status.Attempts = 0
+	o.status.NextRetryAt = nil
+	o.advanceLocked()
+}
+
+func (o *Outbox) advanceLocked() {
+	if len(o.queue) == 0 {
+		return
+	}
+	o.readOffset = o.queue[0].end
+	o.queue = o.queue[1:]
+
+	// Cola vacía: se compacta el log para que no crezca indefinidamente
+	if len(o.queue) == 0 && o.readOffset > 0 {
+		if err := o.file.Truncate(0); err == nil {
+			o.writeOffset = 0
+			o.readOffset = 0
+		} else {
+			slog.Warn("⚠️ Error compactando outbox", "error", err)
+		}
+	}
+	o.saveOffset()
+}
+
+// saveOffset guarda la posición de lectura; perderla solo provoca reenvíos
+// idempotentes.
+func (o *Outbox) saveOffset() {
+	path := filepath.Join(o.dir, "outbox.offset")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(o.readOffset, 10)), 0o644); err != nil {
+		slog.Warn("⚠️ Error guardando offset del outbox", "error", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		slog.Warn("⚠️ Error guardando offset del outbox", "error", err)
+	}
+}
+
+// recover reconstruye la cola en memoria a partir del log y el offset
+func (o *Outbox) recover() error {
+	if data, err := os.ReadFile(filepath.Join(o.dir, "outbox.offset")); err == nil {
+		if offset, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil && offset <= o.writeOffset {
+			o.readOffset = offset
+		}
+	}
+
+	if _, err := o.reader.Seek(o.readOffset, io.SeekStart); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(o.reader)
+	offset := o.readOffset
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// Una línea sin salto final es una escritura incompleta
+			if len(line) > 0 {
+				slog.Warn("⚠️ Outbox: descartando escritura incompleta", "bytes", len(line))
+				if err := o.file.Truncate(offset); err != nil {
+					return err
+				}
+				o.writeOffset = offset
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		offset += int64(len(line))
+		var header struct {
+			CreatedAt time.Time `json:"created_at"`
+		}
+		json.Unmarshal(line, &header)
+		o.queue = append(o.queue, outboxRef{end: offset, createdAt: header.CreatedAt})
+	}
+}
+
+func (o *Outbox) deadLetter(m Mutation, cause error) {
+	entry := map[string]interface{}{
+		"mutation":  m,
+		"error":     cause.Error(),
+		"failed_at": time.Now().UTC(),
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	f, err := os.OpenFile(filepath.Join(o.dir, "outbox.dead"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		slog.Error("❌ Error abriendo outbox.dead", "error", err)
+		return
+	}
+	defer f.Close()
+	f.Write(append(line, '\n'))
+}
+
+// Los backends devuelven "(código) mensaje": un SQLSTATE, un código PGRST de
+// PostgREST o HTTP<status> si la respuesta no traía código. Un error sin
+// código es de red y siempre se reintenta.
+var writeErrorCode = regexp.MustCompile(`^\((\w+)\)`)
+
+// Clases de SQLSTATE que se resuelven reintentando: conexión (08), conflicto
+// de transacción (40), recursos (53), bloqueos (55), cancelaciones y
+// apagados (57), errores del sistema (58) e internos (XX)
+var transientSQLStateClasses = []string{"08", "40", "53", "55", "57", "58", "XX"}
+
+// isPermanentWriteError indica si la base de datos o PostgREST rechazaron la
+// escritura, de modo que reintentarla no cambiará el resultado
+func isPermanentWriteError(err error) bool {
+	match := writeErrorCode.FindStringSubmatch(err.Error())
+	if match == nil {
+		return false
+	}
+	code := match[1]
+	switch {
+	case strings.HasPrefix(code, "HTTP"):
+		status, _ := strconv.Atoi(strings.TrimPrefix(code, "HTTP"))
+		return status >= 400 && status < 500 && status != 408 && status != 429
+	case strings.HasPrefix(code, "PGRST"):
+		// PGRST000-PGRST003: PostgREST no llega a la base de datos o vence
+		// el timeout
+		return !strings.HasPrefix(code, "PGRST0")
+	default:
+		for _, class := range transientSQLStateClasses {
+			if strings.HasPrefix(code, class) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func newMutationID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,265 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIsPermanentWriteError(t *testing.T) {
+	tests := []struct {
+		err  string
+		want bool
+	}{
+		{"(23505) duplicate key value violates unique constraint", true},
+		{"(22P02) invalid input syntax for type uuid", true},
+		{"(42703) column \"live_viewers\" does not exist", true},
+		{"(P0001) trigger exception", true},
+		{"(PGRST204) Could not find the 'live_viewers' column", true},
+		{"(PGRST301) JWT expired", true},
+		{"(HTTP400) <html>bad request</html>", true},
+		{"(HTTP413) Request Entity Too Large", true},
+		{"(PGRST000) Could not connect with the database", false},
+		{"(PGRST003) Timed out acquiring connection from connection pool", false},
+		{"(HTTP408) Request Timeout", false},
+		{"(HTTP429) Too Many Requests", false},
+		{"(HTTP502) <html>Bad Gateway</html>", false},
+		{"(HTTP503) Service Unavailable", false},
+		{"(08006) connection failure", false},
+		{"(40P01) deadlock detected", false},
+		{"(53300) too many connections", false},
+		{"(55P03) lock not available", false},
+		{"(57014) canceling statement due to statement timeout", false},
+		{"(XX000) internal error", false},
+		{"dial tcp: lookup db.example.co: no such host", false},
+		{"context deadline exceeded", false},
+	}
+	for _, tt := range tests {
+		if got := isPermanentWriteError(errors.New(tt.err)); got != tt.want {
+			t.Errorf("isPermanentWriteError(%q) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// recorder guarda las escrituras entregadas; fail decide si una falla
+type recorder struct {
+	mu        sync.Mutex
+	delivered []string
+	fail      func(m Mutation) error
+}
+
+func (r *recorder) exec(m Mutation) error {
+	if r.fail != nil {
+		if err := r.fail(m); err != nil {
+			return err
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.delivered = append(r.delivered, m.ID)
+	return nil
+}
+
+func (r *recorder) ids() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.delivered...)
+}
+
+func enqueueN(t *testing.T, o *Outbox, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		if err := o.Enqueue(Mutation{ID: id, Kind: MutationInsert, Table: "t", Values: []byte(`{"a":1}`)}); err != nil {
+			t.Fatalf("Enqueue(%s): %v", id, err)
+		}
+	}
+}
+
+func drain(t *testing.T, o *Outbox) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := o.Drain(ctx); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+func TestOutboxReplayAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	down := errors.New("dial tcp: connection refused")
+
+	// Primera ejecución: la base de datos cae tras entregar "a"
+	first := &recorder{fail: func(m Mutation) error {
+		if m.ID != "a" {
+			return down
+		}
+		return nil
+	}}
+	o, err := NewOutbox(dir, first.exec, 3, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enqueueN(t, o, "a", "b", "c")
+	go o.Start()
+	for deadline := time.Now().Add(5 * time.Second); len(first.ids()) == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("no se entregó la primera escritura")
+		}
+	}
+
+	// Un corte a mitad de escritura deja una línea incompleta
+	f, err := os.OpenFile(filepath.Join(dir, "outbox.log"), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"id":"partial","kind":"ins`)
+	f.Close()
+
+	// Segunda ejecución: se retoman b y c en orden, sin reenviar a ni la
+	// línea incompleta
+	second := &recorder{}
+	o2, err := NewOutbox(dir, second.exec, 3, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if depth := o2.Status().Depth; depth != 2 {
+		t.Fatalf("pendientes tras reiniciar = %d, want 2", depth)
+	}
+	enqueueN(t, o2, "d")
+	go o2.Start()
+	drain(t, o2)
+
+	if got := fmt.Sprint(second.ids()); got != "[b c d]" {
+		t.Fatalf("entregadas %s, want [b c d]", got)
+	}
+	// Con la cola vacía el log se compacta
+	if size := fileSize(t, filepath.Join(dir, "outbox.log")); size != 0 {
+		t.Errorf("outbox.log de %d bytes tras vaciar la cola, want 0", size)
+	}
+	if status := o2.Status(); status.Delivered != 3 || status.Depth != 0 {
+		t.Errorf("Status() = %+v, want 3 entregadas y 0 pendientes", status)
+	}
+}
+
+func TestOutboxCompactsOnlyWhenEmpty(t *testing.T) {
+	dir := t.TempDir()
+	o, err := NewOutbox(dir, (&recorder{}).exec, 3, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enqueueN(t, o, "a", "b")
+	size := fileSize(t, filepath.Join(dir, "outbox.log"))
+
+	// Entregar una de dos avanza el offset sin truncar el log
+	if _, ok, err := o.peek(); !ok || err != nil {
+		t.Fatalf("peek: ok=%v err=%v", ok, err)
+	}
+	o.advance(false)
+	if got := fileSize(t, filepath.Join(dir, "outbox.log")); got != size {
+		t.Errorf("log de %d bytes con una escritura pendiente, want %d", got, size)
+	}
+	m, ok, err := o.peek()
+	if !ok || err != nil || m.ID != "b" {
+		t.Fatalf("peek = %q ok=%v err=%v, want b", m.ID, ok, err)
+	}
+	o.advance(false)
+	if got := fileSize(t, filepath.Join(dir, "outbox.log")); got != 0 {
+		t.Errorf("log de %d bytes con la cola vacía, want 0", got)
+	}
+}
+
+func TestOutboxDeadLettersRejectedWrites(t *testing.T) {
+	dir := t.TempDir()
+	rec := &recorder{fail: func(m Mutation) error {
+		if m.ID == "bad" {
+			return errors.New("(PGRST204) Could not find the 'live_viewers' column of 'channels_channel'")
+		}
+		return nil
+	}}
+	o, err := NewOutbox(dir, rec.exec, 2, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enqueueN(t, o, "a", "bad", "c")
+	go o.Start()
+	drain(t, o)
+
+	// La escritura rechazada no bloquea a las siguientes
+	if got := fmt.Sprint(rec.ids()); got != "[a c]" {
+		t.Fatalf("entregadas %s, want [a c]", got)
+	}
+	if status := o.Status(); status.DeadLettered != 1 {
+		t.Errorf("dead_lettered = %d, want 1", status.DeadLettered)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "outbox.dead"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	lines := 0
+	for scanner := bufio.NewScanner(f); scanner.Scan(); {
+		lines++
+	}
+	if lines != 1 {
+		t.Errorf("outbox.dead con %d líneas, want 1", lines)
+	}
+}
+
+func TestSupabaseErrorStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		wantErr   string
+		permanent bool
+	}{
+		{"error de PostgREST", 400, `{"code":"PGRST204","message":"Could not find the 'x' column"}`, "(PGRST204) Could not find the 'x' column", true},
+		{"SQLSTATE", 409, `{"code":"23505","message":"duplicate key"}`, "(23505) duplicate key", true},
+		{"4xx de un proxy", 413, `<html>Request Entity Too Large</html>`, "(HTTP413) <html>Request Entity Too Large</html>", true},
+		{"JSON sin código", 401, `{"message":"Invalid API key"}`, "(HTTP401) Invalid API key", true},
+		{"5xx de un proxy", 502, `<html>Bad Gateway</html>`, "(HTTP502) <html>Bad Gateway</html>", false},
+		{"cuerpo vacío", 503, ``, "(HTTP503) Service Unavailable", false},
+		{"rate limit", 429, `{"message":"rate limit"}`, "(HTTP429) rate limit", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			backend, err := NewSupabaseBackend(server.URL, "key")
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, _ := newMutation(MutationUpsert, "t", map[string]interface{}{"id": 1})
+			m.OnConflict = "id"
+			err = backend.Apply(context.Background(), m)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+			if got := isPermanentWriteError(err); got != tt.permanent {
+				t.Errorf("isPermanentWriteError = %v, want %v", got, tt.permanent)
+			}
+		})
+	}
+}
@@ -0,0 +1,425 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PostgresBackend habla directamente con PostgreSQL. Apply con varias
+// escrituras las ejecuta en una transacción.
+type PostgresBackend struct {
+	db *sql.DB
+}
+
+func NewPostgresBackend(dsn string) (*PostgresBackend, error) {
+	if dsn == "" {
+		return nil, errors.New("DATABASE_URL es obligatorio con STORAGE_BACKEND=postgres")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(10)
+	db.SetMaxIdleConns(5)
+	db.SetConnMaxIdleTime(5 * time.Minute)
+
+	// Sin conexión al arrancar se sigue: el outbox retiene las escrituras
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		slog.Warn("⚠️ PostgreSQL no responde al iniciar", "error", err)
+	}
+
+	return &PostgresBackend{db: db}, nil
+}
+
+// DB expone la conexión para las migraciones
+func (b *PostgresBackend) DB() *sql.DB {
+	return b.db
+}
+
+func (b *PostgresBackend) Select(ctx context.Context, q Query, dest interface{}) error {
+	columns := "*"
+	if q.Columns != "" && q.Columns != "*" {
+		names := strings.Split(q.Columns, ",")
+		for i, name := range names {
+			names[i] = pq.QuoteIdentifier(strings.TrimSpace(name))
+		}
+		columns = strings.Join(names, ", ")
+	}
+
+	where, args, err := buildWhere(q.Filters, 1)
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s%s", columns, pq.QuoteIdentifier(q.Table), where)
+	if q.OrderBy != "" {
+		query += " ORDER BY " + pq.QuoteIdentifier(q.OrderBy)
+		if q.Descending {
+			query += " DESC"
+		}
+	}
+	if q.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", q.Limit)
+	}
+
+	rows, err := b.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return wrapPQError(err)
+	}
+	defer rows.Close()
+
+	records, err := scanRows(rows)
+	if err != nil {
+		return wrapPQError(err)
+	}
+
+	// Se pasa por JSON para decodificar igual que las respuestas de PostgREST
+	raw, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, dest)
+}
+
+func (b *PostgresBackend) Count(ctx context.Context, q Query) (int64, error) {
+	where, args, err := buildWhere(q.Filters, 1)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	query := fmt.Sprintf("SELECT count(*) FROM %s%s", pq.QuoteIdentifier(q.Table), where)
+	if err := b.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, wrapPQError(err)
+	}
+	return count, nil
+}
+
+func (b *PostgresBackend) Apply(ctx context.Context, mutations ...Mutation) error {
+	if len(mutations) == 1 {
+		return b.exec(ctx, b.db, mutations[0])
+	}
+
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return wrapPQError(err)
+	}
+	for _, m := range mutations {
+		if err := b.exec(ctx, tx, m); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return wrapPQError(tx.Commit())
+}
+
+func (b *PostgresBackend) Close() error {
+	return b.db.Close()
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (b *PostgresBackend) exec(ctx context.Context, db execer, m Mutation) error {
+	query, args, err := buildMutation(m)
+	if err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, query, args...); err != nil {
+		return wrapPQError(err)
+	}
+	return nil
+}
+
+func buildMutation(m Mutation) (string, []interface{}, error) {
+	table := pq.QuoteIdentifier(m.Table)
+	rows, err := decodeRows(m.Values)
+	if err != nil {
+		return "", nil, fmt.Errorf("decodificando valores de %s: %w", m.Table, err)
+	}
+
+	switch m.Kind {
+	case MutationInsert, MutationUpsert:
+		if len(rows) == 0 {
+			return "", nil, fmt.Errorf("%s en %s sin filas", m.Kind, m.Table)
+		}
+		columns := rowColumns(rows)
+		query, args := buildInsert(table, columns, rows)
+
+		var conflict []string
+		if m.Kind == MutationInsert {
+			if containsString(columns, "idempotency_key") {
+				conflict = []string{"idempotency_key"}
+			}
+		} else {
+			for _, column := range strings.Split(m.OnConflict, ",") {
+				if column = strings.TrimSpace(column); column != "" {
+					conflict = append(conflict, column)
+				}
+			}
+		}
+		if len(conflict) == 0 {
+			return query, args, nil
+		}
+
+		quoted := make([]string, len(conflict))
+		for i, column := range conflict {
+			quoted[i] = pq.QuoteIdentifier(column)
+		}
+		query += " ON CONFLICT (" + strings.Join(quoted, ", ") + ")"
+
+		var sets []string
+		if m.Kind == MutationUpsert {
+			for _, column := range columns {
+				if !containsString(conflict, column) {
+					sets = append(sets, fmt.Sprintf("%s = EXCLUDED.%s", pq.QuoteIdentifier(column), pq.QuoteIdentifier(column)))
+				}
+			}
+		}
+		if len(sets) == 0 {
+			return query + " DO NOTHING", args, nil
+		}
+		return query + " DO UPDATE SET " + strings.Join(sets, ", "), args, nil
+
+	case MutationUpdate:
+		if len(rows) != 1 {
+			return "", nil, fmt.Errorf("update en %s requiere un único objeto", m.Table)
+		}
+		if len(m.Filters) == 0 && len(m.Where) == 0 {
+			return "", nil, fmt.Errorf("update en %s sin filtros", m.Table)
+		}
+		columns := rowColumns(rows)
+		sets := make([]string, len(columns))
+		args := make([]interface{}, 0, len(columns)+len(m.Filters))
+		for i, column := range columns {
+			args = append(args, pgValue(rows[0][column]))
+			sets[i] = fmt.Sprintf("%s = $%d", pq.QuoteIdentifier(column), len(args))
+		}
+		where, whereArgs, err := buildWhere(mutationFilters(m), len(args)+1)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("UPDATE %s SET %s%s", table, strings.Join(sets, ", "), where), append(args, whereArgs...), nil
+
+	case MutationDelete:
+		if len(m.Filters) == 0 && len(m.Where) == 0 {
+			return "", nil, fmt.Errorf("delete en %s sin filtros", m.Table)
+		}
+		where, args, err := buildWhere(mutationFilters(m), 1)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("DELETE FROM %s%s", table, where), args, nil
+	}
+
+	return "", nil, fmt.Errorf("tipo de escritura desconocido: %s", m.Kind)
+}
+
+// buildInsert genera un INSERT multi-fila; las columnas ausentes en una fila
+// toman su DEFAULT.
+func buildInsert(table string, columns []string, rows []map[string]interface{}) (string, []interface{}) {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = pq.QuoteIdentifier(column)
+	}
+
+	args := make([]interface{}, 0, len(rows)*len(columns))
+	tuples := make([]string, len(rows))
+	for i, row := range rows {
+		values := make([]string, len(columns))
+		for j, column := range columns {
+			value, ok := row[column]
+			if !ok {
+				values[j] = "DEFAULT"
+				continue
+			}
+			args = append(args, pgValue(value))
+			values[j] = fmt.Sprintf("$%d", len(args))
+		}
+		tuples[i] = "(" + strings.Join(values, ", ") + ")"
+	}
+
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", table, strings.Join(quoted, ", "), strings.Join(tuples, ", ")), args
+}
+
+func buildWhere(filters []Filter, firstArg int) (string, []interface{}, error) {
+	if len(filters) == 0 {
+		return "", nil, nil
+	}
+
+	conditions := make([]string, len(filters))
+	var args []interface{}
+	for i, f := range filters {
+		column := sqlColumn(f.Column)
+		if f.Op == OpIs {
+			switch f.Value {
+			case "null":
+				conditions[i] = column + " IS NULL"
+			case "true":
+				conditions[i] = column + " IS TRUE"
+			case "false":
+				conditions[i] = column + " IS FALSE"
+			default:
+				return "", nil, fmt.Errorf("valor no soportado para is: %q", f.Value)
+			}
+			continue
+		}
+
+		operator, ok := sqlOperators[f.Op]
+		if !ok {
+			return "", nil, fmt.Errorf("operador de filtro no soportado: %q", f.Op)
+		}
+		args = append(args, f.Value)
+		conditions[i] = fmt.Sprintf("%s %s $%d", column, operator, firstArg+len(args)-1)
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
+var sqlOperators = map[string]string{
+	OpEq:  "=",
+	OpNeq: "<>",
+	OpGt:  ">",
+	OpGte: ">=",
+	OpLt:  "<",
+	OpLte: "<=",
+}
+
+// equalityFilters ordena los filtros de una Mutation para generar SQL estable
+func equalityFilters(filters map[string]string) []Filter {
+	columns := make([]string, 0, len(filters))
+	for column := range filters {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	result := make([]Filter, len(columns))
+	for i, column := range columns {
+		result[i] = Eq(column, filters[column])
+	}
+	return result
+}
+
+// mutationFilters junta los filtros de igualdad y los de Where
+func mutationFilters(m Mutation) []Filter {
+	return append(equalityFilters(m.Filters), m.Where...)
+}
+
+// sqlColumn cita la columna; "metadata->>clave" se traduce al operador ->>
+// de JSONB con la clave como literal
+func sqlColumn(name string) string {
+	if column, key, ok := strings.Cut(name, "->>"); ok {
+		return pq.QuoteIdentifier(column) + "->>" + pq.QuoteLiteral(key)
+	}
+	return pq.QuoteIdentifier(name)
+}
+
+func rowColumns(rows []map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	var columns []string
+	for _, row := range rows {
+		for column := range row {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// pgValue adapta un valor decodificado de JSON a un parámetro: PostgreSQL
+// infiere el tipo de la columna, así que basta con texto para fechas y uuid,
+// y JSON serializado para columnas jsonb.
+func pgValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, string, bool:
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		return string(raw)
+	}
+}
+
+// scanRows convierte las filas en mapas listos para serializar como JSON
+func scanRows(rows *sql.Rows) ([]map[string]interface{}, error) {
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]map[string]interface{}, 0)
+	for rows.Next() {
+		values := make([]interface{}, len(columnTypes))
+		pointers := make([]interface{}, len(columnTypes))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		record := make(map[string]interface{}, len(columnTypes))
+		for i, columnType := range columnTypes {
+			record[columnType.Name()] = jsonValue(columnType.DatabaseTypeName(), values[i])
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+func jsonValue(databaseType string, value interface{}) interface{} {
+	if t, ok := value.(time.Time); ok && databaseType == "DATE" {
+		// Igual que PostgREST: las fechas sin hora se devuelven como YYYY-MM-DD
+		return t.Format("2006-01-02")
+	}
+
+	raw, ok := value.([]byte)
+	if !ok {
+		return value
+	}
+	switch databaseType {
+	case "JSON", "JSONB":
+		return json.RawMessage(raw)
+	case "NUMERIC":
+		return json.Number(raw)
+	default:
+		return string(raw)
+	}
+}
+
+// wrapPQError da a los errores de PostgreSQL el formato "(SQLSTATE) mensaje"
+// que usa PostgREST, para que el outbox los clasifique igual.
+func wrapPQError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return fmt.Errorf("(%s) %s", pqErr.Code, pqErr.Message)
+	}
+	return err
+}
+
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
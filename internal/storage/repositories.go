@@ -0,0 +1,1019 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"srs-backend/internal/logging"
+	"srs-backend/internal/models"
+)
+
+type channelRepository struct {
+	db *db
+}
+
+func (r *channelRepository) FindByStreamKey(ctx context.Context, streamKey string) (string, error) {
+	var results []struct {
+		ID string `json:"id"`
+	}
+	err := r.db.selectRows(ctx, Query{
+		Table:   "channels_channel",
+		Columns: "id",
+		Filters: []Filter{Eq("stream_id", streamKey)},
+	}, &results)
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return "", nil
+	}
+	return results[0].ID, nil
+}
+
+func (r *channelRepository) GetStreamKey(ctx context.Context, channelID string) (string, bool, error) {
+	var results []struct {
+		StreamID *string `json:"stream_id"`
+	}
+	err := r.db.selectRows(ctx, Query{
+		Table:   "channels_channel",
+		Columns: "stream_id",
+		Filters: []Filter{Eq("id", channelID)},
+	}, &results)
+	if err != nil {
+		return "", false, err
+	}
+	if len(results) == 0 {
+		return "", false, nil
+	}
+	if results[0].StreamID == nil {
+		return "", true, nil
+	}
+	return *results[0].StreamID, true, nil
+}
+
+// UpdateStreamKey escribe de forma directa: la API de claves necesita saber
+// si el cambio se aplicó.
+func (r *channelRepository) UpdateStreamKey(ctx context.Context, channelID, streamKey string) error {
+	m, err := newMutation(MutationUpdate, "channels_channel", map[string]interface{}{
+		"stream_id": streamKey,
+		"modified":  time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	m.Filters = map[string]string{"id": channelID}
+
+	if err := r.db.apply(ctx, m); err != nil {
+		slog.ErrorContext(ctx, "❌ Error actualizando clave del canal", "channel_id", channelID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// SetLive reinicia los viewers solo si el canal venía offline: una
+// reconexión dentro de la ventana de failover sigue la misma emisión.
+func (r *channelRepository) SetLive(ctx context.Context, channelID, cover string) error {
+	reset := map[string]interface{}{
+		"live_viewers": 0,
+		"peak_viewers": 0,
+	}
+	if err := r.db.queueUpdate(ctx, "channels_channel", reset, map[string]string{"id": channelID, "is_on_live": "false"}); err != nil {
+		slog.ErrorContext(ctx, "❌ Error reiniciando viewers del canal", "channel_id", channelID, "error", err)
+	}
+
+	updateData := map[string]interface{}{
+		"is_on_live":  true,
+		"last_status": "online",
+		"cover":       cover,
+		"modified":    time.Now().Format(time.RFC3339),
+	}
+
+	err := r.db.queueUpdate(ctx, "channels_channel", updateData, map[string]string{"id": channelID})
+	if err != nil {
+		slog.ErrorContext(ctx, "❌ Error marcando canal en vivo", "channel_id", channelID, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (r *channelRepository) SetOffline(ctx context.Context, channelID string) error {
+	return r.setOffline(ctx, map[string]string{"id": channelID})
+}
+
+// SetOfflineByStreamKey se usa cuando no se pudo resolver el canal
+func (r *channelRepository) SetOfflineByStreamKey(ctx context.Context, streamKey string) error {
+	return r.setOffline(ctx, map[string]string{"stream_id": streamKey})
+}
+
+// setOffline pone a cero live_viewers en una escritura aparte, como SetLive:
+// solo se aplica si el canal sigue offline cuando se procesa.
+func (r *channelRepository) setOffline(ctx context.Context, filters map[string]string) error {
+	updateData := map[string]interface{}{
+		"is_on_live": false,
+		"modified":   time.Now().Format(time.RFC3339),
+	}
+
+	err := r.db.queueUpdate(ctx, "channels_channel", updateData, filters)
+	if err != nil {
+		slog.ErrorContext(ctx, "❌ Error marcando canal offline", "channel_id", filters["id"], logging.KeyStreamHash, logging.StreamHash(filters["stream_id"]), "error", err)
+		return err
+	}
+
+	offline := map[string]string{"is_on_live": "false"}
+	for k, v := range filters {
+		offline[k] = v
+	}
+	if err := r.db.queueUpdate(ctx, "channels_channel", map[string]interface{}{"live_viewers": 0}, offline); err != nil {
+		slog.ErrorContext(ctx, "❌ Error reiniciando viewers del canal", "channel_id", filters["id"], logging.KeyStreamHash, logging.StreamHash(filters["stream_id"]), "error", err)
+	}
+	return nil
+}
+
+// SaveViewers escribe de forma directa: un recuento que no llega se
+// reintenta en el ciclo siguiente con el valor actualizado, sin reenviar
+// recuentos viejos desde el outbox.
+func (r *channelRepository) SaveViewers(ctx context.Context, serverID string, viewers map[string]int) error {
+	if len(viewers) == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	rows := make([]map[string]interface{}, 0, len(viewers))
+	for channelID, n := range viewers {
+		rows = append(rows, map[string]interface{}{
+			"channel_id": channelID,
+			"server_id":  serverID,
+			"viewers":    n,
+			"updated_at": now,
+		})
+	}
+	m, err := newMutation(MutationUpsert, "server_ingest_channel_viewers", rows)
+	if err != nil {
+		return err
+	}
+	m.OnConflict = "channel_id,server_id"
+	if err := r.db.apply(ctx, m); err != nil {
+		slog.ErrorContext(ctx, "❌ Error guardando server_ingest_channel_viewers", "channels", len(viewers), "error", err)
+		return err
+	}
+	return nil
+}
+
+// SetActiveIngest guarda qué fuente (primary/backup) se muestra al público
+func (r *channelRepository) SetActiveIngest(ctx context.Context, channelID, role string) error {
+	updateData := map[string]interface{}{
+		"active_ingest": role,
+		"modified":      time.Now().Format(time.RFC3339),
+	}
+
+	err := r.db.queueUpdate(ctx, "channels_channel", updateData, map[string]string{"id": channelID})
+	if err != nil {
+		slog.ErrorContext(ctx, "❌ Error actualizando active_ingest del canal", "channel_id", channelID, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (r *channelRepository) GetOrganization(ctx context.Context, channelID string) (string, error) {
+	var results []struct {
+		OrganizationID *string `json:"organization_id"`
+	}
+	err := r.db.selectRows(ctx, Query{
+		Table:   "channels_channel",
+		Columns: "organization_id",
+		Filters: []Filter{Eq("id", channelID)},
+	}, &results)
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 || results[0].OrganizationID == nil {
+		return "", nil
+	}
+	return *results[0].OrganizationID, nil
+}
+
+// CountLive cuenta los canales en vivo de la organización, excluyendo
+// opcionalmente un canal.
+func (r *channelRepository) CountLive(ctx context.Context, organizationID, excludeChannelID string) (int64, error) {
+	filters := []Filter{
+		Eq("organization_id", organizationID),
+		Eq("is_on_live", "true"),
+	}
+	if excludeChannelID != "" {
+		filters = append(filters, Neq("id", excludeChannelID))
+	}
+	return r.db.count(ctx, Query{Table: "channels_channel", Columns: "id", Filters: filters})
+}
+
+func (r *channelRepository) InsertGraceKey(ctx context.Context, channelID, streamKey string, expiresAt time.Time) error {
+	m, err := newMutation(MutationUpsert, "server_ingest_stream_key_grace", map[string]interface{}{
+		"channel_id": channelID,
+		"stream_key": streamKey,
+		"expires_at": expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+	m.OnConflict = "stream_key"
+
+	if err := r.db.apply(ctx, m); err != nil {
+		slog.ErrorContext(ctx, "❌ Error guardando server_ingest_stream_key_grace", "error", err)
+		return err
+	}
+	return nil
+}
+
+func (r *channelRepository) FindGraceKey(ctx context.Context, streamKey string) (*models.GraceKey, error) {
+	var results []models.GraceKey
+	err := r.db.selectRows(ctx, Query{
+		Table:   "server_ingest_stream_key_grace",
+		Columns: "channel_id,stream_key,expires_at",
+		Filters: []Filter{Eq("stream_key", streamKey)},
+	}, &results)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return &results[0], nil
+}
+
+func (r *channelRepository) ListGraceKeys(ctx context.Context, channelID string) ([]models.GraceKey, error) {
+	var results []models.GraceKey
+	err := r.db.selectRows(ctx, Query{
+		Table:   "server_ingest_stream_key_grace",
+		Columns: "channel_id,stream_key,expires_at",
+		Filters: []Filter{Eq("channel_id", channelID)},
+	}, &results)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r *channelRepository) DeleteGraceKeys(ctx context.Context, channelID, streamKey string) error {
+	filters := map[string]string{"channel_id": channelID}
+	if streamKey != "" {
+		filters["stream_key"] = streamKey
+	}
+
+	if err := r.db.apply(ctx, Mutation{Kind: MutationDelete, Table: "server_ingest_stream_key_grace", Filters: filters}); err != nil {
+		return fmt.Errorf("eliminando claves en gracia: %w", err)
+	}
+	return nil
+}
+
+// GetPolicy devuelve nil si el canal no tiene restricciones
+func (r *channelRepository) GetPolicy(ctx context.Context, channelID string) (*models.ChannelPolicy, error) {
+	var results []models.ChannelPolicy
+	err := r.db.selectRows(ctx, Query{
+		Table:   "server_ingest_channel_policies",
+		Columns: "channel_id,allowed_countries,blocked_countries,block_unknown,apply_to_publish,allowed_domains,empty_referrer,updated_at",
+		Filters: []Filter{Eq("channel_id", channelID)},
+	}, &results)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return &results[0], nil
+}
+
+// UpsertPolicy escribe de forma directa: la API necesita saber si se aplicó
+func (r *channelRepository) UpsertPolicy(ctx context.Context, policy models.ChannelPolicy) error {
+	m, err := newMutation(MutationUpsert, "server_ingest_channel_policies", policy)
+	if err != nil {
+		return err
+	}
+	m.OnConflict = "channel_id"
+
+	if err := r.db.apply(ctx, m); err != nil {
+		slog.ErrorContext(ctx, "❌ Error guardando server_ingest_channel_policies", "channel_id", policy.ChannelID, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (r *channelRepository) DeletePolicy(ctx context.Context, channelID string) error {
+	err := r.db.apply(ctx, Mutation{Kind: MutationDelete, Table: "server_ingest_channel_policies", Filters: map[string]string{"channel_id": channelID}})
+	if err != nil {
+		return fmt.Errorf("eliminando restricciones del canal: %w", err)
+	}
+	return nil
+}
+
+type serverRepository struct {
+	db *db
+}
+
+// Register escribe de forma directa para confirmar el registro al arrancar
+func (r *serverRepository) Register(ctx context.Context, serverID, serverIP string) error {
+	m, err := newMutation(MutationUpsert, "server_ingest_srs_servers", map[string]interface{}{
+		"server_id":   serverID,
+		"server_ip":   serverIP,
+		"server_name": "SRS Server " + serverID,
+		"is_active":   true,
+	})
+	if err != nil {
+		return err
+	}
+	m.OnConflict = "server_id"
+
+	if err := r.db.apply(ctx, m); err != nil {
+		slog.ErrorContext(ctx, "❌ Error registrando servidor", "error", err)
+		return err
+	}
+	return nil
+}
+
+// Heartbeat actualiza last_seen del servidor registrado
+func (r *serverRepository) Heartbeat(ctx context.Context, serverID, serverIP string) error {
+	updateData := map[string]interface{}{
+		"server_ip": serverIP,
+		"last_seen": time.Now().UTC(),
+		// Reactiva el servidor si mark_inactive_servers() lo marcó inactivo
+		"is_active": true,
+	}
+
+	err := r.db.queueUpdate(ctx, "server_ingest_srs_servers", updateData, map[string]string{"server_id": serverID})
+	if err != nil {
+		slog.ErrorContext(ctx, "❌ Error actualizando last_seen", "error", err)
+		return err
+	}
+	return nil
+}
+
+// MarkInactive escribe de forma directa al apagar: un heartbeat pendiente en
+// el outbox no debe reactivar el servidor después.
+func (r *serverRepository) MarkInactive(ctx context.Context, serverID string) error {
+	m, err := newMutation(MutationUpdate, "server_ingest_srs_servers", map[string]interface{}{
+		"is_active": false,
+		"last_seen": time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	m.Filters = map[string]string{"server_id": serverID}
+
+	if err := r.db.apply(ctx, m); err != nil {
+		slog.ErrorContext(ctx, "❌ Error marcando servidor inactivo", "error", err)
+		return err
+	}
+	return nil
+}
+
+type metricsRepository struct {
+	db *db
+}
+
+// SaveServerMetric hace upsert para no duplicar el minuto
+func (r *metricsRepository) SaveServerMetric(ctx context.Context, row map[string]interface{}) error {
+	return r.db.queueUpsert(ctx, "server_ingest_server_metrics", row, "server_id,minute_bucket")
+}
+
+func (r *metricsRepository) SaveStreamMetrics(ctx context.Context, rows []map[string]interface{}) error {
+	return r.db.queueInsertBatch(ctx, "server_ingest_stream_metrics", rows)
+}
+
+func (r *metricsRepository) SaveSystemMetrics(ctx context.Context, row map[string]interface{}) error {
+	return r.db.queueInsert(ctx, "server_ingest_system_metrics", row)
+}
+
+func (r *metricsRepository) ListStreamClients(ctx context.Context, streamName string, from, to time.Time, limit int) ([]models.StreamClientsSample, error) {
+	var results []models.StreamClientsSample
+	err := r.db.selectRows(ctx, Query{
+		Table:   "server_ingest_stream_metrics",
+		Columns: "server_id,stream_name,timestamp,clients",
+		Filters: []Filter{
+			Eq("stream_name", streamName),
+			Gte("timestamp", from.UTC().Format(time.RFC3339)),
+			Lt("timestamp", to.UTC().Format(time.RFC3339)),
+		},
+		OrderBy: "timestamp",
+		Limit:   limit,
+	}, &results)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+type sessionRepository struct {
+	db *db
+
+	mu       sync.Mutex
+	buffered bool
+	pending  []sessionWrite
+}
+
+// sessionWrite es una apertura (row) o un cierre (values/filters/where)
+// pendiente
+type sessionWrite struct {
+	ctx     context.Context
+	row     map[string]interface{}
+	values  map[string]interface{}
+	filters map[string]string
+	where   []Filter
+}
+
+func (r *sessionRepository) Open(ctx context.Context, row map[string]interface{}) error {
+	if ip, ok := row["client_ip"].(string); ok {
+		row["client_ip"] = r.db.clientIP(ip)
+	}
+	if !r.buffered {
+		return r.db.queueInsert(ctx, "server_ingest_client_connections", row)
+	}
+	return r.add(sessionWrite{ctx: ctx, row: row})
+}
+
+func (r *sessionRepository) Close(ctx context.Context, values map[string]interface{}, filters map[string]string) error {
+	return r.close(ctx, values, filters, nil)
+}
+
+func (r *sessionRepository) CloseOrphan(ctx context.Context, values map[string]interface{}, filters map[string]string) error {
+	return r.close(ctx, values, filters, []Filter{IsNull("disconnected_at")})
+}
+
+func (r *sessionRepository) close(ctx context.Context, values map[string]interface{}, filters map[string]string, where []Filter) error {
+	if ip, ok := filters["client_ip"]; ok {
+		filters["client_ip"] = r.db.clientIP(ip)
+	}
+	if !r.buffered {
+		return r.db.queueUpdate(ctx, "server_ingest_client_connections", values, filters, where...)
+	}
+	return r.add(sessionWrite{ctx: ctx, values: values, filters: filters, where: where})
+}
+
+func (r *sessionRepository) add(write sessionWrite) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pending = append(r.pending, write)
+	if r.db.batchSize > 0 && len(r.pending) >= r.db.batchSize {
+		return r.flushLocked()
+	}
+	return nil
+}
+
+func (r *sessionRepository) flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.flushLocked()
+}
+
+// flushLocked encola las escrituras en orden: las aperturas consecutivas van
+// en un insert en lote y cada cierre en su update, después de las aperturas
+// anteriores para que encuentre la fila. Cada lote es un span propio enlazado
+// a las trazas de sus on_play.
+func (r *sessionRepository) flushLocked() error {
+	pending := r.pending
+	r.pending = nil
+
+	var firstErr error
+	keep := func(err error) {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	var rows []map[string]interface{}
+	var links []trace.Link
+	insert := func() {
+		ctx, span := tracer.Start(context.Background(), "sessions.flush",
+			trace.WithLinks(links...),
+			trace.WithAttributes(attribute.Int("sessions.rows", len(rows))))
+		keep(r.db.queueInsertBatch(ctx, "server_ingest_client_connections", rows))
+		span.End()
+		rows, links = nil, nil
+	}
+
+	for _, write := range pending {
+		if write.row != nil {
+			rows = append(rows, write.row)
+			if link := trace.LinkFromContext(write.ctx); link.SpanContext.IsValid() {
+				links = append(links, link)
+			}
+			continue
+		}
+		if len(rows) > 0 {
+			insert()
+		}
+		keep(r.db.queueUpdate(write.ctx, "server_ingest_client_connections", write.values, write.filters, write.where...))
+	}
+	if len(rows) > 0 {
+		insert()
+	}
+	return firstErr
+}
+
+// CountOpenViewers cuenta las sesiones de reproducción abiertas de la
+// organización en todos los servidores. Las aperturas aún sin encolar por
+// SetBatching no se cuentan.
+func (r *sessionRepository) CountOpenViewers(ctx context.Context, organizationID string) (int64, error) {
+	return r.db.count(ctx, Query{
+		Table:   "server_ingest_client_connections",
+		Columns: "id",
+		Filters: []Filter{
+			Eq("organization_id", organizationID),
+			Eq("client_type", "play"),
+			IsNull("disconnected_at"),
+		},
+	})
+}
+
+// ListOpen no incluye las aperturas aún sin encolar por SetBatching
+func (r *sessionRepository) ListOpen(ctx context.Context, serverID string) ([]models.OpenSession, error) {
+	var results []models.OpenSession
+	err := r.db.selectRows(ctx, Query{
+		Table:   "server_ingest_client_connections",
+		Columns: "id,client_id,client_ip,client_type,stream_id,stream_name,app,connected_at",
+		Filters: []Filter{
+			Eq("server_id", serverID),
+			IsNull("disconnected_at"),
+		},
+	}, &results)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ListViews hace tres consultas porque los filtros solo se combinan con AND:
+// las sesiones iniciadas en la ventana, las anteriores cerradas dentro de
+// ella y las anteriores aún abiertas.
+func (r *sessionRepository) ListViews(ctx context.Context, column, value string, from, to time.Time, limit int) ([]models.ViewerSession, bool, error) {
+	base := []Filter{Eq(column, value), Eq("client_type", "play")}
+	fromValue := from.UTC().Format(time.RFC3339)
+	ranges := [][]Filter{
+		{Gte("connected_at", fromValue), Lt("connected_at", to.UTC().Format(time.RFC3339))},
+		{Lt("connected_at", fromValue), Gte("disconnected_at", fromValue)},
+		{Lt("connected_at", fromValue), IsNull("disconnected_at")},
+	}
+
+	var views []models.ViewerSession
+	truncated := false
+	for _, extra := range ranges {
+		var results []models.ViewerSession
+		err := r.db.selectRows(ctx, Query{
+			Table:   "server_ingest_client_connections",
+			Columns: "client_ip,stream_name,connected_at,disconnected_at,duration_seconds,country_code,asn,as_org,referrer_domain",
+			Filters: append(append([]Filter(nil), base...), extra...),
+			OrderBy: "connected_at",
+			Limit:   limit,
+		}, &results)
+		if err != nil {
+			return nil, false, err
+		}
+		if limit > 0 && len(results) >= limit {
+			truncated = true
+		}
+		views = append(views, results...)
+	}
+	return views, truncated, nil
+}
+
+type eventRepository struct {
+	db *db
+	// hook es nil salvo con Store.SetEventHook
+	hook func(eventType, severity, message string, metadata map[string]interface{})
+}
+
+// EventIPKeys son las claves de metadata que guardan IPs de clientes
+var EventIPKeys = []string{"client_ip", "current_ip", "incoming_ip"}
+
+func (r *eventRepository) Record(ctx context.Context, serverID, serverIP, eventType, severity, message string, metadata map[string]interface{}) error {
+	for _, key := range EventIPKeys {
+		if ip, ok := metadata[key].(string); ok {
+			metadata[key] = r.db.clientIP(ip)
+		}
+	}
+	if r.hook != nil {
+		r.hook(eventType, severity, message, metadata)
+	}
+	event := map[string]interface{}{
+		"server_id":  serverID,
+		"server_ip":  serverIP,
+		"event_type": eventType,
+		"severity":   severity,
+		"message":    message,
+		"metadata":   metadata,
+	}
+
+	if err := r.db.queueInsert(ctx, "server_ingest_system_events", event); err != nil {
+		slog.ErrorContext(ctx, "❌ Error guardando server_ingest_system_events", "event_type", eventType, "error", err)
+		return err
+	}
+	return nil
+}
+
+type tenantRepository struct {
+	db *db
+}
+
+func (r *tenantRepository) GetPlan(ctx context.Context, organizationID string) (*models.Plan, error) {
+	var orgs []struct {
+		PlanID *string `json:"plan_id"`
+	}
+	err := r.db.selectRows(ctx, Query{
+		Table:   "server_ingest_organizations",
+		Columns: "plan_id",
+		Filters: []Filter{Eq("id", organizationID)},
+	}, &orgs)
+	if err != nil {
+		return nil, err
+	}
+	if len(orgs) == 0 || orgs[0].PlanID == nil {
+		return nil, nil
+	}
+
+	var plans []models.Plan
+	err = r.db.selectRows(ctx, Query{
+		Table:   "server_ingest_plans",
+		Filters: []Filter{Eq("id", *orgs[0].PlanID)},
+	}, &plans)
+	if err != nil {
+		return nil, err
+	}
+	if len(plans) == 0 {
+		return nil, nil
+	}
+	return &plans[0], nil
+}
+
+// UpsertUsage guarda el acumulado mensual de este servidor
+func (r *tenantRepository) UpsertUsage(ctx context.Context, usage models.TenantUsage) error {
+	if err := r.db.queueUpsert(ctx, "server_ingest_tenant_usage", usage, "organization_id,month,server_id"); err != nil {
+		slog.ErrorContext(ctx, "❌ Error guardando server_ingest_tenant_usage", "error", err)
+		return err
+	}
+	return nil
+}
+
+// ListUsage devuelve el consumo del mes de una organización por servidor
+func (r *tenantRepository) ListUsage(ctx context.Context, organizationID, month string) ([]models.TenantUsage, error) {
+	var results []models.TenantUsage
+	err := r.db.selectRows(ctx, Query{
+		Table:   "server_ingest_tenant_usage",
+		Columns: "organization_id,month,server_id,stream_seconds,bandwidth_bytes",
+		Filters: []Filter{Eq("organization_id", organizationID), Eq("month", month)},
+	}, &results)
+	return results, err
+}
+
+type privacyRepository struct {
+	db *db
+}
+
+func (r *privacyRepository) Oldest(ctx context.Context, table string) (time.Time, bool, error) {
+	var results []struct {
+		Timestamp time.Time `json:"timestamp"`
+	}
+	err := r.db.selectRows(ctx, Query{
+		Table:   table,
+		Columns: "timestamp",
+		// El backend memory no rellena el DEFAULT de timestamp
+		Filters: []Filter{Gte("timestamp", time.Unix(0, 0).UTC().Format(time.RFC3339))},
+		OrderBy: "timestamp",
+		Limit:   1,
+	}, &results)
+	if err != nil || len(results) == 0 {
+		return time.Time{}, false, err
+	}
+	return results[0].Timestamp, true, nil
+}
+
+func (r *privacyRepository) DeleteBefore(ctx context.Context, table string, before time.Time) error {
+	err := r.db.apply(ctx, Mutation{
+		Kind:  MutationDelete,
+		Table: table,
+		Where: []Filter{Lt("timestamp", before.UTC().Format(time.RFC3339))},
+	})
+	if err != nil {
+		return fmt.Errorf("aplicando retención en %s: %w", table, err)
+	}
+	return nil
+}
+
+func (r *privacyRepository) EraseSessions(ctx context.Context, column, value string, anonymize bool) (int64, error) {
+	filters := []Filter{Eq(column, value)}
+	if anonymize {
+		// Las ya anonimizadas no se vuelven a contar
+		filters = append(filters, Neq("client_ip", ""))
+	}
+	return r.erase(ctx, "server_ingest_client_connections", filters, func() (Mutation, error) {
+		if !anonymize {
+			return Mutation{Kind: MutationDelete, Table: "server_ingest_client_connections", Where: filters}, nil
+		}
+		m, err := newMutation(MutationUpdate, "server_ingest_client_connections", map[string]interface{}{
+			"client_ip": nil,
+			"region":    nil,
+			"city":      nil,
+		})
+		m.Where = filters
+		return m, err
+	})
+}
+
+func (r *privacyRepository) DeleteEvents(ctx context.Context, key, value string) (int64, error) {
+	filters := []Filter{Eq("metadata->>"+key, value)}
+	return r.erase(ctx, "server_ingest_system_events", filters, func() (Mutation, error) {
+		return Mutation{Kind: MutationDelete, Table: "server_ingest_system_events", Where: filters}, nil
+	})
+}
+
+func (r *privacyRepository) DeleteStreamMetrics(ctx context.Context, streamName string) (int64, error) {
+	filters := []Filter{Eq("stream_name", streamName)}
+	return r.erase(ctx, "server_ingest_stream_metrics", filters, func() (Mutation, error) {
+		return Mutation{Kind: MutationDelete, Table: "server_ingest_stream_metrics", Where: filters}, nil
+	})
+}
+
+// erase cuenta las filas afectadas antes de aplicar la escritura: ningún
+// backend devuelve el número de filas de un update o delete.
+func (r *privacyRepository) erase(ctx context.Context, table string, filters []Filter, mutation func() (Mutation, error)) (int64, error) {
+	n, err := r.db.count(ctx, Query{Table: table, Columns: "id", Filters: filters})
+	if err != nil || n == 0 {
+		return 0, err
+	}
+	m, err := mutation()
+	if err != nil {
+		return 0, err
+	}
+	if err := r.db.apply(ctx, m); err != nil {
+		slog.ErrorContext(ctx, "❌ Error aplicando supresión", "table", table, "error", err)
+		return 0, err
+	}
+	return n, nil
+}
+
+type usageRepository struct {
+	db *db
+}
+
+func (r *usageRepository) ListSamples(ctx context.Context, from, to time.Time, limit int) ([]models.UsageSample, error) {
+	var results []models.UsageSample
+	err := r.db.selectRows(ctx, Query{
+		Table:   "server_ingest_stream_metrics",
+		Columns: "server_id,stream_name,channel_id,organization_id,timestamp,send_kbps,is_publishing",
+		Filters: []Filter{
+			Gte("timestamp", from.UTC().Format(time.RFC3339)),
+			Lt("timestamp", to.UTC().Format(time.RFC3339)),
+		},
+		OrderBy: "timestamp",
+		Limit:   limit,
+	}, &results)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r *usageRepository) ListSessions(ctx context.Context, from, to time.Time, limit int) ([]models.ViewerSession, error) {
+	return r.listSessions(ctx, []Filter{
+		Gte("connected_at", from.UTC().Format(time.RFC3339)),
+		Lt("connected_at", to.UTC().Format(time.RFC3339)),
+	}, limit)
+}
+
+// ListOngoing hace dos consultas, como ListViews: las cerradas después de at
+// y las aún abiertas
+func (r *usageRepository) ListOngoing(ctx context.Context, at time.Time, limit int) ([]models.ViewerSession, bool, error) {
+	atValue := at.UTC().Format(time.RFC3339)
+	var sessions []models.ViewerSession
+	truncated := false
+	for _, extra := range []Filter{Gte("disconnected_at", atValue), IsNull("disconnected_at")} {
+		results, err := r.listSessions(ctx, []Filter{Lt("connected_at", atValue), extra}, limit)
+		if err != nil {
+			return nil, false, err
+		}
+		if limit > 0 && len(results) >= limit {
+			truncated = true
+		}
+		sessions = append(sessions, results...)
+	}
+	return sessions, truncated, nil
+}
+
+func (r *usageRepository) listSessions(ctx context.Context, filters []Filter, limit int) ([]models.ViewerSession, error) {
+	var results []models.ViewerSession
+	err := r.db.selectRows(ctx, Query{
+		Table:   "server_ingest_client_connections",
+		Columns: "stream_name,channel_id,organization_id,connected_at,disconnected_at,duration_seconds",
+		Filters: append([]Filter{Eq("client_type", "play")}, filters...),
+		OrderBy: "connected_at",
+		Limit:   limit,
+	}, &results)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Replace escribe de forma directa, borrado y escritura en la misma
+// transacción: un día recalculado no deja filas de canales sin consumo.
+func (r *usageRepository) Replace(ctx context.Context, day string, rows []models.DailyUsage) error {
+	mutations := []Mutation{{
+		Kind:    MutationDelete,
+		Table:   "server_ingest_usage_daily",
+		Filters: map[string]string{"day": day},
+	}}
+	if len(rows) > 0 {
+		// organization_id es UUID: un canal sin organización se guarda NULL
+		values := make([]map[string]interface{}, len(rows))
+		for i, row := range rows {
+			values[i] = map[string]interface{}{
+				"day":             row.Day,
+				"scope":           row.Scope,
+				"channel_id":      row.ChannelID,
+				"organization_id": nil,
+				"ingest_seconds":  row.IngestSeconds,
+				"viewer_seconds":  row.ViewerSeconds,
+				"egress_bytes":    row.EgressBytes,
+				"peak_concurrent": row.PeakConcurrent,
+				"views":           row.Views,
+			}
+			if row.OrganizationID != "" {
+				values[i]["organization_id"] = row.OrganizationID
+			}
+		}
+		// Upsert y no insert: la tabla no tiene idempotency_key
+		m, err := newMutation(MutationUpsert, "server_ingest_usage_daily", values)
+		if err != nil {
+			return err
+		}
+		m.OnConflict = "day,scope,channel_id,organization_id"
+		mutations = append(mutations, m)
+	}
+	if err := r.db.apply(ctx, mutations...); err != nil {
+		slog.ErrorContext(ctx, "❌ Error guardando server_ingest_usage_daily", "day", day, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (r *usageRepository) List(ctx context.Context, day, scope, organizationID, channelID string, limit int) ([]models.DailyUsage, error) {
+	filters := []Filter{Eq("day", day), Eq("scope", scope)}
+	if organizationID != "" {
+		filters = append(filters, Eq("organization_id", organizationID))
+	}
+	if channelID != "" {
+		filters = append(filters, Eq("channel_id", channelID))
+	}
+
+	var results []models.DailyUsage
+	err := r.db.selectRows(ctx, Query{
+		Table:   "server_ingest_usage_daily",
+		Columns: "day,scope,channel_id,organization_id,ingest_seconds,viewer_seconds,egress_bytes,peak_concurrent,views",
+		Filters: filters,
+		Limit:   limit,
+	}, &results)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+type webhookRepository struct {
+	db *db
+}
+
+const webhookColumns = "id,organization_id,channel_id,url,secret,event_types,active,created_at,updated_at"
+
+func (r *webhookRepository) List(ctx context.Context, organizationID, channelID string) ([]models.Webhook, error) {
+	var filters []Filter
+	if organizationID != "" {
+		filters = append(filters, Eq("organization_id", organizationID))
+	}
+	if channelID != "" {
+		filters = append(filters, Eq("channel_id", channelID))
+	}
+
+	var results []models.Webhook
+	err := r.db.selectRows(ctx, Query{
+		Table:   "server_ingest_webhooks",
+		Columns: webhookColumns,
+		Filters: filters,
+		OrderBy: "created_at",
+	}, &results)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r *webhookRepository) Get(ctx context.Context, id string) (*models.Webhook, error) {
+	var results []models.Webhook
+	err := r.db.selectRows(ctx, Query{
+		Table:   "server_ingest_webhooks",
+		Columns: webhookColumns,
+		Filters: []Filter{Eq("id", id)},
+	}, &results)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return &results[0], nil
+}
+
+// Save escribe de forma directa: la API necesita saber si se aplicó
+func (r *webhookRepository) Save(ctx context.Context, webhook models.Webhook) error {
+	// organization_id es UUID: un webhook de canal se guarda NULL
+	values := map[string]interface{}{
+		"id":              webhook.ID,
+		"organization_id": nil,
+		"channel_id":      webhook.ChannelID,
+		"url":             webhook.URL,
+		"event_types":     webhook.EventTypes,
+		"active":          webhook.Active,
+		"created_at":      webhook.CreatedAt,
+		"updated_at":      webhook.UpdatedAt,
+	}
+	if webhook.OrganizationID != "" {
+		values["organization_id"] = webhook.OrganizationID
+	}
+	// Sin secreto se conserva el guardado
+	if webhook.Secret != "" {
+		values["secret"] = webhook.Secret
+	}
+	m, err := newMutation(MutationUpsert, "server_ingest_webhooks", values)
+	if err != nil {
+		return err
+	}
+	m.OnConflict = "id"
+
+	if err := r.db.apply(ctx, m); err != nil {
+		slog.ErrorContext(ctx, "❌ Error guardando server_ingest_webhooks", "webhook_id", webhook.ID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// Delete borra también las entregas, aunque el backend no tenga ON DELETE
+// CASCADE
+func (r *webhookRepository) Delete(ctx context.Context, id string) error {
+	err := r.db.apply(ctx,
+		Mutation{Kind: MutationDelete, Table: "server_ingest_webhook_deliveries", Filters: map[string]string{"webhook_id": id}},
+		Mutation{Kind: MutationDelete, Table: "server_ingest_webhooks", Filters: map[string]string{"id": id}},
+	)
+	if err != nil {
+		return fmt.Errorf("eliminando webhook: %w", err)
+	}
+	return nil
+}
+
+// SaveDelivery pasa por el outbox: el registro no debe frenar los envíos y
+// una entrega pendiente sobrevive a una caída de la base de datos
+func (r *webhookRepository) SaveDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	if err := r.db.queueUpsert(ctx, "server_ingest_webhook_deliveries", delivery, "id"); err != nil {
+		slog.ErrorContext(ctx, "❌ Error guardando server_ingest_webhook_deliveries", "delivery_id", delivery.ID, "error", err)
+		return err
+	}
+	return nil
+}
+
+const webhookDeliveryColumns = "id,webhook_id,event_id,event_type,payload,server_id,status,attempts,response_status,last_error,next_attempt_at,created_at,delivered_at"
+
+func (r *webhookRepository) ListDeliveries(ctx context.Context, webhookID, status string, limit int) ([]models.WebhookDelivery, error) {
+	filters := []Filter{Eq("webhook_id", webhookID)}
+	if status != "" {
+		filters = append(filters, Eq("status", status))
+	}
+
+	var results []models.WebhookDelivery
+	err := r.db.selectRows(ctx, Query{
+		Table:      "server_ingest_webhook_deliveries",
+		Columns:    webhookDeliveryColumns,
+		Filters:    filters,
+		OrderBy:    "created_at",
+		Descending: true,
+		Limit:      limit,
+	}, &results)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r *webhookRepository) ListPending(ctx context.Context, serverID string, limit int) ([]models.WebhookDelivery, error) {
+	var results []models.WebhookDelivery
+	err := r.db.selectRows(ctx, Query{
+		Table:   "server_ingest_webhook_deliveries",
+		Columns: webhookDeliveryColumns,
+		Filters: []Filter{Eq("server_id", serverID), Eq("status", models.WebhookDeliveryPending)},
+		OrderBy: "created_at",
+		Limit:   limit,
+	}, &results)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
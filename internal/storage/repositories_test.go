@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSessionCloseOrphan(t *testing.T) {
+	for _, buffered := range []bool{false, true} {
+		ctx := context.Background()
+		backend := NewMemoryBackend()
+		store := NewStore(backend)
+		store.sessions.buffered = buffered
+
+		connectedAt := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+		for _, clientID := range []string{"a", "b"} {
+			store.Sessions.Open(ctx, map[string]interface{}{
+				"server_id":    "srv-test",
+				"client_id":    clientID,
+				"client_type":  "play",
+				"connected_at": connectedAt,
+			})
+		}
+
+		// El on_stop de "a" se encola antes que el cierre del reconciliador
+		store.Sessions.Close(ctx, map[string]interface{}{
+			"disconnected_at":   connectedAt.Add(time.Minute),
+			"disconnect_reason": "client_stop",
+		}, map[string]string{"client_id": "a"})
+		for _, clientID := range []string{"a", "b"} {
+			store.Sessions.CloseOrphan(ctx, map[string]interface{}{
+				"disconnected_at":   connectedAt.Add(time.Hour),
+				"disconnect_reason": "orphaned",
+			}, map[string]string{"client_id": clientID})
+		}
+		if err := store.Drain(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		var rows []struct {
+			ClientID         string `json:"client_id"`
+			DisconnectReason string `json:"disconnect_reason"`
+		}
+		if err := backend.Select(ctx, Query{Table: "server_ingest_client_connections", OrderBy: "client_id"}, &rows); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]string{"a": "client_stop", "b": "orphaned"}
+		if len(rows) != len(want) {
+			t.Fatalf("buffered=%v: %d sesiones, want %d", buffered, len(rows), len(want))
+		}
+		for _, row := range rows {
+			if row.DisconnectReason != want[row.ClientID] {
+				t.Errorf("buffered=%v: sesión %s cerrada con %q, want %q", buffered, row.ClientID, row.DisconnectReason, want[row.ClientID])
+			}
+		}
+	}
+}
+
+func TestChannelSetOfflineResetsViewers(t *testing.T) {
+	tests := []struct {
+		name       string
+		setOffline func(ctx context.Context, store *Store) error
+	}{
+		{"por canal", func(ctx context.Context, store *Store) error {
+			return store.Channels.SetOffline(ctx, "ch1")
+		}},
+		{"por clave", func(ctx context.Context, store *Store) error {
+			return store.Channels.SetOfflineByStreamKey(ctx, "key1")
+		}},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		backend := NewMemoryBackend()
+		if err := backend.Seed("channels_channel",
+			map[string]interface{}{"id": "ch1", "stream_id": "key1", "is_on_live": true, "live_viewers": 40},
+			map[string]interface{}{"id": "ch2", "stream_id": "key2", "is_on_live": true, "live_viewers": 7},
+		); err != nil {
+			t.Fatal(err)
+		}
+		store := NewStore(backend)
+
+		if err := tt.setOffline(ctx, store); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Drain(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		var rows []struct {
+			ID          string `json:"id"`
+			IsOnLive    bool   `json:"is_on_live"`
+			LiveViewers int    `json:"live_viewers"`
+		}
+		if err := backend.Select(ctx, Query{Table: "channels_channel", OrderBy: "id"}, &rows); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]struct {
+			live    bool
+			viewers int
+		}{"ch1": {false, 0}, "ch2": {true, 7}}
+		for _, row := range rows {
+			if w := want[row.ID]; row.IsOnLive != w.live || row.LiveViewers != w.viewers {
+				t.Errorf("%s: %s is_on_live=%v viewers=%d, want %v y %d", tt.name, row.ID, row.IsOnLive, row.LiveViewers, w.live, w.viewers)
+			}
+		}
+	}
+}
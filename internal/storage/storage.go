@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Backends disponibles (STORAGE_BACKEND)
+const (
+	BackendSupabase = "supabase"
+	BackendPostgres = "postgres"
+	BackendMemory   = "memory"
+)
+
+// Tipos de escritura
+const (
+	MutationInsert = "insert"
+	MutationUpsert = "upsert"
+	MutationUpdate = "update"
+	MutationDelete = "delete"
+)
+
+// Operadores de filtro, con la semántica de PostgREST
+const (
+	OpEq  = "eq"
+	OpNeq = "neq"
+	OpGt  = "gt"
+	OpGte = "gte"
+	OpLt  = "lt"
+	OpLte = "lte"
+	// OpIs compara con null, true o false
+	OpIs = "is"
+)
+
+// Filter restringe una consulta por el valor de una columna. Column admite
+// "columna->>clave" para comparar una clave de una columna JSONB como texto.
+type Filter struct {
+	Column string `json:"column"`
+	Op     string `json:"op"`
+	Value  string `json:"value"`
+}
+
+func Eq(column, value string) Filter  { return Filter{Column: column, Op: OpEq, Value: value} }
+func Neq(column, value string) Filter { return Filter{Column: column, Op: OpNeq, Value: value} }
+func Gte(column, value string) Filter { return Filter{Column: column, Op: OpGte, Value: value} }
+func Lt(column, value string) Filter  { return Filter{Column: column, Op: OpLt, Value: value} }
+func IsNull(column string) Filter     { return Filter{Column: column, Op: OpIs, Value: "null"} }
+
+// Query es una lectura de una tabla o vista. Columns usa la sintaxis de
+// PostgREST ("a,b"); vacío equivale a "*".
+type Query struct {
+	Table      string
+	Columns    string
+	Filters    []Filter
+	OrderBy    string
+	Descending bool
+	Limit      int
+}
+
+// Mutation es una escritura contra la base de datos. ID actúa como clave de
+// idempotencia: los inserts se aplican como upsert sobre la columna
+// idempotency_key para que un reintento no duplique filas. Values puede ser
+// un objeto o un array de objetos.
+type Mutation struct {
+	ID         string            `json:"id"`
+	Kind       string            `json:"kind"`
+	Table      string            `json:"table"`
+	Values     json.RawMessage   `json:"values,omitempty"`
+	OnConflict string            `json:"on_conflict,omitempty"`
+	Filters    map[string]string `json:"filters,omitempty"`
+	// Where agrega a Filters condiciones con operador en updates y deletes
+	Where     []Filter  `json:"where,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// TraceParent (W3C) de la operación que encoló la escritura
+	TraceParent string `json:"traceparent,omitempty"`
+}
+
+// Backend es el acceso a la base de datos usado por los repositorios.
+type Backend interface {
+	// Select decodifica las filas en dest (puntero a slice) como JSON
+	Select(ctx context.Context, q Query, dest interface{}) error
+	Count(ctx context.Context, q Query) (int64, error)
+	// Apply ejecuta las escrituras en orden; en una transacción si el
+	// backend lo permite.
+	Apply(ctx context.Context, mutations ...Mutation) error
+	Close() error
+}
+
+// Options reúne la configuración de todos los backends.
+type Options struct {
+	SupabaseURL string
+	SupabaseKey string
+	DatabaseURL string
+}
+
+// Open crea el backend indicado por kind
+func Open(kind string, opts Options) (Backend, error) {
+	switch kind {
+	case BackendSupabase, "":
+		return NewSupabaseBackend(opts.SupabaseURL, opts.SupabaseKey)
+	case BackendPostgres:
+		return NewPostgresBackend(opts.DatabaseURL)
+	case BackendMemory:
+		return NewMemoryBackend(), nil
+	default:
+		return nil, fmt.Errorf("STORAGE_BACKEND desconocido: %q", kind)
+	}
+}
+
+// backendSystem nombra el backend en los spans (db.system)
+func backendSystem(b Backend) string {
+	switch b.(type) {
+	case *SupabaseBackend:
+		return BackendSupabase
+	case *PostgresBackend:
+		return "postgresql"
+	case *MemoryBackend:
+		return BackendMemory
+	default:
+		return "other"
+	}
+}
+
+func newMutation(kind, table string, values interface{}) (Mutation, error) {
+	m := Mutation{Kind: kind, Table: table}
+	if values != nil {
+		raw, err := json.Marshal(values)
+		if err != nil {
+			return m, err
+		}
+		m.Values = raw
+	}
+	return m, nil
+}
+
+// decodeRows devuelve las filas de Values, sea un objeto o un array. Los
+// números se conservan como json.Number para no perder precisión.
+func decodeRows(values json.RawMessage) ([]map[string]interface{}, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(values))
+	decoder.UseNumber()
+
+	if values[0] == '[' {
+		var rows []map[string]interface{}
+		if err := decoder.Decode(&rows); err != nil {
+			return nil, err
+		}
+		return rows, nil
+	}
+
+	var row map[string]interface{}
+	if err := decoder.Decode(&row); err != nil {
+		return nil, err
+	}
+	return []map[string]interface{}{row}, nil
+}
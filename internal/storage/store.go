@@ -0,0 +1,466 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"srs-backend/internal/models"
+)
+
+// Tiempo máximo de una consulta o escritura directa
+const queryTimeout = 10 * time.Second
+
+var tracer = otel.Tracer("srs-backend/storage")
+
+// ChannelRepository accede a channels_channel y a las claves en gracia.
+type ChannelRepository interface {
+	// FindByStreamKey devuelve "" sin error cuando la clave no existe
+	FindByStreamKey(ctx context.Context, streamKey string) (string, error)
+	// GetStreamKey devuelve la clave actual e indica si el canal existe
+	GetStreamKey(ctx context.Context, channelID string) (string, bool, error)
+	UpdateStreamKey(ctx context.Context, channelID, streamKey string) error
+	SetLive(ctx context.Context, channelID, cover string) error
+	SetOffline(ctx context.Context, channelID string) error
+	SetOfflineByStreamKey(ctx context.Context, streamKey string) error
+	SetActiveIngest(ctx context.Context, channelID, role string) error
+	// GetOrganization devuelve "" si el canal no tiene organización
+	GetOrganization(ctx context.Context, channelID string) (string, error)
+	CountLive(ctx context.Context, organizationID, excludeChannelID string) (int64, error)
+	// SaveViewers guarda los viewers de este servidor por canal en un único
+	// upsert; un trigger los suma en channels_channel.live_viewers
+	SaveViewers(ctx context.Context, serverID string, viewers map[string]int) error
+
+	InsertGraceKey(ctx context.Context, channelID, streamKey string, expiresAt time.Time) error
+	// FindGraceKey devuelve nil si la clave no está en gracia
+	FindGraceKey(ctx context.Context, streamKey string) (*models.GraceKey, error)
+	ListGraceKeys(ctx context.Context, channelID string) ([]models.GraceKey, error)
+	// DeleteGraceKeys elimina una clave, o todas las del canal si streamKey es ""
+	DeleteGraceKeys(ctx context.Context, channelID, streamKey string) error
+
+	// GetPolicy devuelve nil si el canal no tiene restricciones
+	GetPolicy(ctx context.Context, channelID string) (*models.ChannelPolicy, error)
+	UpsertPolicy(ctx context.Context, policy models.ChannelPolicy) error
+	DeletePolicy(ctx context.Context, channelID string) error
+}
+
+// ServerRepository accede a server_ingest_srs_servers.
+type ServerRepository interface {
+	Register(ctx context.Context, serverID, serverIP string) error
+	Heartbeat(ctx context.Context, serverID, serverIP string) error
+	MarkInactive(ctx context.Context, serverID string) error
+}
+
+// MetricsRepository guarda las métricas del recolector.
+type MetricsRepository interface {
+	SaveServerMetric(ctx context.Context, row map[string]interface{}) error
+	// SaveStreamMetrics guarda las métricas de todos los streams de un ciclo
+	// en inserts de hasta METRICS_BATCH_SIZE filas
+	SaveStreamMetrics(ctx context.Context, rows []map[string]interface{}) error
+	SaveSystemMetrics(ctx context.Context, row map[string]interface{}) error
+	// ListStreamClients devuelve las muestras del stream en [from, to)
+	ListStreamClients(ctx context.Context, streamName string, from, to time.Time, limit int) ([]models.StreamClientsSample, error)
+}
+
+// SessionRepository accede a server_ingest_client_connections. Con
+// SetBatching las aperturas y cierres se acumulan y se encolan en lote.
+type SessionRepository interface {
+	Open(ctx context.Context, row map[string]interface{}) error
+	Close(ctx context.Context, values map[string]interface{}, filters map[string]string) error
+	// CloseOrphan cierra como Close pero solo las filas aún abiertas: un
+	// on_stop encolado antes no se sobrescribe
+	CloseOrphan(ctx context.Context, values map[string]interface{}, filters map[string]string) error
+	CountOpenViewers(ctx context.Context, organizationID string) (int64, error)
+	// ListOpen devuelve las sesiones sin disconnected_at del servidor
+	ListOpen(ctx context.Context, serverID string) ([]models.OpenSession, error)
+	// ListViews devuelve las sesiones de reproducción con column = value que
+	// se solapan con [from, to), hasta limit filas por consulta. Indica si
+	// alguna consulta llegó al límite.
+	ListViews(ctx context.Context, column, value string, from, to time.Time, limit int) ([]models.ViewerSession, bool, error)
+}
+
+// EventRepository accede a server_ingest_system_events.
+type EventRepository interface {
+	Record(ctx context.Context, serverID, serverIP, eventType, severity, message string, metadata map[string]interface{}) error
+}
+
+// PrivacyRepository borra o anonimiza datos personales: la retención por
+// antigüedad y las solicitudes de supresión. Escribe de forma directa para
+// que quien llama sepa si se aplicó.
+type PrivacyRepository interface {
+	// Oldest devuelve el timestamp de la fila más antigua de table; false si
+	// la tabla está vacía
+	Oldest(ctx context.Context, table string) (time.Time, bool, error)
+	// DeleteBefore borra las filas de table con timestamp anterior a before
+	DeleteBefore(ctx context.Context, table string, before time.Time) error
+	// EraseSessions borra las sesiones con column = value o, con anonymize,
+	// quita su IP, región y ciudad. Devuelve las filas afectadas.
+	EraseSessions(ctx context.Context, column, value string, anonymize bool) (int64, error)
+	// DeleteEvents borra los eventos cuyo metadata tiene key = value
+	DeleteEvents(ctx context.Context, key, value string) (int64, error)
+	DeleteStreamMetrics(ctx context.Context, streamName string) (int64, error)
+}
+
+// TenantRepository accede a organizaciones, planes y consumo mensual.
+type TenantRepository interface {
+	// GetPlan devuelve nil si la organización no tiene plan
+	GetPlan(ctx context.Context, organizationID string) (*models.Plan, error)
+	UpsertUsage(ctx context.Context, usage models.TenantUsage) error
+	ListUsage(ctx context.Context, organizationID, month string) ([]models.TenantUsage, error)
+}
+
+// UsageRepository lee las muestras y sesiones de las que sale el consumo
+// diario y guarda el resultado en server_ingest_usage_daily.
+type UsageRepository interface {
+	// ListSamples devuelve las muestras del recolector en [from, to)
+	ListSamples(ctx context.Context, from, to time.Time, limit int) ([]models.UsageSample, error)
+	// ListSessions devuelve las sesiones de reproducción iniciadas en [from, to)
+	ListSessions(ctx context.Context, from, to time.Time, limit int) ([]models.ViewerSession, error)
+	// ListOngoing devuelve las sesiones de reproducción iniciadas antes de at
+	// que seguían abiertas en at. Indica si alguna consulta llegó al límite.
+	ListOngoing(ctx context.Context, at time.Time, limit int) ([]models.ViewerSession, bool, error)
+	// Replace sustituye todas las filas de day por rows
+	Replace(ctx context.Context, day string, rows []models.DailyUsage) error
+	// List devuelve las filas de day con el alcance indicado; organizationID
+	// y channelID filtran si no están vacíos
+	List(ctx context.Context, day, scope, organizationID, channelID string, limit int) ([]models.DailyUsage, error)
+}
+
+// WebhookRepository accede a los webhooks salientes y a su registro de
+// entregas.
+type WebhookRepository interface {
+	// List devuelve los webhooks; organizationID y channelID filtran si no
+	// están vacíos
+	List(ctx context.Context, organizationID, channelID string) ([]models.Webhook, error)
+	// Get devuelve nil si el webhook no existe
+	Get(ctx context.Context, id string) (*models.Webhook, error)
+	Save(ctx context.Context, webhook models.Webhook) error
+	// Delete borra el webhook y su registro de entregas
+	Delete(ctx context.Context, id string) error
+	// SaveDelivery encola el estado de la entrega; cada cambio reemplaza la fila
+	SaveDelivery(ctx context.Context, delivery models.WebhookDelivery) error
+	// ListDeliveries devuelve las entregas del webhook, las más recientes
+	// primero; status filtra si no está vacío
+	ListDeliveries(ctx context.Context, webhookID, status string, limit int) ([]models.WebhookDelivery, error)
+	// ListPending devuelve las entregas pendientes de serverID
+	ListPending(ctx context.Context, serverID string, limit int) ([]models.WebhookDelivery, error)
+}
+
+// Store agrupa los repositorios sobre un mismo backend.
+type Store struct {
+	Channels ChannelRepository
+	Servers  ServerRepository
+	Metrics  MetricsRepository
+	Sessions SessionRepository
+	Events   EventRepository
+	Tenants  TenantRepository
+	Privacy  PrivacyRepository
+	Usage    UsageRepository
+	Webhooks WebhookRepository
+
+	db       *db
+	sessions *sessionRepository
+	events   *eventRepository
+}
+
+func NewStore(backend Backend) *Store {
+	d := &db{backend: backend}
+	sessions := &sessionRepository{db: d}
+	events := &eventRepository{db: d}
+	d.system = backendSystem(backend)
+	return &Store{
+		Channels: &channelRepository{db: d},
+		Servers:  &serverRepository{db: d},
+		Metrics:  &metricsRepository{db: d},
+		Sessions: sessions,
+		Events:   events,
+		Tenants:  &tenantRepository{db: d},
+		Privacy:  &privacyRepository{db: d},
+		Usage:    &usageRepository{db: d},
+		Webhooks: &webhookRepository{db: d},
+		db:       d,
+		sessions: sessions,
+		events:   events,
+	}
+}
+
+// SetOutbox hace que las escrituras diferibles pasen por el outbox en disco.
+// Debe llamarse antes de usar los repositorios.
+func (s *Store) SetOutbox(outbox *Outbox) {
+	s.db.outbox = outbox
+}
+
+// SetBatching fija el tamaño máximo de los inserts en lote. Con flushInterval
+// mayor que cero las escrituras de sesiones se acumulan y se encolan cada
+// flushInterval o al llegar a size. Debe llamarse antes de usar los
+// repositorios.
+func (s *Store) SetBatching(size int, flushInterval time.Duration) {
+	s.db.batchSize = size
+	if flushInterval <= 0 {
+		return
+	}
+	s.sessions.buffered = true
+	go func() {
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.Flush()
+		}
+	}()
+}
+
+// SetIPAnonymizer transforma las IPs de clientes antes de guardarlas en
+// sesiones y eventos. Debe llamarse antes de usar los repositorios.
+func (s *Store) SetIPAnonymizer(anonymize func(ip string) string) {
+	s.db.anonymizeIP = anonymize
+}
+
+// SetEventHook recibe cada evento de server_ingest_system_events al
+// registrarlo, con las IPs ya anonimizadas y aunque la escritura falle.
+// Debe llamarse antes de usar los repositorios.
+func (s *Store) SetEventHook(hook func(eventType, severity, message string, metadata map[string]interface{})) {
+	s.events.hook = hook
+}
+
+// ClientIP devuelve la IP como se guarda, para los textos que no pasan por
+// los repositorios
+func (s *Store) ClientIP(ip string) string {
+	return s.db.clientIP(ip)
+}
+
+// Flush encola las escrituras de sesiones acumuladas
+func (s *Store) Flush() {
+	if err := s.sessions.flush(); err != nil {
+		slog.Error("❌ Error encolando sesiones en lote", "error", err)
+	}
+}
+
+// Drain encola las sesiones acumuladas y espera a que el outbox entregue las
+// escrituras pendientes o a que venza ctx
+func (s *Store) Drain(ctx context.Context) error {
+	s.Flush()
+	if s.db.outbox == nil {
+		return nil
+	}
+	return s.db.outbox.Drain(ctx)
+}
+
+// WriteStats devuelve el acumulado de escrituras ejecutadas contra el backend
+func (s *Store) WriteStats() WriteStats {
+	return s.db.stats.snapshot()
+}
+
+// Deliver ejecuta una escritura del outbox contra el backend, dentro de la
+// traza que la originó
+func (s *Store) Deliver(m Mutation) error {
+	ctx := context.Background()
+	if m.TraceParent != "" {
+		ctx = propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{"traceparent": m.TraceParent})
+	}
+	return s.db.apply(ctx, m)
+}
+
+func (s *Store) Backend() Backend {
+	return s.db.backend
+}
+
+// db concentra el acceso al backend compartido por los repositorios
+type db struct {
+	backend   Backend
+	system    string
+	outbox    *Outbox
+	batchSize int
+	stats     writeStats
+	// anonymizeIP es nil si las IPs se guardan tal cual
+	anonymizeIP func(ip string) string
+}
+
+// clientIP aplica SetIPAnonymizer; "" se mantiene vacío
+func (d *db) clientIP(ip string) string {
+	if d.anonymizeIP == nil || ip == "" {
+		return ip
+	}
+	return d.anonymizeIP(ip)
+}
+
+func (d *db) selectRows(ctx context.Context, q Query, dest interface{}) error {
+	ctx, span := d.startSpan(ctx, "select", q.Table)
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	err := d.backend.Select(ctx, q, dest)
+	endSpan(span, err)
+	return err
+}
+
+func (d *db) count(ctx context.Context, q Query) (int64, error) {
+	ctx, span := d.startSpan(ctx, "count", q.Table)
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	n, err := d.backend.Count(ctx, q)
+	endSpan(span, err)
+	return n, err
+}
+
+func (d *db) apply(ctx context.Context, mutations ...Mutation) error {
+	operation, table := "batch", ""
+	if len(mutations) == 1 {
+		operation, table = mutations[0].Kind, mutations[0].Table
+	}
+	ctx, span := d.startSpan(ctx, operation, table)
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := d.backend.Apply(ctx, mutations...)
+	d.stats.record(len(mutations), time.Since(start), err)
+	endSpan(span, err)
+	return err
+}
+
+// queueInsert encola un insert idempotente
+func (d *db) queueInsert(ctx context.Context, table string, row map[string]interface{}) error {
+	id := newMutationID()
+	row["idempotency_key"] = id
+	m, err := newMutation(MutationInsert, table, row)
+	if err != nil {
+		return err
+	}
+	m.ID = id
+	return d.queue(ctx, m)
+}
+
+// queueInsertBatch encola las filas en inserts de hasta batchSize filas.
+// Cada fila lleva su propia clave de idempotencia.
+func (d *db) queueInsertBatch(ctx context.Context, table string, rows []map[string]interface{}) error {
+	size := d.batchSize
+	if size <= 0 {
+		size = len(rows)
+	}
+
+	var firstErr error
+	for start := 0; start < len(rows); start += size {
+		chunk := rows[start:min(start+size, len(rows))]
+		for _, row := range chunk {
+			row["idempotency_key"] = newMutationID()
+		}
+		m, err := newMutation(MutationInsert, table, chunk)
+		if err == nil {
+			m.ID = newMutationID()
+			err = d.queue(ctx, m)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// queueUpsert encola un upsert sobre las columnas de onConflict
+func (d *db) queueUpsert(ctx context.Context, table string, row interface{}, onConflict string) error {
+	m, err := newMutation(MutationUpsert, table, row)
+	if err != nil {
+		return err
+	}
+	m.OnConflict = onConflict
+	return d.queue(ctx, m)
+}
+
+// queueUpdate encola un update filtrado por igualdad de columnas
+func (d *db) queueUpdate(ctx context.Context, table string, values map[string]interface{}, filters map[string]string, where ...Filter) error {
+	m, err := newMutation(MutationUpdate, table, values)
+	if err != nil {
+		return err
+	}
+	m.Filters = filters
+	m.Where = where
+	return d.queue(ctx, m)
+}
+
+// queue guarda en la escritura el traceparent de ctx para que la entrega
+// del outbox quede en la misma traza
+func (d *db) queue(ctx context.Context, m Mutation) error {
+	if d.outbox == nil {
+		return d.apply(ctx, m)
+	}
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	m.TraceParent = carrier["traceparent"]
+
+	if err := d.outbox.Enqueue(m); err != nil {
+		// Sin disco disponible se intenta la escritura directa
+		slog.ErrorContext(ctx, "❌ Error encolando, escritura directa", "kind", m.Kind, "table", m.Table, "error", err)
+		return d.apply(ctx, m)
+	}
+	return nil
+}
+
+func (d *db) startSpan(ctx context.Context, operation, table string) (context.Context, trace.Span) {
+	name := "db." + operation
+	if table != "" {
+		name += " " + table
+	}
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", d.system),
+			attribute.String("db.operation", operation),
+			attribute.String("db.sql.table", table),
+		))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// WriteStats resume las escrituras contra el backend. Cada Mutation cuenta
+// como una petición: en Supabase es una llamada HTTP.
+type WriteStats struct {
+	Requests int64         `json:"requests"`
+	Errors   int64         `json:"errors"`
+	Latency  time.Duration `json:"latency_ns"`
+}
+
+// Sub devuelve la diferencia entre dos acumulados
+func (s WriteStats) Sub(prev WriteStats) WriteStats {
+	return WriteStats{
+		Requests: s.Requests - prev.Requests,
+		Errors:   s.Errors - prev.Errors,
+		Latency:  s.Latency - prev.Latency,
+	}
+}
+
+type writeStats struct {
+	mu    sync.Mutex
+	total WriteStats
+}
+
+func (w *writeStats) record(requests int, latency time.Duration, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.total.Requests += int64(requests)
+	w.total.Latency += latency
+	if err != nil {
+		w.total.Errors++
+	}
+}
+
+func (w *writeStats) snapshot() WriteStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.total
+}
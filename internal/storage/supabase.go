@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/supabase-community/postgrest-go"
+)
+
+// Máximo del cuerpo de una respuesta de error que se conserva en el mensaje
+const supabaseErrorBody = 512
+
+// SupabaseBackend accede a la base de datos a través de PostgREST. No hay
+// transacciones: Apply ejecuta las escrituras una a una.
+type SupabaseBackend struct {
+	url     string
+	headers map[string]string
+}
+
+func NewSupabaseBackend(url, key string) (*SupabaseBackend, error) {
+	if url == "" || key == "" {
+		return nil, errors.New("creando cliente de Supabase: SUPABASE_URL y SUPABASE_KEY son obligatorios")
+	}
+	b := &SupabaseBackend{
+		url: strings.TrimRight(url, "/") + "/rest/v1",
+		headers: map[string]string{
+			"Authorization": "Bearer " + key,
+			"apikey":        key,
+		},
+	}
+	if client := postgrest.NewClient(b.url, "public", b.headers); client.ClientError != nil {
+		return nil, fmt.Errorf("creando cliente de Supabase: %w", client.ClientError)
+	}
+	return b, nil
+}
+
+// client crea un cliente por llamada: postgrest-go no recibe un contexto,
+// así que se fija en el transporte para que el timeout y la cancelación
+// corten la petición HTTP
+func (b *SupabaseBackend) client(ctx context.Context) *postgrest.Client {
+	client := postgrest.NewClient(b.url, "public", b.headers)
+	client.Transport.Parent = contextTransport{ctx: ctx, parent: statusTransport{parent: http.DefaultTransport}}
+	return client
+}
+
+type contextTransport struct {
+	ctx    context.Context
+	parent http.RoundTripper
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.parent.RoundTrip(req.WithContext(t.ctx))
+}
+
+// statusTransport completa las respuestas de error para que el outbox
+// distinga un rechazo de un fallo de red: postgrest-go solo devuelve
+// "(code) message" y, si el cuerpo no es JSON (un 502 de un proxy), ni eso.
+// Sin código de PostgREST o SQLSTATE el código pasa a ser HTTP<status>.
+type statusTransport struct {
+	parent http.RoundTripper
+}
+
+func (t statusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.parent.RoundTrip(req)
+	if err != nil || resp.StatusCode < http.StatusBadRequest {
+		return resp, err
+	}
+	raw, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var body map[string]interface{}
+	if json.Unmarshal(raw, &body) != nil || body == nil {
+		message := strings.TrimSpace(string(raw))
+		if len(message) > supabaseErrorBody {
+			message = message[:supabaseErrorBody]
+		}
+		body = map[string]interface{}{"message": message}
+	}
+	if code, _ := body["code"].(string); code == "" {
+		body["code"] = "HTTP" + strconv.Itoa(resp.StatusCode)
+	}
+	if message, _ := body["message"].(string); message == "" {
+		body["message"] = http.StatusText(resp.StatusCode)
+	}
+
+	raw, err = json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(raw))
+	resp.ContentLength = int64(len(raw))
+	resp.Header.Del("Content-Length")
+	return resp, nil
+}
+
+func (b *SupabaseBackend) Select(ctx context.Context, q Query, dest interface{}) error {
+	query := applyPostgrestFilters(b.client(ctx).From(q.Table).Select(columnsOrAll(q.Columns), "", false), q.Filters)
+	if q.OrderBy != "" {
+		query = query.Order(q.OrderBy, &postgrest.OrderOpts{Ascending: !q.Descending})
+	}
+	if q.Limit > 0 {
+		query = query.Limit(q.Limit, "")
+	}
+
+	_, err := query.ExecuteTo(dest)
+	return err
+}
+
+func (b *SupabaseBackend) Count(ctx context.Context, q Query) (int64, error) {
+	query := applyPostgrestFilters(b.client(ctx).From(q.Table).Select(columnsOrAll(q.Columns), "exact", true), q.Filters)
+	_, count, err := query.Execute()
+	return count, err
+}
+
+func (b *SupabaseBackend) Apply(ctx context.Context, mutations ...Mutation) error {
+	client := b.client(ctx)
+	for _, m := range mutations {
+		if err := applyPostgrest(client, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyPostgrest(client *postgrest.Client, m Mutation) error {
+	var err error
+	switch m.Kind {
+	case MutationInsert:
+		values, uerr := uniformRows(m.Values)
+		if uerr != nil {
+			return uerr
+		}
+		_, _, err = client.From(m.Table).
+			Upsert(values, "idempotency_key", "minimal", "").
+			Execute()
+	case MutationUpsert:
+		_, _, err = client.From(m.Table).
+			Upsert(m.Values, m.OnConflict, "minimal", "").
+			Execute()
+	case MutationUpdate:
+		query := client.From(m.Table).Update(m.Values, "minimal", "")
+		for column, value := range m.Filters {
+			query = query.Eq(column, value)
+		}
+		_, _, err = applyPostgrestFilters(query, m.Where).Execute()
+	case MutationDelete:
+		query := client.From(m.Table).Delete("minimal", "")
+		for column, value := range m.Filters {
+			query = query.Eq(column, value)
+		}
+		_, _, err = applyPostgrestFilters(query, m.Where).Execute()
+	default:
+		err = fmt.Errorf("tipo de escritura desconocido: %s", m.Kind)
+	}
+	return err
+}
+
+func (b *SupabaseBackend) Close() error {
+	return nil
+}
+
+// applyPostgrestFilters agrupa en and=(...) los filtros repetidos sobre una
+// columna: PostgREST solo admite un parámetro por columna.
+func applyPostgrestFilters(query *postgrest.FilterBuilder, filters []Filter) *postgrest.FilterBuilder {
+	perColumn := make(map[string]int, len(filters))
+	for _, f := range filters {
+		perColumn[f.Column]++
+	}
+
+	var combined []string
+	for _, f := range filters {
+		if perColumn[f.Column] > 1 {
+			combined = append(combined, fmt.Sprintf("%s.%s.%s", f.Column, f.Op, f.Value))
+			continue
+		}
+		query = query.Filter(f.Column, f.Op, f.Value)
+	}
+	if len(combined) > 0 {
+		query = query.And(strings.Join(combined, ","), "")
+	}
+	return query
+}
+
+func columnsOrAll(columns string) string {
+	if columns == "" {
+		return "*"
+	}
+	return columns
+}
+
+// uniformRows completa con null las columnas ausentes de un insert en lote:
+// PostgREST exige que todas las filas de un array tengan las mismas claves.
+func uniformRows(values json.RawMessage) (json.RawMessage, error) {
+	if len(values) == 0 || values[0] != '[' {
+		return values, nil
+	}
+	rows, err := decodeRows(values)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]struct{})
+	for _, row := range rows {
+		for column := range row {
+			columns[column] = struct{}{}
+		}
+	}
+	for _, row := range rows {
+		for column := range columns {
+			if _, ok := row[column]; !ok {
+				row[column] = nil
+			}
+		}
+	}
+	return json.Marshal(rows)
+}
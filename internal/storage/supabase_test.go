@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSupabaseContextCancel(t *testing.T) {
+	// PostgREST colgado: solo responde cuando el cliente se va
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	backend, err := NewSupabaseBackend(server.URL, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _ := newMutation(MutationInsert, "t", map[string]interface{}{"a": 1})
+
+	tests := []struct {
+		name string
+		call func(ctx context.Context) error
+	}{
+		{"Select", func(ctx context.Context) error {
+			var rows []map[string]interface{}
+			return backend.Select(ctx, Query{Table: "t"}, &rows)
+		}},
+		{"Count", func(ctx context.Context) error {
+			_, err := backend.Count(ctx, Query{Table: "t"})
+			return err
+		}},
+		{"Apply", func(ctx context.Context) error {
+			return backend.Apply(ctx, m)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(50*time.Millisecond, cancel)
+
+			done := make(chan error, 1)
+			go func() { done <- tt.call(ctx) }()
+			select {
+			case err := <-done:
+				if !errors.Is(err, context.Canceled) {
+					t.Errorf("error = %v, want context.Canceled", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("la llamada sigue bloqueada tras cancelar el contexto")
+			}
+		})
+	}
+
+	// El timeout del contexto también corta la petición
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := backend.Apply(ctx, m); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Apply con timeout: error = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,251 @@
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+
+	"srs-backend/internal/logging"
+	"srs-backend/internal/models"
+	"srs-backend/internal/storage"
+)
+
+// Los streams se etiquetan por app y public_id (MD5 del canal, el mismo que
+// nombra el thumbnail). La clave de transmisión nunca es una etiqueta.
+var (
+	registry = prometheus.NewRegistry()
+
+	serverCPU = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "srs_server_cpu_percent",
+		Help: "CPU del proceso SRS en porcentaje.",
+	})
+	serverMemory = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "srs_server_memory_bytes",
+		Help: "Memoria residente del proceso SRS.",
+	})
+	publishers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "srs_publishers",
+		Help: "Conexiones de publicación en SRS.",
+	})
+	players = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "srs_players",
+		Help: "Conexiones de reproducción en SRS.",
+	})
+	streamRecvKbps = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "srs_stream_recv_kbps",
+		Help: "Bitrate de ingesta por stream (media de 30s).",
+	}, []string{"app", "public_id"})
+	streamSendKbps = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "srs_stream_send_kbps",
+		Help: "Bitrate de salida por stream (media de 30s).",
+	}, []string{"app", "public_id"})
+	streamClients = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "srs_stream_clients",
+		Help: "Clientes conectados por stream.",
+	}, []string{"app", "public_id"})
+
+	callbacks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "srs_backend_callbacks_total",
+		Help: "Hooks HTTP de SRS recibidos por acción y resultado.",
+	}, []string{"hook", "result"})
+	callbackDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "srs_backend_callback_duration_seconds",
+		Help:    "Tiempo de respuesta de los hooks HTTP de SRS.",
+		Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"hook"})
+	geoRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "srs_backend_geo_rejections_total",
+		Help: "Hooks rechazados por la restricción geográfica del canal, por acción y país.",
+	}, []string{"hook", "country"})
+	referrerRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "srs_backend_referrer_rejections_total",
+		Help: "Plays rechazados por los dominios autorizados del canal, por regla.",
+	}, []string{"rule"})
+	webhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "srs_backend_webhook_deliveries_total",
+		Help: "Intentos de entrega de webhooks, por resultado.",
+	}, []string{"result"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		serverCPU, serverMemory, publishers, players,
+		streamRecvKbps, streamSendKbps, streamClients,
+		callbacks, callbackDuration, geoRejections, referrerRejections, webhookDeliveries,
+	)
+}
+
+// Sources son los estados internos que se leen en cada scrape.
+type Sources struct {
+	Backend        string
+	WriteStats     func() storage.WriteStats
+	OutboxStatus   func() storage.OutboxStatus
+	ActiveCaptures func() int
+	EventClients   func() int
+	StatsClients   func() int
+}
+
+// Register agrega las métricas internas del backend. Llamar una sola vez.
+func Register(src Sources) {
+	labels := prometheus.Labels{"backend": src.Backend}
+	registry.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name:        "srs_backend_storage_writes_total",
+			Help:        "Escrituras ejecutadas contra la base de datos.",
+			ConstLabels: labels,
+		}, func() float64 { return float64(src.WriteStats().Requests) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name:        "srs_backend_storage_write_errors_total",
+			Help:        "Escrituras contra la base de datos que devolvieron error.",
+			ConstLabels: labels,
+		}, func() float64 { return float64(src.WriteStats().Errors) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name:        "srs_backend_storage_write_seconds_total",
+			Help:        "Tiempo acumulado en escrituras contra la base de datos.",
+			ConstLabels: labels,
+		}, func() float64 { return src.WriteStats().Latency.Seconds() }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "srs_backend_outbox_depth",
+			Help: "Escrituras pendientes en el outbox.",
+		}, func() float64 { return float64(src.OutboxStatus().Depth) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "srs_backend_outbox_oldest_pending_seconds",
+			Help: "Antigüedad de la escritura pendiente más antigua.",
+		}, func() float64 { return src.OutboxStatus().OldestPendingAgeSeconds }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "srs_backend_outbox_dead_lettered_total",
+			Help: "Escrituras descartadas a outbox.dead.",
+		}, func() float64 { return float64(src.OutboxStatus().DeadLettered) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "srs_backend_thumbnail_captures_active",
+			Help: "Loops de captura de thumbnails activos.",
+		}, func() float64 { return float64(src.ActiveCaptures()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "srs_backend_event_stream_clients",
+			Help: "Clientes conectados a /api/v1/events/stream.",
+		}, func() float64 { return float64(src.EventClients()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "srs_backend_stats_feed_clients",
+			Help: "Conexiones abiertas a /api/v1/stats/ws.",
+		}, func() float64 { return float64(src.StatsClients()) }),
+	)
+}
+
+// Handler sirve /metrics en formato OpenMetrics (o texto de Prometheus si el
+// cliente no lo negocia)
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// StreamSample es la muestra de un stream en un ciclo del recolector
+type StreamSample struct {
+	App      string
+	PublicID string
+	RecvKbps int
+	SendKbps int
+	Clients  int
+}
+
+// RecordServer reemplaza las métricas de SRS con las del último ciclo. Un
+// primario y su respaldo comparten public_id y se suman.
+func RecordServer(cpuPercent float64, memoryMB int64, pubs, plays int, streams []StreamSample) {
+	serverCPU.Set(cpuPercent)
+	serverMemory.Set(float64(memoryMB * 1024 * 1024))
+	publishers.Set(float64(pubs))
+	players.Set(float64(plays))
+
+	streamRecvKbps.Reset()
+	streamSendKbps.Reset()
+	streamClients.Reset()
+	for _, s := range streams {
+		streamRecvKbps.WithLabelValues(s.App, s.PublicID).Add(float64(s.RecvKbps))
+		streamSendKbps.WithLabelValues(s.App, s.PublicID).Add(float64(s.SendKbps))
+		streamClients.WithLabelValues(s.App, s.PublicID).Add(float64(s.Clients))
+	}
+}
+
+// RecordGeoRejection cuenta un play o publish rechazado por país. Sin datos
+// GeoIP el país es "unknown".
+func RecordGeoRejection(hook, country string) {
+	if country == "" {
+		country = "unknown"
+	}
+	geoRejections.WithLabelValues(hook, country).Inc()
+}
+
+// RecordReferrerRejection cuenta un play rechazado por dominio. El dominio
+// no es una etiqueta: cualquier página puede inventar uno.
+func RecordReferrerRejection(rule string) {
+	referrerRejections.WithLabelValues(rule).Inc()
+}
+
+// InstrumentHook cuenta, mide y traza un hook de SRS. La etiqueta hook es la
+// action del callback (on_publish, on_play...) o fallback si no viene en el
+// cuerpo; result es allow o reject según la respuesta ("0" o {"code":0}
+// aceptan). El span del hook viaja en el contexto de la petición.
+func InstrumentHook(fallback string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hook := fallback
+		var cb models.SRSCallback
+		if body, err := io.ReadAll(r.Body); err == nil {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			if json.Unmarshal(body, &cb) == nil && cb.Action != "" {
+				hook = cb.Action
+			}
+		}
+
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := startHookSpan(ctx, hook, cb)
+		defer span.End()
+		// Los logs del hook y de su trabajo en segundo plano llevan estos campos
+		ctx = logging.WithAttrs(ctx, logging.KeyHook, hook, logging.KeyClientID, cb.ClientID)
+		ctx = logging.WithAttrs(ctx, logging.Stream(cb.App, cb.Stream)...)
+
+		rec := &hookRecorder{ResponseWriter: w}
+		start := time.Now()
+		next(rec, r.WithContext(ctx))
+		result := rec.result()
+		callbackDuration.WithLabelValues(hook).Observe(time.Since(start).Seconds())
+		callbacks.WithLabelValues(hook, result).Inc()
+		span.SetAttributes(attribute.String("srs.result", result))
+	}
+}
+
+// hookRecorder guarda el inicio de la respuesta para clasificarla
+type hookRecorder struct {
+	http.ResponseWriter
+	body []byte
+}
+
+func (r *hookRecorder) Write(p []byte) (int, error) {
+	if len(r.body) < 64 {
+		r.body = append(r.body, p[:min(len(p), 64-len(r.body))]...)
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *hookRecorder) result() string {
+	body := strings.TrimSpace(string(r.body))
+	if body == "0" || strings.HasPrefix(strings.ReplaceAll(body, " ", ""), `{"code":0`) {
+		return "allow"
+	}
+	return "reject"
+}
+
+// RecordWebhookDelivery cuenta un intento de entrega: delivered, retry, dead
+// o failed (envío de prueba)
+func RecordWebhookDelivery(result string) {
+	webhookDeliveries.WithLabelValues(result).Inc()
+}
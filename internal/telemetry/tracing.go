@@ -0,0 +1,106 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"srs-backend/internal/models"
+)
+
+// Exportadores de trazas (OTEL_TRACES_EXPORTER)
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+var tracer = otel.Tracer("srs-backend/telemetry")
+
+// SetupTracing instala el TracerProvider global. Con otlp el destino sale de
+// las variables estándar (OTEL_EXPORTER_OTLP_ENDPOINT, por defecto
+// http://localhost:4318); con stdout cada span se escribe como JSON al
+// terminar. Devuelve la función que vacía y cierra el exportador.
+func SetupTracing(exporter, serverID string, sampleRatio float64) (func(context.Context) error, error) {
+	return setupTracing(exporter, serverID, sampleRatio, os.Stdout)
+}
+
+// setupTracing es SetupTracing con el destino del exportador stdout
+func setupTracing(exporter, serverID string, sampleRatio float64, stdout io.Writer) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		spanExporter, err = otlptracehttp.New(context.Background())
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(stdout))
+	default:
+		return nil, fmt.Errorf("OTEL_TRACES_EXPORTER desconocido: %q", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// OTEL_SERVICE_NAME y OTEL_RESOURCE_ATTRIBUTES tienen prioridad
+	res, err := resource.New(context.Background(),
+		resource.WithAttributes(
+			attribute.String("service.name", "srs-backend"),
+			attribute.String("service.instance.id", serverID),
+		),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	}
+	if exporter == ExporterStdout {
+		opts = append(opts, sdktrace.WithSyncer(spanExporter))
+	} else {
+		opts = append(opts, sdktrace.WithBatcher(spanExporter))
+	}
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// hookAttributes identifica el callback en el span. La clave de transmisión
+// (stream) no se incluye.
+func hookAttributes(cb models.SRSCallback) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("srs.action", cb.Action),
+		attribute.String("srs.app", cb.App),
+		attribute.String("srs.vhost", cb.Vhost),
+		attribute.String("srs.client_id", cb.ClientID),
+		attribute.String("client.address", cb.IP),
+	}
+	if cb.RequestID != "" {
+		attrs = append(attrs, attribute.String("srs.request_id", cb.RequestID))
+	}
+	return attrs
+}
+
+func startHookSpan(ctx context.Context, hook string, cb models.SRSCallback) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "srs."+hook,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(hookAttributes(cb)...))
+}
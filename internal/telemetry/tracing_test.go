@@ -0,0 +1,126 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// exportedSpan es lo que el exportador stdout escribe de cada span
+type exportedSpan struct {
+	Name        string
+	SpanContext struct{ TraceID string }
+	Parent      struct{ TraceID string }
+	Attributes  []struct {
+		Key   string
+		Value struct{ Value interface{} }
+	}
+}
+
+func (s exportedSpan) attr(key string) string {
+	for _, a := range s.Attributes {
+		if a.Key == key {
+			v, _ := a.Value.Value.(string)
+			return v
+		}
+	}
+	return ""
+}
+
+func readSpans(t *testing.T, out *bytes.Buffer) []exportedSpan {
+	t.Helper()
+	var spans []exportedSpan
+	for dec := json.NewDecoder(out); ; {
+		var s exportedSpan
+		if err := dec.Decode(&s); errors.Is(err, io.EOF) {
+			return spans
+		} else if err != nil {
+			t.Fatalf("salida del exportador no es JSON: %v", err)
+		}
+		spans = append(spans, s)
+	}
+}
+
+// El TracerProvider global solo se puede instalar una vez para los tracers
+// ya creados: todos los casos comparten el exportador
+func TestInstrumentHookStdoutSpans(t *testing.T) {
+	var out bytes.Buffer
+	shutdown, err := setupTracing(ExporterStdout, "srv-test", 1, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown(context.Background())
+
+	const parentTrace = "4bf92f3577b34da6a3ce929d0e0e4736"
+	tests := []struct {
+		name        string
+		traceparent string
+		response    string
+		wantSpan    bool
+		wantResult  string
+	}{
+		{"aceptado", "", "0", true, "allow"},
+		{"aceptado con JSON", "", `{"code":0}`, true, "allow"},
+		{"rechazado", "", "403", true, "reject"},
+		{"padre muestreado", "00-" + parentTrace + "-00f067aa0ba902b7-01", "0", true, "allow"},
+		{"padre sin muestrear", "00-" + parentTrace + "-00f067aa0ba902b7-00", "0", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out.Reset()
+			handler := InstrumentHook("on_publish", func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.response))
+			})
+			body := `{"action":"on_publish","app":"live","stream":"clave-secreta","client_id":"c1","ip":"203.0.113.7"}`
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/publish", strings.NewReader(body))
+			if tt.traceparent != "" {
+				r.Header.Set("traceparent", tt.traceparent)
+			}
+			// El exportador stdout escribe cada span al terminar
+			handler(httptest.NewRecorder(), r)
+
+			if strings.Contains(out.String(), "clave-secreta") {
+				t.Error("la clave de transmisión aparece en la traza")
+			}
+			spans := readSpans(t, &out)
+			if !tt.wantSpan {
+				if len(spans) != 0 {
+					t.Fatalf("%d spans exportados, want 0", len(spans))
+				}
+				return
+			}
+			if len(spans) != 1 {
+				t.Fatalf("%d spans exportados, want 1", len(spans))
+			}
+			span := spans[0]
+			if span.Name != "srs.on_publish" {
+				t.Errorf("span %q, want srs.on_publish", span.Name)
+			}
+			if got := span.attr("srs.result"); got != tt.wantResult {
+				t.Errorf("srs.result = %q, want %q", got, tt.wantResult)
+			}
+			if got := span.attr("srs.client_id"); got != "c1" {
+				t.Errorf("srs.client_id = %q, want c1", got)
+			}
+			if tt.traceparent != "" && (span.SpanContext.TraceID != parentTrace || span.Parent.TraceID != parentTrace) {
+				t.Errorf("trace %s (padre %s), want %s del traceparent", span.SpanContext.TraceID, span.Parent.TraceID, parentTrace)
+			}
+		})
+	}
+}
+
+func TestSetupTracingUnknownExporter(t *testing.T) {
+	if _, err := setupTracing("jaeger", "srv-test", 1, io.Discard); err == nil {
+		t.Error("exportador desconocido aceptado")
+	}
+	shutdown, err := setupTracing(ExporterNone, "srv-test", 1, io.Discard)
+	if err != nil || shutdown(context.Background()) != nil {
+		t.Errorf("exportador none: %v", err)
+	}
+}